@@ -0,0 +1,51 @@
+package audio
+
+const (
+	MIXER_DEFAULT_SAMPLE_RATE = 44100
+)
+
+// MARK: 音声のミキサーの定義
+type Mixer struct {
+	sampleRate      int
+	cyclesPerSample float64 // 1サンプルあたりのCPUサイクル数
+
+	elapsed float64 // 現在のサンプルに含めたCPUサイクル数
+	sum     float64
+	count   int
+
+	samples []float32
+}
+
+// MARK: ミキサーのコンストラクタ
+func NewMixer(cpuClockHz int, sampleRate int) *Mixer {
+	return &Mixer{
+		sampleRate:      sampleRate,
+		cyclesPerSample: float64(cpuClockHz) / float64(sampleRate),
+	}
+}
+
+// MARK: 1CPUサイクル分の出力を加えるメソッド
+func (m *Mixer) Clock(apu float32, expansion float32) {
+	// 拡張音源はAPUの出力を線形近似した場合と同じスケールのため、そのまま加算する
+	m.sum += float64(apu) + float64(expansion)
+	m.count++
+	m.elapsed++
+
+	// 1サンプルの区間の平均を出力する (簡易的なローパスフィルタ)
+	if m.elapsed >= m.cyclesPerSample {
+		m.elapsed -= m.cyclesPerSample
+		m.samples = append(m.samples, float32(m.sum/float64(m.count)))
+		m.sum = 0
+		m.count = 0
+	}
+}
+
+// MARK: サンプリング周波数を返すメソッド
+func (m *Mixer) SampleRate() int {
+	return m.sampleRate
+}
+
+// MARK: これまでに出力したサンプルを返すメソッド
+func (m *Mixer) Samples() []float32 {
+	return m.samples
+}
@@ -0,0 +1,68 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// MARK: ミキサーのサンプル数と平均のテスト
+func TestMixerAveragesPerSample(t *testing.T) {
+	// 1サンプル = 4 CPUサイクル
+	m := NewMixer(400, 100)
+	for i := range 40 {
+		expansion := float32(0)
+		if i%2 == 0 {
+			expansion = 0.5
+		}
+		m.Clock(0.25, expansion)
+	}
+
+	samples := m.Samples()
+	if len(samples) != 10 {
+		t.Fatalf("got %d samples, want 10", len(samples))
+	}
+	for i, s := range samples {
+		if s != 0.5 {
+			t.Errorf("sample %d = %f, want 0.5 (APU 0.25 + average expansion 0.25)", i, s)
+		}
+	}
+}
+
+// MARK: 1サンプルのCPUサイクル数が整数でない場合のサンプル数のテスト
+func TestMixerFractionalRate(t *testing.T) {
+	m := NewMixer(1789773, MIXER_DEFAULT_SAMPLE_RATE)
+	for range 1789773 {
+		m.Clock(0, 0)
+	}
+	// 浮動小数点の誤差で最後の1サンプルが次の区間にずれることは許容する
+	if got := len(m.Samples()); got < MIXER_DEFAULT_SAMPLE_RATE-1 || got > MIXER_DEFAULT_SAMPLE_RATE {
+		t.Fatalf("got %d samples for one second, want %d", got, MIXER_DEFAULT_SAMPLE_RATE)
+	}
+}
+
+// MARK: WAVの書き出しのテスト
+func TestWriteWAV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteWAV(&buf, 44100, []float32{0, 1, -1, 2, 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if len(data) != WAV_HEADER_SIZE+5*2 {
+		t.Fatalf("size = %d, want %d", len(data), WAV_HEADER_SIZE+10)
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+		t.Fatalf("bad header % X", data[:WAV_HEADER_SIZE])
+	}
+	if rate := binary.LittleEndian.Uint32(data[24:28]); rate != 44100 {
+		t.Fatalf("sample rate = %d", rate)
+	}
+
+	// 範囲外の値は -1 - 1 に制限される
+	want := []int16{0, 32767, -32767, 32767, 16384}
+	for i, w := range want {
+		if got := int16(binary.LittleEndian.Uint16(data[WAV_HEADER_SIZE+i*2:])); got != w {
+			t.Errorf("sample %d = %d, want %d", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package audio
+
+const (
+	// APUの出力を線形近似した場合の1ステップあたりの出力 (拡張音源の音量の基準)
+	PULSE_LINEAR_GAIN = 0.00752 // 矩形波 (0-15)
+	DMC_LINEAR_GAIN   = 0.00335 // DMC (0-127)
+
+	// 音量15の矩形波1チャンネルの出力 (拡張音源の音量は各チップのミックス比とこの値の積で決める)
+	PULSE_MAX_LEVEL = 15 * PULSE_LINEAR_GAIN
+
+	PULSE_DUTY_STEPS = 8
+)
+
+// MARK: 長さカウンタのロード値のテーブル
+var lengthTable = [32]uint8{
+	10, 254, 20, 2, 40, 4, 80, 6, 160, 8, 60, 10, 14, 12, 26, 14,
+	12, 16, 24, 18, 48, 20, 96, 22, 192, 24, 72, 26, 16, 28, 32, 30,
+}
+
+// MARK: 矩形波のデューティ比のテーブル
+var dutyTable = [4][PULSE_DUTY_STEPS]uint8{
+	{0, 1, 0, 0, 0, 0, 0, 0}, // 12.5%
+	{0, 1, 1, 0, 0, 0, 0, 0}, // 25%
+	{0, 1, 1, 1, 1, 0, 0, 0}, // 50%
+	{1, 0, 0, 1, 1, 1, 1, 1}, // 25% (反転)
+}
+
+// MARK: 長さカウンタの定義
+type LengthCounter struct {
+	value   uint8
+	halt    bool
+	enabled bool
+}
+
+// MARK: 長さカウンタの有効化 (無効化するとカウンタは0になる)
+func (l *LengthCounter) SetEnabled(enabled bool) {
+	l.enabled = enabled
+	if !enabled {
+		l.value = 0
+	}
+}
+
+// MARK: 長さカウンタの停止フラグの設定
+func (l *LengthCounter) SetHalt(halt bool) {
+	l.halt = halt
+}
+
+// MARK: テーブルのインデックス (5bit) から長さカウンタをロードする
+func (l *LengthCounter) Load(index uint8) {
+	if l.enabled {
+		l.value = lengthTable[index&0x1F]
+	}
+}
+
+// MARK: 長さカウンタのクロック (ハーフフレームごと)
+func (l *LengthCounter) Clock() {
+	if l.value > 0 && !l.halt {
+		l.value--
+	}
+}
+
+// MARK: 長さカウンタが0でないかを返す
+func (l *LengthCounter) Active() bool {
+	return l.value > 0
+}
+
+// MARK: エンベロープの定義
+type Envelope struct {
+	start    bool
+	loop     bool
+	constant bool
+	period   uint8 // 一定音量の場合は音量
+	divider  uint8
+	decay    uint8
+}
+
+// MARK: エンベロープのレジスタへの書き込み (bit5: ループ, bit4: 一定音量, bit0-3: 音量/周期)
+func (e *Envelope) Write(value uint8) {
+	e.loop = value&0x20 != 0
+	e.constant = value&0x10 != 0
+	e.period = value & 0x0F
+}
+
+// MARK: エンベロープの再スタート (次のクォーターフレームで反映される)
+func (e *Envelope) Restart() {
+	e.start = true
+}
+
+// MARK: エンベロープのクロック (クォーターフレームごと)
+func (e *Envelope) Clock() {
+	if e.start {
+		e.start = false
+		e.decay = 15
+		e.divider = e.period
+		return
+	}
+	if e.divider > 0 {
+		e.divider--
+		return
+	}
+	e.divider = e.period
+	if e.decay > 0 {
+		e.decay--
+	} else if e.loop {
+		e.decay = 15
+	}
+}
+
+// MARK: エンベロープの音量 (0-15)
+func (e *Envelope) Volume() uint8 {
+	if e.constant {
+		return e.period
+	}
+	return e.decay
+}
+
+// MARK: APU互換の矩形波 (スイープを除く) の定義
+type Pulse struct {
+	Envelope Envelope
+	Length   LengthCounter
+
+	duty   uint8
+	step   uint8
+	period uint16 // 11bit
+	timer  uint16
+}
+
+// MARK: 制御レジスタへの書き込み ($4000 / $4004 相当)
+func (p *Pulse) WriteControl(value uint8) {
+	// bit6-7: デューティ, bit5: 長さカウンタ停止 (エンベロープのループ)
+	p.duty = value >> 6
+	p.Length.SetHalt(value&0x20 != 0)
+	p.Envelope.Write(value)
+}
+
+// MARK: タイマの下位8bitへの書き込み ($4002 / $4006 相当)
+func (p *Pulse) WriteTimerLow(value uint8) {
+	p.period = p.period&0x0700 | uint16(value)
+}
+
+// MARK: タイマの上位3bitと長さカウンタへの書き込み ($4003 / $4007 相当)
+func (p *Pulse) WriteTimerHigh(value uint8) {
+	p.period = p.period&0x00FF | uint16(value&0x07)<<8
+	p.Length.Load(value >> 3)
+	p.Envelope.Restart()
+	p.step = 0
+}
+
+// MARK: タイマのクロック (APUサイクル = 2 CPUサイクルごと)
+func (p *Pulse) ClockTimer() {
+	if p.timer == 0 {
+		p.timer = p.period
+		p.step = (p.step + 1) % PULSE_DUTY_STEPS
+	} else {
+		p.timer--
+	}
+}
+
+// MARK: 矩形波の出力 (0-15)
+func (p *Pulse) Output() uint8 {
+	if !p.Length.Active() || dutyTable[p.duty][p.step] == 0 {
+		return 0
+	}
+	return p.Envelope.Volume()
+}
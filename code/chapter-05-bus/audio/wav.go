@@ -0,0 +1,46 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+const (
+	WAV_HEADER_SIZE     = 44
+	WAV_BITS_PER_SAMPLE = 16
+	WAV_FORMAT_PCM      = 1
+	WAV_CHANNELS        = 1
+)
+
+// MARK: サンプルを16bitモノラルのWAV形式で書き出す関数
+func WriteWAV(w io.Writer, sampleRate int, samples []float32) error {
+	dataSize := len(samples) * WAV_BITS_PER_SAMPLE / 8
+	blockAlign := WAV_CHANNELS * WAV_BITS_PER_SAMPLE / 8
+
+	header := make([]uint8, 0, WAV_HEADER_SIZE)
+	header = append(header, "RIFF"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(WAV_HEADER_SIZE-8+dataSize))
+	header = append(header, "WAVEfmt "...)
+	header = binary.LittleEndian.AppendUint32(header, 16) // fmtチャンクのサイズ
+	header = binary.LittleEndian.AppendUint16(header, WAV_FORMAT_PCM)
+	header = binary.LittleEndian.AppendUint16(header, WAV_CHANNELS)
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(sampleRate*blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, uint16(blockAlign))
+	header = binary.LittleEndian.AppendUint16(header, WAV_BITS_PER_SAMPLE)
+	header = append(header, "data"...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(dataSize))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	// -1 - 1 の範囲に制限して16bitの整数に変換する
+	data := make([]uint8, 0, dataSize)
+	for _, s := range samples {
+		s = max(-1, min(1, s))
+		data = binary.LittleEndian.AppendUint16(data, uint16(int16(math.Round(float64(s)*math.MaxInt16))))
+	}
+	_, err := w.Write(data)
+	return err
+}
@@ -1,38 +1,191 @@
 package bus
 
+import (
+	"fc-emu/audio"
+	"fc-emu/cartridge"
+	"fc-emu/ram"
+)
+
 const (
-	CPU_WRAM_SIZE = 2 * 1024 // 2kB
+	CPU_WRAM_SIZE        = 2 * 1024 // 2kB
+	CPU_WRAM_END         = 0x1FFF
+	CPU_WRAM_MIRROR_MASK = CPU_WRAM_SIZE - 1
+
+	PPU_REGISTERS_START = 0x2000
+	PPU_REGISTERS_END   = 0x3FFF
+
+	PPU_REGISTERS_MIRROR_MASK = 0x0007 // 8バイトごとにミラーリング
+
+	JOYPAD1_REGISTER     = 0x4016
+	JOYPAD2_REGISTER     = 0x4017
+	JOYPAD_DRIVEN_BITS   = 0x1F // D0-D4のみがコントローラ側から駆動される
+	JOYPAD_OPEN_BUS_BITS = ^uint8(JOYPAD_DRIVEN_BITS)
+
+	CARTRIDGE_START = 0x4020
 )
 
 // MARK: Busの定義
 type Bus struct {
-	wram [CPU_WRAM_SIZE]uint8 // 一時的なプログラムのバイト列
+	wram    wram  // 一時的なプログラムのバイト列
+	openBus uint8 // データバスに最後に乗った値 (オープンバス)
+
+	cartridge *cartridge.Cartridge // 接続されているカートリッジ (nilの場合は未接続)
+
+	ramInit *ram.Initializer // 電源投入時のRAM初期化器
+
+	cycles   uint64       // 電源投入からのCPUサイクル数
+	pc       uint16       // 実行中の命令のアドレス
+	recorder *Recorder    // メモリアクセス記録器 (nilの場合は記録しない)
+	mixer    *audio.Mixer // 音声のミキサー (nilの場合は音声を出力しない)
 }
 
 // MARK: Busのコンストラクタ
 func NewBus() Bus {
-	return Bus{}
+	return NewBusWithPowerOnState(ram.PowerOnState{Pattern: ram.InitZero})
+}
+
+// MARK: 電源投入時のRAMの状態を指定するBusのコンストラクタ
+func NewBusWithPowerOnState(state ram.PowerOnState) Bus {
+	b := Bus{
+		ramInit: ram.NewInitializer(state),
+	}
+	b.ramInit.Fill(b.wram[:])
+	return b
+}
+
+/*
+	CPU メモリマップ
+	(範囲 / サイズ / コンポーネント)
+
+	$0000-$07FF 0x0800 2kBのWRAM
+	$0800-$0FFF 0x0800 WRAMのミラーリング x3
+	$1000-$17FF 0x0800
+	$1800-$1FFF 0x0800
+	$2000-$2007 0x0008 PPUレジスタ
+	$2008-$3FFF 0x1FF8 PPUレジスタのミラーリング
+	$4016-$4017 0x0002 コントローラ (D5-D7はオープンバス)
+	$4020-$FFFF 0xBFE0 カートリッジ (PRG-ROM / PRG-RAM / マッパーのレジスタ)
+
+	どのデバイスも応答しないアドレスを読み取った場合は
+	データバスに最後に乗った値 (多くはオペランドの上位バイト) が返る
+*/
+
+// MARK: WRAMのミラーリングを解決したアドレスを返す
+func wramAddress(address uint16) uint16 {
+	return address & CPU_WRAM_MIRROR_MASK // 2kBでミラーリング
+}
+
+// MARK: カートリッジを接続するメソッド
+func (b *Bus) ConnectCartridge(c *cartridge.Cartridge) {
+	// PRG-RAMもWRAMと同じ電源投入時の状態にする
+	c.PowerOn(b.ramInit)
+	b.cartridge = c
+}
+
+// MARK: メモリアクセス記録器を接続するメソッド
+func (b *Bus) AttachRecorder(recorder *Recorder) {
+	b.recorder = recorder
+}
+
+// MARK: 音声のミキサーを接続するメソッド
+func (b *Bus) AttachMixer(mixer *audio.Mixer) {
+	b.mixer = mixer
+}
+
+// MARK: CPUサイクルを進めるメソッド
+func (b *Bus) Tick(cycles uint64) {
+	b.cycles += cycles
+
+	if b.mixer == nil {
+		if b.cartridge != nil {
+			b.cartridge.Tick(cycles)
+		}
+		return
+	}
+
+	// 拡張音源の出力はサイクルごとに変化するため、1サイクルずつ進めてミキサーに渡す
+	for range cycles {
+		if b.cartridge != nil {
+			b.cartridge.Tick(1)
+		}
+		// TODO: APUの出力 (APU未実装のため0)
+		b.mixer.Clock(0, b.ExpansionAudioOutput())
+	}
+}
+
+// MARK: IRQ信号の状態を返すメソッド
+func (b *Bus) IRQ() bool {
+	// TODO: APUのフレームカウンタ / DMCのIRQ
+	return b.cartridge != nil && b.cartridge.IRQ()
+}
+
+// MARK: カートリッジの拡張音源の出力を返すメソッド
+func (b *Bus) ExpansionAudioOutput() float32 {
+	if b.cartridge == nil {
+		return 0
+	}
+	return b.cartridge.AudioOutput()
+}
+
+// MARK: 電源投入からのCPUサイクル数を返すメソッド
+func (b *Bus) Cycles() uint64 {
+	return b.cycles
+}
+
+// MARK: メモリアクセスを記録するメソッド
+func (b *Bus) record(kind AccessKind, address uint16, value uint8) {
+	if b.recorder == nil {
+		return
+	}
+	b.recorder.Record(Access{
+		Kind:    kind,
+		Address: address,
+		Value:   value,
+		Cycle:   b.cycles,
+		PC:      b.pc,
+	})
+}
+
+// MARK: 命令のフェッチ (1バイト)
+func (b *Bus) FetchOpcodeFrom(address uint16) uint8 {
+	b.pc = address
+	value := b.read(address)
+	b.record(AccessExecute, address, value)
+	return value
 }
 
 // MARK: メモリの読み取り (1バイト)
 func (b *Bus) ReadByteFrom(address uint16) uint8 {
-	/*
-		CPU メモリマップ
-		(範囲 / サイズ / コンポーネント)
+	value := b.read(address)
+	b.record(AccessRead, address, value)
+	return value
+}
 
-		$0000-$07FF 0x0800 2kBのWRAM
-		$0800-$0FFF 0x0800 WRAMのミラーリング x3
-		$1000-$17FF 0x0800
-		$1800-$1FFF 0x0800
-	*/
+// MARK: 各コンポーネントからの読み取り
+func (b *Bus) read(address uint16) uint8 {
+	var value uint8
 
 	switch {
-	case 0x0000 <= address && address <= 0x1FFF:
-		return b.wram[address&0x07FF] // 2kBでミラーリング
+	case address <= CPU_WRAM_END:
+		value = b.wram.ReadByteFrom(address)
+	case PPU_REGISTERS_START <= address && address <= PPU_REGISTERS_END:
+		// TODO: PPUレジスタの読み取り (PPU未実装のためオープンバスを返す)
+		value = b.openBus
+	case address == JOYPAD1_REGISTER || address == JOYPAD2_REGISTER:
+		// TODO: コントローラの状態を D0-D4 に反映する
+		value = b.openBus & JOYPAD_OPEN_BUS_BITS
+	case CARTRIDGE_START <= address && b.cartridge != nil:
+		// カートリッジが駆動しないbitはオープンバスの値になる
+		var driven uint8
+		value, driven = b.cartridge.ReadByteFrom(address)
+		value = value&driven | b.openBus&^driven
 	default:
-		// TODO: 正しいコンポーネントから値を読み取って返す
-		return 0x00
+		// どのデバイスも応答しないためオープンバスの値を返す
+		value = b.openBus
 	}
+
+	b.openBus = value
+	return value
 }
 
 // MARK: メモリの読み取り (2バイト)
@@ -44,28 +197,76 @@ func (b *Bus) ReadWordFrom(address uint16) uint16 {
 
 // MARK: メモリへの書き込み (1バイト)
 func (b *Bus) WriteByteAt(address uint16, value uint8) {
-	/*
-		CPU メモリマップ
-		(範囲 / サイズ / コンポーネント)
-
-		$0000-$07FF 0x0800 2kBのWRAM
-		$0800-$0FFF 0x0800 WRAMのミラーリング x3
-		$1000-$17FF 0x0800
-		$1800-$1FFF 0x0800
-	*/
+	b.openBus = value
+	b.record(AccessWrite, address, value)
 
 	switch {
-	case 0x0000 <= address && address <= 0x1FFF:
-		b.wram[address] = value
+	case address <= CPU_WRAM_END:
+		b.wram.WriteByteAt(address, value)
+	case PPU_REGISTERS_START <= address && address <= PPU_REGISTERS_END:
+		// TODO: PPUレジスタへの書き込み
+		// カートリッジ (MMC5等) もPPUレジスタへの書き込みを監視している
+		if b.cartridge != nil {
+			b.cartridge.ObservePPURegister(PPU_REGISTERS_START|address&PPU_REGISTERS_MIRROR_MASK, value)
+		}
+	case CARTRIDGE_START <= address && b.cartridge != nil:
+		b.cartridge.WriteByteAt(address, value)
 	default:
 		// TODO: 正しいコンポーネントに値を書き込む
 	}
 }
 
 // MARK: メモリへの書き込み (2バイト)
-func (b *Bus) WriteWordAt(address uint16, value uint8) {
+func (b *Bus) WriteWordAt(address uint16, value uint16) {
 	lower := uint8(value & 0xFF)
 	upper := uint8(value >> 8)
 	b.WriteByteAt(address, lower)
 	b.WriteByteAt(address+1, upper)
 }
+
+// MARK: メモリの読み取り (1バイト / 副作用なし)
+func (b *Bus) PeekByteFrom(address uint16) uint8 {
+	// デバッガやメモリビューア向けのため、オープンバスの値も更新しない
+	switch {
+	case address <= CPU_WRAM_END:
+		return b.wram.PeekByteFrom(address)
+	case PPU_REGISTERS_START <= address && address <= PPU_REGISTERS_END:
+		// TODO: PPUレジスタの読み取り (副作用なし)
+		return b.openBus
+	case address == JOYPAD1_REGISTER || address == JOYPAD2_REGISTER:
+		// TODO: コントローラの状態を D0-D4 に反映する (シフトレジスタは進めない)
+		return b.openBus & JOYPAD_OPEN_BUS_BITS
+	case CARTRIDGE_START <= address && b.cartridge != nil:
+		value, driven := b.cartridge.PeekByteFrom(address)
+		return value&driven | b.openBus&^driven
+	default:
+		return b.openBus
+	}
+}
+
+// MARK: メモリの読み取り (2バイト / 副作用なし)
+func (b *Bus) PeekWordFrom(address uint16) uint16 {
+	lower := b.PeekByteFrom(address)
+	upper := b.PeekByteFrom(address + 1)
+	return uint16(upper)<<8 | uint16(lower)
+}
+
+// MARK: メモリの読み取り (範囲 / 副作用なし)
+func (b *Bus) PeekRange(start uint16, dst []uint8) {
+	for i := range dst {
+		dst[i] = b.PeekByteFrom(start + uint16(i)) // $FFFFを超えた分は$0000へ折り返す
+	}
+}
+
+// MARK: メモリへの書き込み (1バイト / 副作用なし)
+func (b *Bus) PokeByteAt(address uint16, value uint8) {
+	// レジスタへの書き込みによる副作用は発生させず、メモリの内容のみを書き換える
+	switch {
+	case address <= CPU_WRAM_END:
+		b.wram.PokeByteAt(address, value)
+	case CARTRIDGE_START <= address && b.cartridge != nil:
+		b.cartridge.PokeByteAt(address, value)
+	default:
+		// TODO: 正しいコンポーネントのメモリを書き換える
+	}
+}
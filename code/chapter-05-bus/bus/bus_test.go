@@ -0,0 +1,383 @@
+package bus
+
+import (
+	"testing"
+
+	"fc-emu/audio"
+	"fc-emu/cartridge"
+	"fc-emu/ram"
+)
+
+// MARK: WRAMのミラーリングのテスト
+func TestWRAMMirrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		address uint16
+		base    uint16 // ミラーリングを解決したアドレス
+	}{
+		{"base", 0x0000, 0x0000},
+		{"mirror 1", 0x0800, 0x0000},
+		{"mirror 2", 0x1000, 0x0000},
+		{"mirror 3", 0x1800, 0x0000},
+		{"mirror 3 end", 0x1FFF, 0x07FF},
+		{"mirror 1 middle", 0x0C34, 0x0434},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBus()
+			b.WriteByteAt(tt.address, 0x5A)
+
+			// 4つのミラーのどこから読んでも同じ値が見える
+			for mirror := uint16(0); mirror < 4; mirror++ {
+				address := tt.base + mirror*CPU_WRAM_SIZE
+				if got := b.ReadByteFrom(address); got != 0x5A {
+					t.Errorf("ReadByteFrom($%04X) = $%02X, want $5A", address, got)
+				}
+			}
+		})
+	}
+}
+
+// MARK: 2バイトの書き込みのテスト
+func TestWriteWordAt(t *testing.T) {
+	tests := []struct {
+		name    string
+		address uint16
+		value   uint16
+		want    map[uint16]uint8 // 書き込み後に読み取るアドレスと期待値
+	}{
+		{
+			name:    "within page",
+			address: 0x0010,
+			value:   0xBEEF,
+			want:    map[uint16]uint8{0x0010: 0xEF, 0x0011: 0xBE},
+		},
+		{
+			name:    "across page",
+			address: 0x00FF,
+			value:   0x1234,
+			want:    map[uint16]uint8{0x00FF: 0x34, 0x0100: 0x12},
+		},
+		{
+			// 上位バイトは$0800 (= $0000のミラー) に書き込まれる
+			name:    "across WRAM mirror",
+			address: 0x07FF,
+			value:   0xCAFE,
+			want:    map[uint16]uint8{0x07FF: 0xFE, 0x0000: 0xCA, 0x0800: 0xCA},
+		},
+		{
+			// 上位バイトはPPUレジスタ ($2000) に書き込まれ、WRAMには影響しない
+			name:    "across WRAM end",
+			address: 0x1FFF,
+			value:   0xA55A,
+			want:    map[uint16]uint8{0x07FF: 0x5A, 0x1FFF: 0x5A, 0x0000: 0x00},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBus()
+			b.WriteWordAt(tt.address, tt.value)
+
+			for address, want := range tt.want {
+				if got := b.PeekByteFrom(address); got != want {
+					t.Errorf("PeekByteFrom($%04X) = $%02X, want $%02X", address, got, want)
+				}
+			}
+		})
+	}
+}
+
+// MARK: 2バイトの書き込みで上位バイトが書き込まれるかのテスト
+func TestWriteWordAtUpperByte(t *testing.T) {
+	b := NewBus()
+	b.WriteWordAt(0x0200, 0xFF00)
+	if got := b.ReadWordFrom(0x0200); got != 0xFF00 {
+		t.Fatalf("ReadWordFrom($0200) = $%04X, want $FF00", got)
+	}
+	if got := b.ReadByteFrom(0x0201); got != 0xFF {
+		t.Fatalf("upper byte at $0201 = $%02X, want $FF", got)
+	}
+}
+
+// MARK: カートリッジが一部のbitのみを駆動する場合のオープンバスのテスト
+func TestCartridgePartialOpenBus(t *testing.T) {
+	// マッパー22 (VRC2a, PRG-RAMなし): $6000-$6FFFはbit0のみのラッチ
+	image := []uint8{'N', 'E', 'S', 0x1A, 8, 1, 0x60, 0x10, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]uint8, 8*cartridge.PRG_ROM_BANK_SIZE+cartridge.CHR_ROM_BANK_SIZE)...)
+	cart, err := cartridge.Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBus()
+	b.ConnectCartridge(cart)
+
+	tests := []struct {
+		write uint8 // ラッチへの書き込み (オープンバスの値にもなる)
+		want  uint8
+	}{
+		{0xA5, 0xA5}, // bit0はラッチ (1), bit1-7はオープンバス
+		{0xA4, 0xA4}, // bit0はラッチ (0)
+		{0x01, 0x01},
+	}
+	for _, tt := range tests {
+		b.WriteByteAt(0x6000, tt.write)
+		if got := b.PeekByteFrom(0x6000); got != tt.want {
+			t.Errorf("after writing $%02X: PeekByteFrom($6000) = $%02X, want $%02X", tt.write, got, tt.want)
+		}
+		if got := b.ReadByteFrom(0x6000); got != tt.want {
+			t.Errorf("after writing $%02X: ReadByteFrom($6000) = $%02X, want $%02X", tt.write, got, tt.want)
+		}
+	}
+
+	// 直前の読み取りでオープンバスが変わっても、bit0はラッチの値のまま
+	b.WriteByteAt(0x6000, 0x00)
+	b.WriteByteAt(0x0000, 0xFF)
+	b.ReadByteFrom(0x0000)
+	if got := b.ReadByteFrom(0x6000); got != 0xFE {
+		t.Errorf("ReadByteFrom($6000) = $%02X, want $FE", got)
+	}
+}
+
+// MARK: バンダイのEEPROMのSDA (bit4) 以外がオープンバスになるかのテスト
+func TestBandaiEEPROMOpenBus(t *testing.T) {
+	// マッパー159 (LZ93D50 + 24C01)
+	image := []uint8{'N', 'E', 'S', 0x1A, 8, 1, 0xF2, 0x90, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]uint8, 8*cartridge.PRG_ROM_BANK_SIZE+cartridge.CHR_ROM_BANK_SIZE)...)
+	cart, err := cartridge.Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBus()
+	b.ConnectCartridge(cart)
+
+	// EEPROMがSDAを解放している (1) 間は、bit4以外に直前のバスの値が残る
+	b.WriteByteAt(0x0000, 0xE3)
+	b.ReadByteFrom(0x0000)
+	if got := b.ReadByteFrom(0x6000); got != 0xF3 {
+		t.Errorf("ReadByteFrom($6000) = $%02X, want $F3", got)
+	}
+}
+
+// MARK: 拡張音源の出力がミキサーに渡されるかのテスト
+func TestExpansionAudioMixed(t *testing.T) {
+	// マッパー24 (VRC6)
+	image := []uint8{'N', 'E', 'S', 0x1A, 8, 1, 0x80, 0x10, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]uint8, 8*cartridge.PRG_ROM_BANK_SIZE+cartridge.CHR_ROM_BANK_SIZE)...)
+	cart, err := cartridge.Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBus()
+	b.ConnectCartridge(cart)
+	mixer := audio.NewMixer(cart.Region().CPUClockHz(), audio.MIXER_DEFAULT_SAMPLE_RATE)
+	b.AttachMixer(mixer)
+
+	// 矩形波1をデジタルモード (常にHigh) の音量15で有効化する
+	b.WriteByteAt(0x9000, 0x8F)
+	b.WriteByteAt(0x9002, 0x80)
+	b.Tick(1000)
+
+	samples := mixer.Samples()
+	if len(samples) == 0 {
+		t.Fatal("no samples")
+	}
+	want := float32(15 * audio.PULSE_LINEAR_GAIN)
+	if got := samples[len(samples)-1]; got != want {
+		t.Fatalf("mixed sample = %f, want %f", got, want)
+	}
+}
+
+// MARK: iNESのヘッダのフラグからテスト用のカートリッジを作る関数
+func parseTestCartridge(t *testing.T, flags6 uint8, flags7 uint8) *cartridge.Cartridge {
+	t.Helper()
+	image := []uint8{'N', 'E', 'S', 0x1A, 8, 1, flags6, flags7, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]uint8, 8*cartridge.PRG_ROM_BANK_SIZE+cartridge.CHR_ROM_BANK_SIZE)...)
+	cart, err := cartridge.Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cart
+}
+
+// MARK: PPUレジスタとコントローラのPeekがオープンバスを変えないかのテスト
+func TestPeekKeepsOpenBus(t *testing.T) {
+	b := NewBus()
+	b.WriteByteAt(0x0000, 0xA7)
+	b.ReadByteFrom(0x0000)
+
+	// Peekはオープンバスを読み取るだけで、その値を更新しない
+	for _, address := range []uint16{0x2002, 0x3FFA, JOYPAD1_REGISTER, JOYPAD2_REGISTER, 0x5000} {
+		b.PeekByteFrom(address)
+		if b.openBus != 0xA7 {
+			t.Fatalf("PeekByteFrom($%04X) changed the open bus to $%02X", address, b.openBus)
+		}
+	}
+	if got := b.PeekByteFrom(JOYPAD1_REGISTER); got != 0xA7&JOYPAD_OPEN_BUS_BITS {
+		t.Fatalf("PeekByteFrom($4016) = $%02X, want $%02X", got, 0xA7&JOYPAD_OPEN_BUS_BITS)
+	}
+
+	// Pokeもオープンバスを更新せず、アクセスとして記録されない
+	recorder := NewRecorder(nil)
+	b.AttachRecorder(recorder)
+	b.PokeByteAt(0x0010, 0x3C)
+	b.PeekByteFrom(0x0010)
+	if b.openBus != 0xA7 {
+		t.Fatalf("PokeByteAt changed the open bus to $%02X", b.openBus)
+	}
+	if recorder.Count(AccessWrite, 0x0010)+recorder.Count(AccessRead, 0x0010) != 0 {
+		t.Fatal("Peek/Poke were recorded as accesses")
+	}
+	if got := b.ReadByteFrom(0x0010); got != 0x3C {
+		t.Fatalf("ReadByteFrom($0010) after Poke = $%02X, want $3C", got)
+	}
+}
+
+// MARK: MMC5の$5204のPeekが保留中のIRQを解除しないかのテスト
+func TestPeekMMC5IRQStatus(t *testing.T) {
+	cart := parseTestCartridge(t, 0x50, 0x00)
+	b := NewBus()
+	b.ConnectCartridge(cart)
+	b.WriteByteAt(0x5203, 1)
+	b.WriteByteAt(0x5204, 0x80)
+
+	// 同じネームテーブルのアドレスを3回連続で読み取るとスキャンラインの開始として検出される
+	// 最初の検出で描画期間に入り、2回目でスキャンライン1 (比較値) に達する
+	for range 2 {
+		for range 3 {
+			cart.ReadNametable(0x2000)
+		}
+		cart.ReadNametable(0x2001)
+	}
+	if !b.IRQ() {
+		t.Fatal("no IRQ on the compare scanline")
+	}
+
+	for range 2 {
+		if got := b.PeekByteFrom(0x5204); got&0x80 == 0 {
+			t.Fatalf("PeekByteFrom($5204) = $%02X, want the pending bit", got)
+		}
+	}
+	if !b.IRQ() {
+		t.Fatal("peeking $5204 acknowledged the IRQ")
+	}
+
+	// Pokeもレジスタへの書き込みとして扱わない
+	b.PokeByteAt(0x5204, 0x00)
+	if !b.IRQ() {
+		t.Fatal("poking $5204 disabled the IRQ")
+	}
+
+	if got := b.ReadByteFrom(0x5204); got&0x80 == 0 {
+		t.Fatalf("ReadByteFrom($5204) = $%02X, want the pending bit", got)
+	}
+	if b.IRQ() {
+		t.Fatal("reading $5204 did not acknowledge the IRQ")
+	}
+}
+
+// MARK: Namco 163の$4800のPeekがアドレスを進めないかのテスト
+func TestPeekNamco163RAMPort(t *testing.T) {
+	cart := parseTestCartridge(t, 0x30, 0x10)
+	b := NewBus()
+	b.ConnectCartridge(cart)
+	b.WriteByteAt(0xF800, 0x80)
+	for _, value := range []uint8{0x11, 0x22} {
+		b.WriteByteAt(0x4800, value)
+	}
+
+	b.WriteByteAt(0xF800, 0x80)
+	for range 3 {
+		if got := b.PeekByteFrom(0x4800); got != 0x11 {
+			t.Fatalf("PeekByteFrom($4800) = $%02X, want $11", got)
+		}
+	}
+	if got := b.ReadByteFrom(0x4800); got != 0x11 {
+		t.Fatalf("ReadByteFrom($4800) = $%02X, want $11", got)
+	}
+	if got := b.ReadByteFrom(0x4800); got != 0x22 {
+		t.Fatalf("second ReadByteFrom($4800) = $%02X, want $22 after auto-increment", got)
+	}
+
+	// Pokeは書き込み保護に関係なくPRG-RAMを書き換え、$F800は変更しない
+	b.WriteByteAt(0xF800, 0x00)
+	b.PokeByteAt(0x6000, 0x5A)
+	if got := b.PeekByteFrom(0x6000); got != 0x5A {
+		t.Fatalf("PeekByteFrom($6000) after Poke = $%02X, want $5A", got)
+	}
+	b.PokeByteAt(0xF800, 0x80|0x01)
+	if got := b.PeekByteFrom(0x4800); got != 0x11 {
+		t.Fatalf("poking $F800 moved the RAM address: $4800 = $%02X", got)
+	}
+}
+
+// MARK: 何も駆動しないアドレスの読み取りがオープンバスを返すかのテスト
+func TestOpenBusReads(t *testing.T) {
+	tests := []struct {
+		name    string
+		address uint16
+		mask    uint8 // オープンバスのうち読み取りに現れるbit
+	}{
+		{"PPU register", 0x2000, 0xFF},
+		{"PPU status", 0x2002, 0xFF},
+		{"PPU register mirror", 0x3FFF, 0xFF},
+		{"joypad 1", JOYPAD1_REGISTER, 0xE0},
+		{"joypad 2", JOYPAD2_REGISTER, 0xE0},
+		{"unmapped", 0x4018, 0xFF},
+		{"no cartridge", 0x8000, 0xFF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 直前の読み取りの値
+			b := NewBus()
+			b.WriteByteAt(0x0000, 0xB6)
+			b.ReadByteFrom(0x0000)
+			if got, want := b.ReadByteFrom(tt.address), 0xB6&tt.mask; got != want {
+				t.Fatalf("after reading $B6: ReadByteFrom($%04X) = $%02X, want $%02X", tt.address, got, want)
+			}
+
+			// 直前の書き込みの値
+			b.WriteByteAt(0x0001, 0x7F)
+			if got, want := b.ReadByteFrom(tt.address), 0x7F&tt.mask; got != want {
+				t.Fatalf("after writing $7F: ReadByteFrom($%04X) = $%02X, want $%02X", tt.address, got, want)
+			}
+
+			// 読み取った値がバスに残るため、続けて読み取っても変わらない
+			if got, want := b.ReadByteFrom(tt.address), 0x7F&tt.mask; got != want {
+				t.Fatalf("second ReadByteFrom($%04X) = $%02X, want $%02X", tt.address, got, want)
+			}
+		})
+	}
+}
+
+// MARK: コントローラの読み取りで下位5bitが駆動されることがオープンバスに残るかのテスト
+func TestOpenBusAfterJoypadRead(t *testing.T) {
+	b := NewBus()
+	b.WriteByteAt(0x0000, 0xFF)
+	b.ReadByteFrom(0x0000)
+
+	// D0-D4はコントローラが駆動する (現在は0) ため、続くPPUレジスタの読み取りにも現れない
+	b.ReadByteFrom(JOYPAD1_REGISTER)
+	if got := b.ReadByteFrom(0x2002); got != 0xE0 {
+		t.Fatalf("ReadByteFrom($2002) after $4016 = $%02X, want $E0", got)
+	}
+}
+
+// MARK: 電源投入時のWRAMが初期化パターンで埋められるかのテスト
+func TestPowerOnWRAM(t *testing.T) {
+	b := NewBusWithPowerOnState(ram.PowerOnState{Pattern: ram.InitAlternating})
+	for address, want := range map[uint16]uint8{0x0000: 0x00, 0x0003: 0x00, 0x0004: 0xFF, 0x0007: 0xFF, 0x0008: 0x00, 0x07FC: 0xFF} {
+		if got := b.PeekByteFrom(address); got != want {
+			t.Errorf("PeekByteFrom($%04X) = $%02X, want $%02X", address, got, want)
+		}
+	}
+
+	// 同じシードのBusは同じ内容で起動する
+	state := ram.PowerOnState{Pattern: ram.InitRandom, Seed: 7}
+	b1, b2 := NewBusWithPowerOnState(state), NewBusWithPowerOnState(state)
+	if b1.wram != b2.wram {
+		t.Fatal("buses with the same seed started with different WRAM")
+	}
+}
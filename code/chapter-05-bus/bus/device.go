@@ -0,0 +1,37 @@
+package bus
+
+// MARK: Busに接続されるデバイスの定義
+type device interface {
+	// CPUからのアクセス (レジスタの読み書きに伴う副作用あり)
+	ReadByteFrom(address uint16) uint8
+	WriteByteAt(address uint16, value uint8)
+
+	// デバッガ等のツールからのアクセス (副作用なし)
+	PeekByteFrom(address uint16) uint8
+	PokeByteAt(address uint16, value uint8)
+}
+
+// MARK: WRAMの定義
+type wram [CPU_WRAM_SIZE]uint8
+
+var _ device = (*wram)(nil)
+
+// MARK: WRAMの読み取り
+func (w *wram) ReadByteFrom(address uint16) uint8 {
+	return w[wramAddress(address)]
+}
+
+// MARK: WRAMへの書き込み
+func (w *wram) WriteByteAt(address uint16, value uint8) {
+	w[wramAddress(address)] = value
+}
+
+// MARK: WRAMの読み取り (副作用なし)
+func (w *wram) PeekByteFrom(address uint16) uint8 {
+	return w.ReadByteFrom(address) // WRAMの読み取りには副作用がない
+}
+
+// MARK: WRAMへの書き込み (副作用なし)
+func (w *wram) PokeByteAt(address uint16, value uint8) {
+	w.WriteByteAt(address, value)
+}
@@ -0,0 +1,234 @@
+package bus
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	CPU_ADDRESS_SPACE_SIZE = 0x10000
+	HEATMAP_WIDTH          = 256 // 1ピクセル = 1アドレス (下位バイト)
+	HEATMAP_HEIGHT         = 256 // 上位バイト
+)
+
+// MARK: メモリアクセスの種類の定義
+type AccessKind uint8
+
+const (
+	AccessRead    AccessKind = iota // 読み取り
+	AccessWrite                     // 書き込み
+	AccessExecute                   // 命令フェッチ
+	accessKindCount
+)
+
+// MARK: メモリアクセスの種類の文字列表現
+func (k AccessKind) String() string {
+	switch k {
+	case AccessRead:
+		return "R"
+	case AccessWrite:
+		return "W"
+	case AccessExecute:
+		return "X"
+	default:
+		return "?"
+	}
+}
+
+// MARK: メモリアクセスの記録の定義
+type Access struct {
+	Kind    AccessKind
+	Address uint16
+	Value   uint8
+	Cycle   uint64 // アクセス時のCPUサイクル数
+	PC      uint16 // アクセスを行った命令のアドレス
+}
+
+// MARK: アドレス範囲の定義 (Start, Endを含む)
+type AddressRange struct {
+	Start uint16
+	End   uint16
+}
+
+// MARK: アドレスが範囲内かを判定するメソッド
+func (r AddressRange) Contains(address uint16) bool {
+	return r.Start <= address && address <= r.End
+}
+
+// MARK: 文字列からアドレス範囲の一覧へ変換する関数
+func ParseAddressRanges(spec string) ([]AddressRange, error) {
+	// "0000-07FF,4016" のような形式を受け付ける
+	var ranges []AddressRange
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		startText, endText, isRange := strings.Cut(field, "-")
+		start, err := parseAddress(startText)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if isRange {
+			if end, err = parseAddress(endText); err != nil {
+				return nil, err
+			}
+		}
+		if end < start {
+			return nil, fmt.Errorf("invalid address range %q: end is before start", field)
+		}
+		ranges = append(ranges, AddressRange{Start: start, End: end})
+	}
+	return ranges, nil
+}
+
+// MARK: 16進数のアドレスを解釈する関数
+func parseAddress(text string) (uint16, error) {
+	text = strings.TrimPrefix(strings.TrimSpace(text), "$")
+	value, err := strconv.ParseUint(text, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q: %w", text, err)
+	}
+	return uint16(value), nil
+}
+
+// MARK: メモリアクセス記録器の定義
+type Recorder struct {
+	ranges  []AddressRange // 記録するアドレス範囲 (空の場合は全範囲)
+	log     *bufio.Writer  // アクセスログの出力先 (nilの場合はヒートマップのみ集計)
+	heatmap [accessKindCount][CPU_ADDRESS_SPACE_SIZE]uint64
+}
+
+// MARK: メモリアクセス記録器のコンストラクタ
+func NewRecorder(log io.Writer, ranges ...AddressRange) *Recorder {
+	r := &Recorder{
+		ranges: ranges,
+	}
+	if log != nil {
+		r.log = bufio.NewWriter(log)
+	}
+	return r
+}
+
+// MARK: アドレスが記録対象かを判定するメソッド
+func (r *Recorder) accepts(address uint16) bool {
+	if len(r.ranges) == 0 {
+		return true
+	}
+	for _, rng := range r.ranges {
+		if rng.Contains(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// MARK: メモリアクセスを記録するメソッド
+func (r *Recorder) Record(access Access) {
+	if !r.accepts(access.Address) {
+		return
+	}
+
+	r.heatmap[access.Kind][access.Address]++
+
+	if r.log != nil {
+		fmt.Fprintf(
+			r.log,
+			"%d %04X %s %04X %02X\n",
+			access.Cycle,
+			access.PC,
+			access.Kind,
+			access.Address,
+			access.Value,
+		)
+	}
+}
+
+// MARK: バッファされたアクセスログを書き出すメソッド
+func (r *Recorder) Flush() error {
+	if r.log == nil {
+		return nil
+	}
+	return r.log.Flush()
+}
+
+// MARK: アドレスごとのアクセス回数を返すメソッド
+func (r *Recorder) Count(kind AccessKind, address uint16) uint64 {
+	return r.heatmap[kind][address]
+}
+
+// MARK: ヒートマップをCSVで書き出すメソッド
+func (r *Recorder) WriteHeatmapCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"address", "reads", "writes", "executes"}); err != nil {
+		return err
+	}
+
+	for address := range CPU_ADDRESS_SPACE_SIZE {
+		reads := r.heatmap[AccessRead][address]
+		writes := r.heatmap[AccessWrite][address]
+		executes := r.heatmap[AccessExecute][address]
+		if reads == 0 && writes == 0 && executes == 0 {
+			continue // 一度もアクセスされていないアドレスは省略
+		}
+
+		record := []string{
+			fmt.Sprintf("%04X", address),
+			strconv.FormatUint(reads, 10),
+			strconv.FormatUint(writes, 10),
+			strconv.FormatUint(executes, 10),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// MARK: ヒートマップをPNGで書き出すメソッド
+func (r *Recorder) WriteHeatmapPNG(w io.Writer) error {
+	/*
+		256x256ピクセルの画像で、(x, y) = (アドレスの下位バイト, 上位バイト)
+		R: 書き込み回数 / G: 読み取り回数 / B: 命令フェッチ回数
+		アクセス回数の差が大きいため、各チャンネルは対数スケールで正規化する
+	*/
+	var peak [accessKindCount]uint64
+	for kind := range accessKindCount {
+		for _, count := range r.heatmap[kind] {
+			peak[kind] = max(peak[kind], count)
+		}
+	}
+
+	intensity := func(kind AccessKind, address int) uint8 {
+		count := r.heatmap[kind][address]
+		if count == 0 {
+			return 0
+		}
+		scale := math.Log1p(float64(count)) / math.Log1p(float64(peak[kind]))
+		return uint8(0x20 + scale*(0xFF-0x20)) // 1回でもアクセスがあれば見えるように下駄を履かせる
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, HEATMAP_WIDTH, HEATMAP_HEIGHT))
+	for address := range CPU_ADDRESS_SPACE_SIZE {
+		img.SetRGBA(address%HEATMAP_WIDTH, address/HEATMAP_WIDTH, color.RGBA{
+			R: intensity(AccessWrite, address),
+			G: intensity(AccessRead, address),
+			B: intensity(AccessExecute, address),
+			A: 0xFF,
+		})
+	}
+
+	return png.Encode(w, img)
+}
@@ -0,0 +1,159 @@
+package bus
+
+import (
+	"bytes"
+	"encoding/csv"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// MARK: テスト用のアクセスを記録したBus
+func recordTestAccesses(t *testing.T, ranges ...AddressRange) (*Recorder, *bytes.Buffer) {
+	t.Helper()
+	var log bytes.Buffer
+	recorder := NewRecorder(&log, ranges...)
+
+	b := NewBus()
+	b.AttachRecorder(recorder)
+	b.WriteByteAt(0x0010, 0x42)
+	b.ReadByteFrom(0x0010)
+	b.ReadByteFrom(0x0010)
+	b.FetchOpcodeFrom(0x0100)
+	b.ReadByteFrom(0x4016)
+	if err := recorder.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return recorder, &log
+}
+
+// MARK: アクセスログの書式と回数のテスト
+func TestRecorderLog(t *testing.T) {
+	recorder, log := recordTestAccesses(t)
+
+	// サイクル数, PC, 種類, アドレス, 値
+	want := []string{
+		"0 0000 W 0010 42",
+		"0 0000 R 0010 42",
+		"0 0000 R 0010 42",
+		"0 0100 X 0100 00",
+		"0 0100 R 4016 00",
+	}
+	if got := strings.Split(strings.TrimSpace(log.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("log =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	counts := []struct {
+		kind    AccessKind
+		address uint16
+		want    uint64
+	}{
+		{AccessWrite, 0x0010, 1},
+		{AccessRead, 0x0010, 2},
+		{AccessExecute, 0x0100, 1},
+		{AccessRead, 0x0100, 0},
+		{AccessRead, 0x4016, 1},
+	}
+	for _, c := range counts {
+		if got := recorder.Count(c.kind, c.address); got != c.want {
+			t.Errorf("Count(%s, $%04X) = %d, want %d", c.kind, c.address, got, c.want)
+		}
+	}
+}
+
+// MARK: アドレス範囲による絞り込みのテスト
+func TestRecorderRanges(t *testing.T) {
+	ranges, err := ParseAddressRanges("0000-00FF, $4016")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder, log := recordTestAccesses(t, ranges...)
+
+	if lines := strings.Count(log.String(), "\n"); lines != 4 {
+		t.Fatalf("log has %d lines, want 4 (the fetch at $0100 is outside the ranges)", lines)
+	}
+	if got := recorder.Count(AccessExecute, 0x0100); got != 0 {
+		t.Fatalf("fetch outside the ranges was counted %d times", got)
+	}
+
+	for _, spec := range []string{"10-0F", "GGGG", "10000"} {
+		if _, err := ParseAddressRanges(spec); err == nil {
+			t.Errorf("ParseAddressRanges(%q) succeeded", spec)
+		}
+	}
+}
+
+// MARK: ヒートマップのCSVのテスト
+func TestRecorderHeatmapCSV(t *testing.T) {
+	recorder, _ := recordTestAccesses(t)
+
+	var out bytes.Buffer
+	if err := recorder.WriteHeatmapCSV(&out); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// ヘッダとアクセスされたアドレスの行のみ (アドレス順)
+	want := [][]string{
+		{"address", "reads", "writes", "executes"},
+		{"0010", "2", "1", "0"},
+		{"0100", "0", "0", "1"},
+		{"4016", "1", "0", "0"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("CSV has %d rows, want %d: %v", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if strings.Join(rows[i], ",") != strings.Join(want[i], ",") {
+			t.Errorf("row %d = %v, want %v", i, rows[i], want[i])
+		}
+	}
+}
+
+// MARK: ヒートマップのPNGのテスト
+func TestRecorderHeatmapPNG(t *testing.T) {
+	recorder, _ := recordTestAccesses(t)
+
+	var out bytes.Buffer
+	if err := recorder.WriteHeatmapPNG(&out); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size.X != HEATMAP_WIDTH || size.Y != HEATMAP_HEIGHT {
+		t.Fatalf("heatmap is %dx%d, want %dx%d", size.X, size.Y, HEATMAP_WIDTH, HEATMAP_HEIGHT)
+	}
+
+	// アクセスされた3アドレスのピクセルのみが点灯する (R: 書き込み, G: 読み取り, B: 命令フェッチ)
+	var lit int
+	for y := range HEATMAP_HEIGHT {
+		for x := range HEATMAP_WIDTH {
+			if r, g, b, _ := img.At(x, y).RGBA(); r|g|b != 0 {
+				lit++
+			}
+		}
+	}
+	if lit != 3 {
+		t.Fatalf("%d pixels lit, want 3", lit)
+	}
+
+	channels := []struct {
+		address uint16
+		r, g, b bool
+	}{
+		{0x0010, true, true, false},
+		{0x0100, false, false, true},
+		{0x4016, false, true, false},
+	}
+	for _, c := range channels {
+		r, g, b, _ := img.At(int(c.address%HEATMAP_WIDTH), int(c.address/HEATMAP_WIDTH)).RGBA()
+		if (r != 0) != c.r || (g != 0) != c.g || (b != 0) != c.b {
+			t.Errorf("$%04X: RGB = (%d, %d, %d), want lit (%v, %v, %v)", c.address, r>>8, g>>8, b>>8, c.r, c.g, c.b)
+		}
+	}
+}
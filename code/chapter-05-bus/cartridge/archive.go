@@ -0,0 +1,114 @@
+package cartridge
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+var (
+	GZIP_MAGIC = []uint8{0x1F, 0x8B}
+	ZIP_MAGIC  = []uint8{'P', 'K', 0x03, 0x04}
+)
+
+var (
+	ErrNoROMInArchive        = errors.New("archive contains no ROM file")
+	ErrMultipleROMsInArchive = errors.New("archive contains more than one ROM file")
+	ErrArchiveEntryNotFound  = errors.New("archive has no entry with the requested name")
+)
+
+// アーカイブ内でROMとみなす拡張子 (Parseが読み込める形式のみ)
+var romExtensions = []string{".nes", ".unf", ".unif"}
+
+// MARK: 圧縮されたROMを展開する関数
+func extractROM(data []uint8, entry string) ([]uint8, error) {
+	// 拡張子ではなく先頭のマジックナンバーで判別する
+	switch {
+	case bytes.HasPrefix(data, GZIP_MAGIC):
+		return extractGzip(data)
+	case bytes.HasPrefix(data, ZIP_MAGIC):
+		return extractZip(data, entry)
+	default:
+		return data, nil
+	}
+}
+
+// MARK: gzipを展開する関数
+func extractGzip(data []uint8) ([]uint8, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	defer reader.Close()
+
+	rom, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("gzip: %w", err)
+	}
+	return rom, nil
+}
+
+// MARK: zipからROMを1つ取り出す関数
+func extractZip(data []uint8, entry string) ([]uint8, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("zip: %w", err)
+	}
+
+	var candidates []*zip.File
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+
+		if entry != "" {
+			// 名前が指定された場合は拡張子に関わらずその名前のエントリを使う
+			if file.Name == entry || path.Base(file.Name) == entry {
+				candidates = append(candidates, file)
+			}
+		} else if isROMFileName(file.Name) {
+			candidates = append(candidates, file)
+		}
+	}
+
+	switch {
+	case len(candidates) == 0 && entry != "":
+		return nil, fmt.Errorf("%w: %q", ErrArchiveEntryNotFound, entry)
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("%w (looked for %s)", ErrNoROMInArchive, strings.Join(romExtensions, ", "))
+	case len(candidates) > 1:
+		names := make([]string, len(candidates))
+		for i, file := range candidates {
+			names[i] = file.Name
+		}
+		return nil, fmt.Errorf("%w: %s (choose one by name)", ErrMultipleROMsInArchive, strings.Join(names, ", "))
+	}
+
+	reader, err := candidates[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("zip: %s: %w", candidates[0].Name, err)
+	}
+	defer reader.Close()
+
+	rom, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("zip: %s: %w", candidates[0].Name, err)
+	}
+	return rom, nil
+}
+
+// MARK: ROMファイルの名前かを判定する関数
+func isROMFileName(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, romExt := range romExtensions {
+		if ext == romExt {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package cartridge
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+)
+
+// MARK: テスト用のzipアーカイブ
+func testZip(t *testing.T, files map[string]string) []uint8 {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, body := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]uint8(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// MARK: gzipの展開のテスト
+func TestExtractGzip(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]uint8("NES\x1Arom"))
+	w.Close()
+
+	rom, err := extractROM(buf.Bytes(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rom) != "NES\x1Arom" {
+		t.Fatalf("extracted %q", rom)
+	}
+
+	// 圧縮されていないデータはそのまま返す
+	if rom, err := extractROM([]uint8("NES\x1Araw"), ""); err != nil || string(rom) != "NES\x1Araw" {
+		t.Fatalf("plain data = %q, %v", rom, err)
+	}
+}
+
+// MARK: zipからのROMの選択のテスト
+func TestExtractZip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		entry string
+		want  string
+		err   error
+	}{
+		{"single ROM", map[string]string{"game.nes": "rom", "readme.txt": "text"}, "", "rom", nil},
+		{"UNIF", map[string]string{"dir/game.UNF": "unif"}, "", "unif", nil},
+		{"no ROM", map[string]string{"readme.txt": "text"}, "", "", ErrNoROMInArchive},
+		{"unsupported formats only", map[string]string{"disk.fds": "fds", "music.nsf": "nsf"}, "", "", ErrNoROMInArchive},
+		{"multiple ROMs", map[string]string{"a.nes": "a", "b.nes": "b"}, "", "", ErrMultipleROMsInArchive},
+		{"named entry", map[string]string{"a.nes": "a", "b.nes": "b"}, "b.nes", "b", nil},
+		{"named entry by base name", map[string]string{"roms/a.nes": "a", "roms/b.nes": "b"}, "b.nes", "b", nil},
+		{"named entry with other extension", map[string]string{"a.nes": "a", "b.bin": "b"}, "b.bin", "b", nil},
+		{"named entry missing", map[string]string{"a.nes": "a"}, "c.nes", "", ErrArchiveEntryNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rom, err := extractROM(testZip(t, tt.files), tt.entry)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(rom) != tt.want {
+				t.Fatalf("extracted %q, want %q", rom, tt.want)
+			}
+		})
+	}
+}
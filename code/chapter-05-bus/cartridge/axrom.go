@@ -0,0 +1,37 @@
+package cartridge
+
+// MARK: AxROM (マッパー7) の定義
+type axrom struct {
+	baseMapper
+}
+
+// MARK: AxROMのコンストラクタ
+func newAxROM(c *Cartridge) *axrom {
+	/*
+		$8000-$FFFF: 32kB 切り替え可能なバンク
+		$8000-$FFFFへの書き込み
+			bit0-2: PRG-ROMのバンク
+			bit4:   1画面ミラーリングで使用するVRAMの選択
+	*/
+	m := &axrom{
+		baseMapper: newBaseMapper(c),
+	}
+	m.mirroring = MirroringSingleScreenLower
+	return m
+}
+
+// MARK: CPUからの書き込み
+func (m *axrom) WritePRG(address uint16, value uint8) {
+	if address < CPU_PRG_ROM_START {
+		m.baseMapper.WritePRG(address, value)
+		return
+	}
+	value = m.busConflict(address, value)
+
+	m.setPRGBank32k(int(value & 0x07))
+	if value&0x10 == 0 {
+		m.mirroring = MirroringSingleScreenLower
+	} else {
+		m.mirroring = MirroringSingleScreenUpper
+	}
+}
@@ -0,0 +1,220 @@
+package cartridge
+
+const (
+	BANDAI_SUBMAPPER_FCG     = 4 // FCG-1/2 (レジスタは$6000-$7FFF, カウンタへ直接書き込む)
+	BANDAI_SUBMAPPER_LZ93D50 = 5 // LZ93D50 (レジスタは$8000-$FFFF, ラッチ経由でカウンタへ書き込む)
+
+	BANDAI_EEPROM_SDA = 0x10 // $6000-$7FFFの読み取りのbit4がEEPROMのSDA
+
+	BANDAI_153_OUTER_BANK = 16 // マッパー153はCHRレジスタのbit0で256kBの外側のバンクを選択する
+)
+
+// MARK: バンダイ FCG / LZ93D50 (マッパー16, 153, 159) の定義
+type bandai struct {
+	baseMapper
+
+	lowRegisters  bool // $6000-$7FFFのレジスタ (FCG-1/2)
+	highRegisters bool // $8000-$FFFFのレジスタ (LZ93D50)
+	sram          bool // マッパー153: PRG-RAMとCHR-RAMを持ち、CHRレジスタがPRGの外側のバンクになる
+
+	chr [8]uint8 // $x000-$x007
+	prg uint8    // $x008
+
+	irqEnabled bool
+	irqLatch   uint16
+	irqCounter uint16
+	irqPending bool
+
+	eeprom         *i2cEEPROM // nilの場合はEEPROMを持たない
+	eepromReadMode bool       // $x00D bit7
+}
+
+// MARK: バンダイ FCG / LZ93D50のコンストラクタ
+func newBandai(c *Cartridge) *bandai {
+	/*
+		$8000-$BFFF: 16kB 切り替え可能なバンク
+		$C000-$FFFF: 16kB 最後のバンクに固定
+		CHRは1kB単位で8つ
+		マッパー16 サブマッパー4: FCG-1/2, サブマッパー5: LZ93D50 (24C02を持つ場合がある)
+		マッパー153: LZ93D50 + 8kB PRG-RAM, マッパー159: LZ93D50 + 24C01
+	*/
+	h := c.Header
+	m := &bandai{
+		baseMapper:    newBaseMapper(c),
+		lowRegisters:  h.Mapper == 16 && h.Submapper != BANDAI_SUBMAPPER_LZ93D50,
+		highRegisters: h.Mapper != 16 || h.Submapper != BANDAI_SUBMAPPER_FCG,
+		sram:          h.Mapper == 153,
+	}
+
+	// ヘッダのPRG-NVRAMはEEPROMを表す (EEPROMの内容はマッパーが保持する)
+	switch {
+	case h.Mapper == 159:
+		m.eeprom = newI2CEEPROM(c, EEPROM_24C01_SIZE)
+	case h.Mapper == 16 && h.Submapper != BANDAI_SUBMAPPER_FCG && h.PRGNVRAMSize > 0:
+		size := EEPROM_24C02_SIZE
+		if h.PRGNVRAMSize == EEPROM_24C01_SIZE {
+			size = EEPROM_24C01_SIZE
+		}
+		m.eeprom = newI2CEEPROM(c, size)
+	}
+
+	m.prgRAMEnabled = !m.sram // マッパー153は$x00D bit5で有効化する
+	m.updateBanks()
+	return m
+}
+
+// MARK: CPUからの読み取り
+func (m *bandai) ReadPRG(address uint16) (uint8, bool) {
+	return m.PeekPRG(address)
+}
+
+// MARK: CPUからの読み取り (副作用なし)
+func (m *bandai) PeekPRG(address uint16) (uint8, bool) {
+	if m.hasEEPROMPort(address) {
+		if m.eeprom.read() {
+			return BANDAI_EEPROM_SDA, true
+		}
+		return 0x00, true
+	}
+	if CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END && !m.sram {
+		return 0x00, false
+	}
+	return m.baseMapper.PeekPRG(address)
+}
+
+// MARK: 読み取りで駆動されるbitのマスク
+func (m *bandai) DrivenBits(address uint16) uint8 {
+	// EEPROMはbit4 (SDA) のみを駆動し、その他のbitはオープンバスになる
+	if m.hasEEPROMPort(address) {
+		return BANDAI_EEPROM_SDA
+	}
+	return DRIVEN_ALL_BITS
+}
+
+// MARK: EEPROMのSDAを読み取るアドレスかを判定するメソッド
+func (m *bandai) hasEEPROMPort(address uint16) bool {
+	return m.eeprom != nil && CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END
+}
+
+// MARK: CPUからの書き込み
+func (m *bandai) WritePRG(address uint16, value uint8) {
+	switch {
+	case CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END:
+		if m.sram {
+			m.writePRGRAM(address, value)
+		} else if m.lowRegisters {
+			m.writeRegister(address, value)
+		}
+	case CPU_PRG_ROM_START <= address && m.highRegisters:
+		m.writeRegister(address, value)
+	}
+}
+
+// MARK: レジスタへの書き込み
+func (m *bandai) writeRegister(address uint16, value uint8) {
+	switch register := address & 0x000F; {
+	case register <= 0x7:
+		m.chr[register] = value
+	case register == 0x8:
+		m.prg = value & 0x0F
+	case register == 0x9:
+		switch value & 0x03 {
+		case 0:
+			m.mirroring = MirroringVertical
+		case 1:
+			m.mirroring = MirroringHorizontal
+		case 2:
+			m.mirroring = MirroringSingleScreenLower
+		case 3:
+			m.mirroring = MirroringSingleScreenUpper
+		}
+		return
+	case register == 0xA:
+		// LZ93D50は有効化と同時にラッチの値をカウンタへコピーする
+		m.irqEnabled = value&0x01 != 0
+		m.irqPending = false
+		if m.highRegisters {
+			m.irqCounter = m.irqLatch
+		}
+		return
+	case register == 0xB, register == 0xC:
+		shift := (register - 0xB) * 8
+		m.irqLatch = m.irqLatch&^(0xFF<<shift) | uint16(value)<<shift
+		if m.lowRegisters {
+			m.irqCounter = m.irqCounter&^(0xFF<<shift) | uint16(value)<<shift
+		}
+		return
+	case register == 0xD:
+		m.writeControl(value)
+		return
+	default:
+		return
+	}
+	m.updateBanks()
+}
+
+// MARK: $x00Dへの書き込み (EEPROMの制御 / マッパー153のPRG-RAMの有効化)
+func (m *bandai) writeControl(value uint8) {
+	if m.sram {
+		m.prgRAMEnabled = value&0x20 != 0
+		return
+	}
+	if m.eeprom == nil {
+		return
+	}
+	// bit5: SCL, bit6: SDA, bit7: 読み取りモード (マスタがSDAを解放する)
+	m.eepromReadMode = value&0x80 != 0
+	m.eeprom.write(value&0x20 != 0, value&0x40 != 0 || m.eepromReadMode)
+}
+
+// MARK: バンクの更新
+func (m *bandai) updateBanks() {
+	bank := int(m.prg)
+	if m.sram {
+		// CHR-RAMのためCHRレジスタのbit0はPRGの外側のバンクとして使われる
+		var outer int
+		for _, chr := range m.chr {
+			outer |= int(chr & 0x01)
+		}
+		bank |= outer * BANDAI_153_OUTER_BANK
+		m.setPRGBank16k(0, bank)
+		m.setPRGBank16k(1, outer*BANDAI_153_OUTER_BANK+BANDAI_153_OUTER_BANK-1)
+		return
+	}
+	m.setPRGBank16k(0, bank)
+	m.setPRGBank16k(1, -1)
+
+	for slot, bank := range m.chr {
+		m.setCHRBank1k(slot, int(bank))
+	}
+}
+
+// MARK: EEPROMの内容
+func (m *bandai) BatteryData() []uint8 {
+	if m.eeprom == nil {
+		return nil
+	}
+	return m.eeprom.data
+}
+
+// MARK: EEPROMがPRG-NVRAMの代わりとなるかを返すメソッド
+func (m *bandai) ReplacesPRGNVRAM() bool {
+	return m.eeprom != nil
+}
+
+// MARK: IRQの取得
+func (m *bandai) IRQ() bool {
+	return m.irqPending
+}
+
+// MARK: CPUサイクルの通知
+func (m *bandai) Tick() {
+	// 16bitのカウンタはCPUサイクルごとに減算され、0になるとIRQを発生させる
+	if !m.irqEnabled {
+		return
+	}
+	m.irqCounter--
+	if m.irqCounter == 0 {
+		m.irqPending = true
+	}
+}
@@ -0,0 +1,337 @@
+package cartridge
+
+import (
+	"bytes"
+	"fmt"
+
+	"fc-emu/ram"
+)
+
+const (
+	PRG_ROM_BANK_SIZE = 16 * 1024 // 16kB
+	CHR_ROM_BANK_SIZE = 8 * 1024  // 8kB
+	TRAINER_SIZE      = 512
+
+	CPU_PRG_RAM_START = 0x6000
+	CPU_PRG_RAM_END   = 0x7FFF
+	CPU_TRAINER_START = 0x7000
+	CPU_TRAINER_END   = CPU_TRAINER_START + TRAINER_SIZE - 1
+	CPU_PRG_ROM_START = 0x8000
+	CPU_PRG_ROM_END   = 0xFFFF
+
+	DRIVEN_ALL_BITS = 0xFF // データバスのすべてのbitを駆動する
+	DRIVEN_NO_BITS  = 0x00 // 応答しない (オープンバス)
+)
+
+// MARK: ネームテーブルのミラーリングの定義
+type Mirroring uint8
+
+const (
+	MirroringHorizontal        Mirroring = iota // 水平ミラー (縦スクロール用)
+	MirroringVertical                           // 垂直ミラー (横スクロール用)
+	MirroringFourScreen                         // 4画面 (カートリッジ側にVRAMを持つ)
+	MirroringSingleScreenLower                  // 1画面 ($2000側のVRAMのみ)
+	MirroringSingleScreenUpper                  // 1画面 ($2400側のVRAMのみ)
+)
+
+// MARK: ミラーリングの文字列表現
+func (m Mirroring) String() string {
+	switch m {
+	case MirroringHorizontal:
+		return "horizontal"
+	case MirroringVertical:
+		return "vertical"
+	case MirroringFourScreen:
+		return "four-screen"
+	case MirroringSingleScreenLower:
+		return "single-screen (lower)"
+	case MirroringSingleScreenUpper:
+		return "single-screen (upper)"
+	default:
+		return fmt.Sprintf("Mirroring(%d)", uint8(m))
+	}
+}
+
+// MARK: ネームテーブル ($2000/$2400/$2800/$2C00) に割り当てるページを返すメソッド
+func (m Mirroring) Page(table int) int {
+	// 0, 1 は本体のCIRAM, 4画面の 2, 3 はカートリッジ側のVRAM
+	switch m {
+	case MirroringHorizontal:
+		return table >> 1 & 0x01
+	case MirroringVertical:
+		return table & 0x01
+	case MirroringSingleScreenUpper:
+		return 1
+	case MirroringFourScreen:
+		return table & 0x03
+	default:
+		return 0
+	}
+}
+
+// MARK: カートリッジの定義
+type Cartridge struct {
+	Header  Header
+	PRGROM  []uint8
+	CHRROM  []uint8 // 空の場合はCHR-RAMを使用する
+	Trainer []uint8 // $7000-$71FFに配置される (存在しない場合は空)
+
+	PRGRAM []uint8 // 先頭 Header.PRGNVRAMSize バイトがバッテリーバックアップされる
+	CHRRAM []uint8 // 先頭 Header.CHRNVRAMSize バイトがバッテリーバックアップされる
+
+	mapper Mapper
+
+	ciram       []uint8 // 本体のネームテーブル用VRAM (PPUが接続する, nilの場合は未接続)
+	smoothAudio bool    // 時分割多重の拡張音源 (Namco 163) を平均して出力する
+
+	savePath       string // セーブファイルのパス (空の場合は保存しない)
+	saveLoaded     bool   // セーブファイルから復元済みか
+	saveDirty      bool   // 最後の保存以降にバッテリーバックアップされたメモリが変更されたか
+	autosaveCycles uint64 // 最後の定期保存判定からのCPUサイクル数
+	autosaveErr    error  // 定期保存で発生したエラー
+}
+
+// MARK: カートリッジのコンストラクタ
+func newCartridge(header Header, prgROM []uint8, chrROM []uint8, trainer []uint8) (*Cartridge, error) {
+	c := &Cartridge{
+		PRGROM:  prgROM,
+		CHRROM:  chrROM,
+		Trainer: trainer,
+	}
+	if err := c.applyHeader(header); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// MARK: ヘッダに従ってRAMとマッパーを作り直すメソッド
+func (c *Cartridge) applyHeader(header Header) error {
+	// マッパーはカートリッジへのポインタを保持するため、構造体をコピーせずにその場で更新する
+	old := *c
+	c.Header = header
+	c.PRGRAM = make([]uint8, header.PRGNVRAMSize+header.PRGRAMSize)
+	c.CHRRAM = make([]uint8, header.CHRNVRAMSize+header.CHRRAMSize)
+
+	// ヘッダのマッパー番号に対応するマッパーを接続する
+	mapper, err := newMapper(c)
+	if err != nil {
+		*c = old
+		return err
+	}
+	c.mapper = mapper
+	return nil
+}
+
+// MARK: 実行に使用する地域を返すメソッド
+func (c *Cartridge) Region() Timing {
+	return c.Header.Timing.Region()
+}
+
+// MARK: 電源投入時の処理
+func (c *Cartridge) PowerOn(init *ram.Initializer) {
+	// セーブファイルから復元したメモリは初期化しない
+	if c.saveLoaded {
+		init.Fill(c.PRGRAM[c.Header.PRGNVRAMSize:])
+	} else {
+		init.Fill(c.PRGRAM)
+	}
+
+	// トレーナーはPRG-RAMの$7000に転送されている状態で起動する
+	if c.trainerInPRGRAM() {
+		copy(c.PRGRAM[CPU_TRAINER_START-CPU_PRG_RAM_START:], c.Trainer)
+	}
+}
+
+// MARK: トレーナーをPRG-RAMに配置できるかを判定するメソッド
+func (c *Cartridge) trainerInPRGRAM() bool {
+	return len(c.Trainer) > 0 && len(c.PRGRAM) >= CPU_TRAINER_END-CPU_PRG_RAM_START+1
+}
+
+// MARK: PRG-RAMに配置できなかったトレーナーのアドレスかを判定するメソッド
+func (c *Cartridge) isTrainerAddress(address uint16) bool {
+	return len(c.Trainer) > 0 && !c.trainerInPRGRAM() &&
+		CPU_TRAINER_START <= address && address <= CPU_TRAINER_END
+}
+
+// MARK: バイト列からカートリッジを生成する関数
+func Parse(data []uint8) (*Cartridge, error) {
+	// ファイル先頭のマジックナンバーで形式を判別する
+	if bytes.HasPrefix(data, UNIF_MAGIC) {
+		return parseUNIF(data)
+	}
+	return parseINES(data)
+}
+
+// MARK: PRG-RAMへの書き込み
+func (c *Cartridge) writePRGRAM(index int, value uint8) {
+	if index < c.Header.PRGNVRAMSize && c.PRGRAM[index] != value {
+		c.markSaveDirty()
+	}
+	c.PRGRAM[index] = value
+}
+
+// MARK: CHR-RAMへの書き込み
+func (c *Cartridge) writeCHRRAM(index int, value uint8) {
+	if index < c.Header.CHRNVRAMSize && c.CHRRAM[index] != value {
+		c.markSaveDirty()
+	}
+	c.CHRRAM[index] = value
+}
+
+// MARK: CPUからの読み取り ($4020-$FFFF)
+func (c *Cartridge) ReadByteFrom(address uint16) (uint8, uint8) {
+	if c.isTrainerAddress(address) {
+		return c.Trainer[address-CPU_TRAINER_START], DRIVEN_ALL_BITS
+	}
+	value, ok := c.mapper.ReadPRG(address)
+	return value, c.drivenBits(address, ok)
+}
+
+// MARK: 読み取りで駆動されるbitのマスクを返すメソッド
+func (c *Cartridge) drivenBits(address uint16, ok bool) uint8 {
+	if !ok {
+		return DRIVEN_NO_BITS
+	}
+	if m, ok := c.mapper.(partialBusMapper); ok {
+		return m.DrivenBits(address)
+	}
+	return DRIVEN_ALL_BITS
+}
+
+// MARK: CPUからの書き込み ($4020-$FFFF)
+func (c *Cartridge) WriteByteAt(address uint16, value uint8) {
+	c.mapper.WritePRG(address, value)
+}
+
+// MARK: CPUからの読み取り (副作用なし)
+func (c *Cartridge) PeekByteFrom(address uint16) (uint8, uint8) {
+	/*
+		カートリッジ CPU メモリマップ
+		(範囲 / サイズ / コンポーネント)
+
+		$4020-$5FFF 0x1FE0 拡張領域 (マッパーによってはレジスタや拡張RAM)
+		$6000-$7FFF 0x2000 PRG-RAM (バッテリーバックアップされている場合あり)
+		$7000-$71FF 0x0200 トレーナー (PRG-RAMがない場合は読み取り専用)
+		$8000-$FFFF 0x8000 PRG-ROM (マッパーによってバンク切り替え)
+
+		2つ目の戻り値は駆動されたbitのマスクで、駆動されないbitはBus側でオープンバスとして扱う
+	*/
+	if c.isTrainerAddress(address) {
+		return c.Trainer[address-CPU_TRAINER_START], DRIVEN_ALL_BITS
+	}
+	value, ok := c.mapper.PeekPRG(address)
+	return value, c.drivenBits(address, ok)
+}
+
+// MARK: CPUからの書き込み (副作用なし)
+func (c *Cartridge) PokeByteAt(address uint16, value uint8) {
+	if c.isTrainerAddress(address) {
+		c.Trainer[address-CPU_TRAINER_START] = value
+		return
+	}
+	c.mapper.PokePRG(address, value)
+}
+
+// MARK: PPUからの読み取り ($0000-$1FFF)
+func (c *Cartridge) ReadCHR(address uint16) uint8 {
+	return c.mapper.ReadCHR(address)
+}
+
+// MARK: PPUからの書き込み ($0000-$1FFF)
+func (c *Cartridge) WriteCHR(address uint16, value uint8) {
+	c.mapper.WriteCHR(address, value)
+}
+
+// MARK: PPUからの読み取り (副作用なし)
+func (c *Cartridge) PeekCHR(address uint16) uint8 {
+	return c.mapper.PeekCHR(address)
+}
+
+// MARK: 現在のネームテーブルのミラーリングを返すメソッド
+func (c *Cartridge) Mirroring() Mirroring {
+	return c.mapper.Mirroring()
+}
+
+// MARK: ネームテーブルに割り当てるページを返すメソッド
+func (c *Cartridge) NametablePage(table int) int {
+	// ネームテーブルを個別に切り替えられるマッパー (TxSROM等) はその割り当てを優先する
+	if m, ok := c.mapper.(nametableMapper); ok {
+		return m.NametablePage(table)
+	}
+	return c.Mirroring().Page(table)
+}
+
+// MARK: 本体のネームテーブル用VRAM (CIRAM) を接続するメソッド (PPUから呼び出す)
+func (c *Cartridge) ConnectCIRAM(ciram []uint8) {
+	// Namco 163等はCIRAMをパターンテーブルとしても使うことができる
+	c.ciram = ciram
+}
+
+// MARK: PPUからのネームテーブルの読み取り ($2000-$2FFF)
+func (c *Cartridge) ReadNametable(address uint16) (uint8, bool) {
+	// false の場合はNametablePageで選ばれた本体のCIRAMを読み取る
+	if m, ok := c.mapper.(nametableDevice); ok {
+		return m.ReadNametable(address)
+	}
+	return 0x00, false
+}
+
+// MARK: PPUからのネームテーブルへの書き込み ($2000-$2FFF)
+func (c *Cartridge) WriteNametable(address uint16, value uint8) bool {
+	if m, ok := c.mapper.(nametableDevice); ok {
+		return m.WriteNametable(address, value)
+	}
+	return false
+}
+
+// MARK: PPUからのネームテーブルの読み取り (副作用なし)
+func (c *Cartridge) PeekNametable(address uint16) (uint8, bool) {
+	if m, ok := c.mapper.(nametableDevice); ok {
+		return m.PeekNametable(address)
+	}
+	return 0x00, false
+}
+
+// MARK: PPUレジスタへの書き込みの通知 (Busから呼び出す)
+func (c *Cartridge) ObservePPURegister(address uint16, value uint8) {
+	// MMC5等はPPUの設定 (スプライトサイズ / 描画の有効化) を監視する
+	if m, ok := c.mapper.(ppuRegisterObserver); ok {
+		m.ObservePPURegister(address, value)
+	}
+}
+
+// MARK: 拡張音源の出力を返すメソッド
+func (c *Cartridge) AudioOutput() float32 {
+	/*
+		拡張音源 (VRC6, VRC7, MMC5, 5B, N163) のミキシングの接続点
+		値はAPUの出力を線形近似した場合と同じスケール (audio.PULSE_LINEAR_GAIN 基準) で、
+		Busが毎サイクル audio.Mixer に渡し、APUの出力に加算される
+	*/
+	if m, ok := c.mapper.(audioMapper); ok {
+		return m.AudioOutput()
+	}
+	return 0
+}
+
+// MARK: IRQの状態を返すメソッド
+func (c *Cartridge) IRQ() bool {
+	return c.mapper.IRQ()
+}
+
+// MARK: CPUサイクルを進めるメソッド
+func (c *Cartridge) Tick(cycles uint64) {
+	for range cycles {
+		c.mapper.Tick()
+	}
+	c.autosave(cycles)
+}
+
+// MARK: スキャンラインの通知 (PPUから呼び出す)
+func (c *Cartridge) Scanline() {
+	c.mapper.Scanline()
+}
+
+// MARK: PPUのアドレスの通知 (PPUから呼び出す)
+func (c *Cartridge) PPUAddress(address uint16) {
+	c.mapper.PPUAddress(address)
+}
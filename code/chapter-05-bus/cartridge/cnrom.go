@@ -0,0 +1,28 @@
+package cartridge
+
+// MARK: CNROM (マッパー3) の定義
+type cnrom struct {
+	baseMapper
+}
+
+// MARK: CNROMのコンストラクタ
+func newCNROM(c *Cartridge) *cnrom {
+	/*
+		PRG-ROM: 16kB / 32kB 固定 (NROMと同じ)
+		CHR-ROM: 8kB 切り替え可能なバンク
+		$8000-$FFFFへの書き込みでCHRのバンクを選択
+	*/
+	return &cnrom{
+		baseMapper: newBaseMapper(c),
+	}
+}
+
+// MARK: CPUからの書き込み
+func (m *cnrom) WritePRG(address uint16, value uint8) {
+	if address < CPU_PRG_ROM_START {
+		m.baseMapper.WritePRG(address, value)
+		return
+	}
+	value = m.busConflict(address, value)
+	m.setCHRBank8k(int(value))
+}
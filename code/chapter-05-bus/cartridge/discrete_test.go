@@ -0,0 +1,109 @@
+package cartridge
+
+import "testing"
+
+// MARK: UxROMのバンク切り替えのテスト
+func TestUxROMBanks(t *testing.T) {
+	c := testCartridge(t, 2, 128*1024, 0)
+	if got := prgBankAt(t, c, 0xC000); got != 14 {
+		t.Fatalf("$C000 = 8kB bank %d, want the last 16kB bank (14)", got)
+	}
+
+	c.WriteByteAt(0x8000, 0x05)
+	if got := prgBankAt(t, c, 0x8000); got != 10 {
+		t.Fatalf("$8000 = 8kB bank %d, want 10", got)
+	}
+	if got := prgBankAt(t, c, 0xE000); got != 15 {
+		t.Fatalf("$E000 = 8kB bank %d, want 15 (fixed)", got)
+	}
+
+	// ROMのサイズを超えるバンク番号は折り返す
+	c.WriteByteAt(0xFFFF, 0x0F)
+	if got := prgBankAt(t, c, 0x8000); got != 14 {
+		t.Fatalf("$8000 after bank $0F = 8kB bank %d, want 14", got)
+	}
+}
+
+// MARK: CNROMのバンク切り替えのテスト
+func TestCNROMBanks(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 3, prgROM: 32 * 1024, chrROM: 32 * 1024})
+	c.WriteByteAt(0x8000, 0x02)
+	if got := chrBankAt(c, 0x0000); got != 16 {
+		t.Fatalf("$0000 = 1kB bank %d, want 16", got)
+	}
+	if got := chrBankAt(c, 0x1C00); got != 23 {
+		t.Fatalf("$1C00 = 1kB bank %d, want 23", got)
+	}
+	if got := prgBankAt(t, c, 0xC000); got != 2 {
+		t.Fatalf("PRG-ROM changed: $C000 = 8kB bank %d, want 2", got)
+	}
+}
+
+// MARK: AxROMのバンク切り替えと1画面ミラーリングのテスト
+func TestAxROMBanks(t *testing.T) {
+	c := testCartridge(t, 7, 256*1024, 0)
+	if c.Mirroring() != MirroringSingleScreenLower {
+		t.Fatalf("power-on mirroring = %v, want single-screen (lower)", c.Mirroring())
+	}
+
+	c.WriteByteAt(0x8000, 0x13)
+	if got := prgBankAt(t, c, 0x8000); got != 12 {
+		t.Fatalf("$8000 = 8kB bank %d, want 12", got)
+	}
+	if got := prgBankAt(t, c, 0xE000); got != 15 {
+		t.Fatalf("$E000 = 8kB bank %d, want 15", got)
+	}
+	if c.Mirroring() != MirroringSingleScreenUpper {
+		t.Fatalf("mirroring = %v, want single-screen (upper)", c.Mirroring())
+	}
+
+	c.WriteByteAt(0x8000, 0x05)
+	if got := prgBankAt(t, c, 0x8000); got != 20 {
+		t.Fatalf("$8000 = 8kB bank %d, want 20", got)
+	}
+	if c.Mirroring() != MirroringSingleScreenLower {
+		t.Fatalf("mirroring = %v, want single-screen (lower)", c.Mirroring())
+	}
+}
+
+// MARK: サブマッパー2のバスの競合のテスト
+func TestDiscreteBusConflicts(t *testing.T) {
+	tests := []struct {
+		name      string
+		board     testBoard
+		address   uint16 // 書き込み先 (ROMの値はそのアドレスの8kBバンクの番号)
+		value     uint8
+		bankAt    uint16
+		chr       bool
+		want      int
+		mirroring Mirroring
+	}{
+		// $C000のROMの値は$0E: $05 & $0E = $04
+		{"UxROM", testBoard{mapper: 2, submapper: DISCRETE_SUBMAPPER_BUS_CONFLICTS, prgROM: 128 * 1024}, 0xC000, 0x05, 0x8000, false, 8, 0},
+		{"UxROM without conflicts", testBoard{mapper: 2, submapper: DISCRETE_SUBMAPPER_NO_BUS_CONFLICTS, prgROM: 128 * 1024}, 0xC000, 0x05, 0x8000, false, 10, 0},
+		// $E000のROMの値は$03: $03 & $03 = $03, $8000のROMの値は$00
+		{"CNROM", testBoard{mapper: 3, submapper: DISCRETE_SUBMAPPER_BUS_CONFLICTS, prgROM: 32 * 1024, chrROM: 32 * 1024}, 0xE000, 0x03, 0x0000, true, 24, 0},
+		{"CNROM at $8000", testBoard{mapper: 3, submapper: DISCRETE_SUBMAPPER_BUS_CONFLICTS, prgROM: 32 * 1024, chrROM: 32 * 1024}, 0x8000, 0x03, 0x0000, true, 0, 0},
+		// $E000のROMの値は$03: $17 & $03 = $03 (bit4は消える)
+		{"AxROM", testBoard{mapper: 7, submapper: DISCRETE_SUBMAPPER_BUS_CONFLICTS, prgROM: 256 * 1024}, 0xE000, 0x17, 0x8000, false, 12, MirroringSingleScreenLower},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCartridge(t, tt.board)
+			c.WriteByteAt(tt.address, tt.value)
+
+			var got int
+			if tt.chr {
+				got = chrBankAt(c, tt.bankAt)
+			} else {
+				got = prgBankAt(t, c, tt.bankAt)
+			}
+			if got != tt.want {
+				t.Fatalf("$%04X = bank %d, want %d", tt.bankAt, got, tt.want)
+			}
+			if tt.board.mapper == 7 && c.Mirroring() != tt.mirroring {
+				t.Fatalf("mirroring = %v, want %v", c.Mirroring(), tt.mirroring)
+			}
+		})
+	}
+}
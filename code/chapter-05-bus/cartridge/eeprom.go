@@ -0,0 +1,201 @@
+package cartridge
+
+const (
+	EEPROM_24C01_SIZE = 128
+	EEPROM_24C02_SIZE = 256
+
+	EEPROM_24C01_PAGE_SIZE = 4
+	EEPROM_24C02_PAGE_SIZE = 8
+	EEPROM_24C02_DEVICE    = 0xA0 // デバイスアドレスの上位4bit (1010)
+
+	EEPROM_ERASED_VALUE = 0xFF // 消去済みのセルの値
+)
+
+// MARK: EEPROMの状態
+type eepromState uint8
+
+const (
+	eepromIdle          eepromState = iota
+	eepromDeviceAddress             // デバイスアドレスの受信 (24C02)
+	eepromWordAddress               // ワードアドレスの受信
+	eepromWrite                     // データの受信
+	eepromRead                      // データの送信
+	eepromSendAck                   // EEPROMからの確認応答
+	eepromWaitAck                   // マスタからの確認応答 (読み取り時)
+)
+
+// MARK: I2C接続のシリアルEEPROM (24C01 / 24C02) の定義
+type i2cEEPROM struct {
+	cartridge *Cartridge
+	data      []uint8 // EEPROMの内容 (バッテリーの有無に関わらずセーブファイルに保存される)
+	x24c01    bool    // 24C01: デバイスアドレスを持たず、LSBから転送する
+
+	scl, sda bool // 直前の入力
+	output   bool // EEPROMが出力するSDA (falseの場合はLowに引き込む)
+
+	state     eepromState
+	nextState eepromState // 確認応答の後の状態
+	shift     uint8
+	bits      int
+	address   uint8
+}
+
+// MARK: シリアルEEPROMのコンストラクタ
+func newI2CEEPROM(c *Cartridge, size int) *i2cEEPROM {
+	// EEPROMは電源を必要としないため、PRG-RAMとは別に内容を保持する
+	data := make([]uint8, size)
+	for i := range data {
+		data[i] = EEPROM_ERASED_VALUE
+	}
+	return &i2cEEPROM{
+		cartridge: c,
+		data:      data,
+		x24c01:    size == EEPROM_24C01_SIZE,
+		output:    true,
+	}
+}
+
+// MARK: SDAの出力を返すメソッド
+func (e *i2cEEPROM) read() bool {
+	return e.output
+}
+
+// MARK: SCLとSDAの入力
+func (e *i2cEEPROM) write(scl bool, sda bool) {
+	switch {
+	case e.scl && scl && e.sda && !sda:
+		// SCLがHighの間にSDAが立ち下がる: 開始条件
+		e.state = eepromWordAddress
+		if !e.x24c01 {
+			e.state = eepromDeviceAddress
+		}
+		e.bits = 0
+		e.output = true
+	case e.scl && scl && !e.sda && sda:
+		// SCLがHighの間にSDAが立ち上がる: 停止条件
+		e.state = eepromIdle
+		e.output = true
+	case !e.scl && scl:
+		e.rise(sda)
+	case e.scl && !scl:
+		e.fall()
+	}
+	e.scl, e.sda = scl, sda
+}
+
+// MARK: SCLの立ち上がり (ビットの受信 / 送信)
+func (e *i2cEEPROM) rise(sda bool) {
+	switch e.state {
+	case eepromDeviceAddress, eepromWordAddress, eepromWrite:
+		e.receiveBit(sda)
+	case eepromRead:
+		e.sendBit()
+	case eepromSendAck:
+		e.output = false
+	case eepromWaitAck:
+		// マスタがACK (Low) を返した場合は次のバイトを送信し、NACKの場合は停止条件を待つ
+		if sda {
+			e.nextState = eepromIdle
+		} else {
+			e.nextState = eepromRead
+			e.shift = e.data[e.address]
+		}
+	}
+}
+
+// MARK: SCLの立ち下がり (1バイトの完了)
+func (e *i2cEEPROM) fall() {
+	switch e.state {
+	case eepromDeviceAddress:
+		if e.bits < 8 {
+			return
+		}
+		if e.shift&0xF0 != EEPROM_24C02_DEVICE {
+			e.state = eepromIdle // 他のデバイス宛て
+			return
+		}
+		e.ack(e.readOrWrite(e.shift&0x01 != 0, eepromWordAddress))
+	case eepromWordAddress:
+		if e.bits < 8 {
+			return
+		}
+		if e.x24c01 {
+			// 24C01は7bitのアドレスとR/Wを続けて受信する
+			e.address = e.shift & 0x7F
+			e.ack(e.readOrWrite(e.shift&0x80 != 0, eepromWrite))
+		} else {
+			e.address = e.shift
+			e.ack(eepromWrite)
+		}
+	case eepromWrite:
+		if e.bits < 8 {
+			return
+		}
+		e.store(e.shift)
+		e.ack(eepromWrite)
+	case eepromRead:
+		if e.bits < 8 {
+			return
+		}
+		e.address = uint8((int(e.address) + 1) % len(e.data))
+		e.state = eepromWaitAck
+		e.output = true
+	case eepromSendAck, eepromWaitAck:
+		e.state = e.nextState
+		e.bits = 0
+		e.output = true
+	}
+}
+
+// MARK: R/Wのビットから次の状態を決めるメソッド
+func (e *i2cEEPROM) readOrWrite(read bool, write eepromState) eepromState {
+	if read {
+		e.shift = e.data[e.address]
+		return eepromRead
+	}
+	return write
+}
+
+// MARK: 確認応答の送信
+func (e *i2cEEPROM) ack(next eepromState) {
+	e.state = eepromSendAck
+	e.nextState = next
+	e.bits = 0
+}
+
+// MARK: 1bitの受信
+func (e *i2cEEPROM) receiveBit(sda bool) {
+	var bit uint8
+	if sda {
+		bit = 1
+	}
+	if e.x24c01 {
+		e.shift = e.shift>>1 | bit<<7 // LSBから
+	} else {
+		e.shift = e.shift<<1 | bit // MSBから
+	}
+	e.bits++
+}
+
+// MARK: 1bitの送信
+func (e *i2cEEPROM) sendBit() {
+	if e.x24c01 {
+		e.output = e.shift>>e.bits&0x01 != 0
+	} else {
+		e.output = e.shift>>(7-e.bits)&0x01 != 0
+	}
+	e.bits++
+}
+
+// MARK: 受信したデータの書き込み (ページ内でアドレスが折り返す)
+func (e *i2cEEPROM) store(value uint8) {
+	page := uint8(EEPROM_24C02_PAGE_SIZE)
+	if e.x24c01 {
+		page = EEPROM_24C01_PAGE_SIZE
+	}
+	if e.data[e.address] != value {
+		e.cartridge.markSaveDirty()
+	}
+	e.data[e.address] = value
+	e.address = e.address&^(page-1) | (e.address+1)&(page-1)
+}
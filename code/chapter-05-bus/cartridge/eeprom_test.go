@@ -0,0 +1,299 @@
+package cartridge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// MARK: テスト用のI2Cマスタの定義
+type testI2CMaster struct {
+	t        testing.TB
+	set      func(scl bool, sda bool) // SCLとSDAの出力
+	get      func() bool              // SDAの入力
+	lsbFirst bool                     // 24C01はLSBから転送する
+}
+
+// MARK: EEPROMに直接接続したI2Cマスタ
+func newTestI2CMaster(t testing.TB, e *i2cEEPROM) *testI2CMaster {
+	return &testI2CMaster{t: t, set: e.write, get: e.read, lsbFirst: e.x24c01}
+}
+
+// MARK: 開始条件 (SCLがHighの間にSDAを立ち下げる)
+func (m *testI2CMaster) start() {
+	m.set(false, true)
+	m.set(true, true)
+	m.set(true, false)
+	m.set(false, false)
+}
+
+// MARK: 停止条件 (SCLがHighの間にSDAを立ち上げる)
+func (m *testI2CMaster) stop() {
+	m.set(false, false)
+	m.set(true, false)
+	m.set(true, true)
+}
+
+// MARK: 1bitの送信
+func (m *testI2CMaster) writeBit(bit bool) {
+	m.set(false, bit)
+	m.set(true, bit)
+	m.set(false, bit)
+}
+
+// MARK: 1bitの受信 (SDAを解放してSCLがHighの間に読み取る)
+func (m *testI2CMaster) readBit() bool {
+	m.set(false, true)
+	m.set(true, true)
+	bit := m.get()
+	m.set(false, true)
+	return bit
+}
+
+// MARK: 1バイトを送信して確認応答を返すメソッド
+func (m *testI2CMaster) writeByte(value uint8) bool {
+	for i := range 8 {
+		shift := 7 - i
+		if m.lsbFirst {
+			shift = i
+		}
+		m.writeBit(value>>shift&0x01 != 0)
+	}
+	return !m.readBit() // EEPROMがLowに引き込めばACK
+}
+
+// MARK: 1バイトを受信して確認応答を送信するメソッド
+func (m *testI2CMaster) readByte(ack bool) uint8 {
+	var value uint8
+	for i := range 8 {
+		shift := 7 - i
+		if m.lsbFirst {
+			shift = i
+		}
+		if m.readBit() {
+			value |= 1 << shift
+		}
+	}
+	m.writeBit(!ack)
+	return value
+}
+
+// MARK: 確認応答を要求して1バイトを送信するメソッド
+func (m *testI2CMaster) mustWrite(value uint8) {
+	m.t.Helper()
+	if !m.writeByte(value) {
+		m.t.Fatalf("EEPROM did not acknowledge $%02X", value)
+	}
+}
+
+// MARK: 24C02への書き込みと読み取りのテスト
+func TestEEPROM24C02WriteRead(t *testing.T) {
+	c := testCartridge(t, 16, 128*1024, 0)
+	e := newI2CEEPROM(c, EEPROM_24C02_SIZE)
+	m := newTestI2CMaster(t, e)
+
+	// デバイスアドレス, ワードアドレス, データの順に送信する
+	m.start()
+	m.mustWrite(EEPROM_24C02_DEVICE)
+	m.mustWrite(0x10)
+	for _, value := range []uint8{0x11, 0x22, 0x33} {
+		m.mustWrite(value)
+	}
+	m.stop()
+	if !c.saveDirty {
+		t.Fatal("write to the EEPROM did not mark the save dirty")
+	}
+	if e.data[0x10] != 0x11 || e.data[0x11] != 0x22 || e.data[0x12] != 0x33 {
+		t.Fatalf("EEPROM = % X, want 11 22 33", e.data[0x10:0x13])
+	}
+
+	// ランダムリード: ワードアドレスを設定してから再度開始条件を送信する
+	m.start()
+	m.mustWrite(EEPROM_24C02_DEVICE)
+	m.mustWrite(0x10)
+	m.start()
+	m.mustWrite(EEPROM_24C02_DEVICE | 0x01)
+	got := []uint8{m.readByte(true), m.readByte(true), m.readByte(false)}
+	m.stop()
+	if got[0] != 0x11 || got[1] != 0x22 || got[2] != 0x33 {
+		t.Fatalf("read % X, want 11 22 33", got)
+	}
+}
+
+// MARK: 24C02のページ内の折り返しとシーケンシャルリードの折り返しのテスト
+func TestEEPROM24C02Wrap(t *testing.T) {
+	c := testCartridge(t, 16, 128*1024, 0)
+	e := newI2CEEPROM(c, EEPROM_24C02_SIZE)
+	m := newTestI2CMaster(t, e)
+
+	// 8バイトのページの末尾を超えるとページの先頭に戻る
+	m.start()
+	m.mustWrite(EEPROM_24C02_DEVICE)
+	m.mustWrite(0x0E)
+	for _, value := range []uint8{0xA1, 0xA2, 0xA3} {
+		m.mustWrite(value)
+	}
+	m.stop()
+	if e.data[0x0E] != 0xA1 || e.data[0x0F] != 0xA2 || e.data[0x08] != 0xA3 {
+		t.Fatalf("page write = % X, want A1 A2 at $0E and A3 at $08", e.data[0x08:0x10])
+	}
+	if e.data[0x10] != EEPROM_ERASED_VALUE {
+		t.Fatalf("page write spilled into the next page: $%02X", e.data[0x10])
+	}
+
+	// 読み取りはページに関係なく末尾から先頭へ折り返す
+	e.data[0xFF], e.data[0x00] = 0x5A, 0xA5
+	m.start()
+	m.mustWrite(EEPROM_24C02_DEVICE)
+	m.mustWrite(0xFF)
+	m.start()
+	m.mustWrite(EEPROM_24C02_DEVICE | 0x01)
+	got := []uint8{m.readByte(true), m.readByte(false)}
+	m.stop()
+	if got[0] != 0x5A || got[1] != 0xA5 {
+		t.Fatalf("read % X, want 5A A5", got)
+	}
+}
+
+// MARK: 他のデバイス宛てのアドレスに応答しないかのテスト
+func TestEEPROM24C02IgnoresOtherDevices(t *testing.T) {
+	c := testCartridge(t, 16, 128*1024, 0)
+	e := newI2CEEPROM(c, EEPROM_24C02_SIZE)
+	m := newTestI2CMaster(t, e)
+
+	m.start()
+	if m.writeByte(0x50) {
+		t.Fatal("EEPROM acknowledged another device's address")
+	}
+	m.writeByte(0x00)
+	m.writeByte(0x99)
+	m.stop()
+	if c.saveDirty || e.data[0x00] != EEPROM_ERASED_VALUE {
+		t.Fatal("EEPROM stored data addressed to another device")
+	}
+}
+
+// MARK: 24C01 (デバイスアドレスなし, LSBから転送) の書き込みと読み取りのテスト
+func TestEEPROM24C01WriteRead(t *testing.T) {
+	c := testCartridge(t, 159, 128*1024, 0)
+	e := newI2CEEPROM(c, EEPROM_24C01_SIZE)
+	m := newTestI2CMaster(t, e)
+
+	// 7bitのワードアドレスとR/W (bit7) を1バイトで送信する
+	// 4バイトのページの末尾を超えるとページの先頭に戻る
+	m.start()
+	m.mustWrite(0x06)
+	for _, value := range []uint8{0x61, 0x62, 0x63} {
+		m.mustWrite(value)
+	}
+	m.stop()
+	if e.data[0x06] != 0x61 || e.data[0x07] != 0x62 || e.data[0x04] != 0x63 {
+		t.Fatalf("page write = % X, want 61 62 at $06 and 63 at $04", e.data[0x04:0x08])
+	}
+
+	m.start()
+	m.mustWrite(0x80 | 0x06)
+	got := []uint8{m.readByte(true), m.readByte(false)}
+	m.stop()
+	if got[0] != 0x61 || got[1] != 0x62 {
+		t.Fatalf("read % X, want 61 62", got)
+	}
+
+	// アドレスは7bitのため$80以上は存在しない
+	if len(e.data) != EEPROM_24C01_SIZE {
+		t.Fatalf("24C01 has %d bytes, want %d", len(e.data), EEPROM_24C01_SIZE)
+	}
+}
+
+// MARK: バンダイの$x00Dと$6000-$7FFFに接続したI2Cマスタ
+func newBandaiI2CMaster(t testing.TB, c *Cartridge) *testI2CMaster {
+	return &testI2CMaster{
+		t: t,
+		set: func(scl bool, sda bool) {
+			var value uint8
+			if scl {
+				value |= 0x20
+			}
+			if sda {
+				value |= 0x40
+			}
+			c.WriteByteAt(0x800D, value)
+		},
+		get: func() bool {
+			value, _ := c.ReadByteFrom(CPU_PRG_RAM_START)
+			return value&BANDAI_EEPROM_SDA != 0
+		},
+		lsbFirst: c.Header.Mapper == 159,
+	}
+}
+
+// MARK: バッテリーを持たないマッパー159のEEPROMが保存されるかのテスト
+func TestBandaiEEPROMSavedWithoutBattery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+
+	c := testCartridge(t, 159, 128*1024, 0)
+	if err := c.AttachSaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	m := newBandaiI2CMaster(t, c)
+	m.start()
+	m.mustWrite(0x03)
+	m.mustWrite(0x42)
+	m.stop()
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != EEPROM_24C01_SIZE || saved[0x03] != 0x42 {
+		t.Fatalf("save file has %d bytes ($03 = $%02X), want %d bytes with $42", len(saved), saved[0x03], EEPROM_24C01_SIZE)
+	}
+
+	// 読み込み直したEEPROMから同じ値が読み取れる
+	c = testCartridge(t, 159, 128*1024, 0)
+	if err := c.AttachSaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	m = newBandaiI2CMaster(t, c)
+	m.start()
+	m.mustWrite(0x80 | 0x03)
+	got := m.readByte(false)
+	m.stop()
+	if got != 0x42 {
+		t.Fatalf("reloaded EEPROM[$03] = $%02X, want $42", got)
+	}
+}
+
+// MARK: バッテリーを持つマッパー16のセーブファイルがEEPROMのみを含むかのテスト
+func TestBandaiEEPROMSaveSize(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 16, submapper: BANDAI_SUBMAPPER_LZ93D50, prgROM: 128 * 1024, prgNVRAM: EEPROM_24C02_SIZE})
+	regions := c.batteryBackedData()
+	if len(regions) != 1 || len(regions[0]) != EEPROM_24C02_SIZE {
+		t.Fatalf("battery-backed regions = %d, want only the %d-byte EEPROM", len(regions), EEPROM_24C02_SIZE)
+	}
+}
+
+// MARK: バッテリーを持たないiNESのマッパー159にセーブファイルが関連付けられるかのテスト
+func TestLoadAttachesEEPROMSave(t *testing.T) {
+	dir := t.TempDir()
+	romPath := filepath.Join(dir, "game.nes")
+	image := []uint8{'N', 'E', 'S', 0x1A, 8, 1, 0xF0, 0x90, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]uint8, 8*PRG_ROM_BANK_SIZE+CHR_ROM_BANK_SIZE)...)
+	if err := os.WriteFile(romPath, image, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, report, err := Load(romPath, LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Header.Battery {
+		t.Fatal("test ROM unexpectedly has a battery")
+	}
+	if want := filepath.Join(dir, "game.sav"); report.SavePath != want {
+		t.Fatalf("save path = %q, want %q", report.SavePath, want)
+	}
+}
@@ -0,0 +1,162 @@
+package cartridge
+
+// MARK: FME-7 / 5A / 5B (マッパー69) の定義
+type fme7 struct {
+	baseMapper
+
+	command uint8    // $8000-$9FFF
+	chr     [8]uint8 // コマンド$0-$7
+	prg     [4]uint8 // コマンド$8-$B ($6000, $8000, $A000, $C000)
+
+	// IRQ (コマンド$D-$F)
+	irqEnabled     bool
+	counterEnabled bool
+	counter        uint16
+	irqPending     bool
+
+	audio sunsoft5b
+}
+
+// MARK: FME-7のコンストラクタ
+func newFME7(c *Cartridge) *fme7 {
+	/*
+		$6000-$7FFF: 8kB 切り替え可能なPRG-ROM または PRG-RAM
+		$8000-$DFFF: 8kB 切り替え可能なバンク x3
+		$E000-$FFFF: 最後のバンクに固定
+		CHRは1kB単位で8つ
+	*/
+	m := &fme7{
+		baseMapper: newBaseMapper(c),
+	}
+	m.updateBanks()
+	return m
+}
+
+// MARK: $6000-$7FFFにPRG-RAMが割り当てられているか
+func (m *fme7) ramSelected() bool {
+	return m.prg[0]&0x40 != 0
+}
+
+// MARK: CPUからの読み取り
+func (m *fme7) ReadPRG(address uint16) (uint8, bool) {
+	return m.PeekPRG(address)
+}
+
+// MARK: CPUからの読み取り (副作用なし)
+func (m *fme7) PeekPRG(address uint16) (uint8, bool) {
+	if m.romAt6000(address) {
+		return m.cartridge.PRGROM[m.prgROMIndex6000(address)], true
+	}
+	return m.baseMapper.PeekPRG(address)
+}
+
+// MARK: CPUからの書き込み (副作用なし)
+func (m *fme7) PokePRG(address uint16, value uint8) {
+	// PeekPRGと同じく、$6000-$7FFFにROMが割り当てられている場合はROMを書き換える
+	if m.romAt6000(address) {
+		m.cartridge.PRGROM[m.prgROMIndex6000(address)] = value
+		return
+	}
+	m.baseMapper.PokePRG(address, value)
+}
+
+// MARK: $6000-$7FFFにPRG-ROMが割り当てられているアドレスかを判定するメソッド
+func (m *fme7) romAt6000(address uint16) bool {
+	return CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END && !m.ramSelected()
+}
+
+// MARK: $6000-$7FFFに割り当てられたPRG-ROMの位置を返すメソッド
+func (m *fme7) prgROMIndex6000(address uint16) int {
+	offset := bankOffset(int(m.prg[0]&0x3F), PRG_BANK_SIZE, len(m.cartridge.PRGROM))
+	return offset + int(address-CPU_PRG_RAM_START)
+}
+
+// MARK: CPUからの書き込み
+func (m *fme7) WritePRG(address uint16, value uint8) {
+	switch address & 0xE000 {
+	case 0x6000:
+		if m.ramSelected() {
+			m.writePRGRAM(address, value)
+		}
+	case 0x8000:
+		m.command = value & 0x0F
+	case 0xA000:
+		m.writeParameter(value)
+	case 0xC000:
+		m.audio.writeAddress(value)
+	case 0xE000:
+		m.audio.writeData(value)
+	}
+}
+
+// MARK: パラメータレジスタへの書き込み ($A000-$BFFF)
+func (m *fme7) writeParameter(value uint8) {
+	switch command := m.command; {
+	case command <= 0x7:
+		m.chr[command] = value
+	case command <= 0xB:
+		m.prg[command-0x8] = value
+	case command == 0xC:
+		switch value & 0x03 {
+		case 0:
+			m.mirroring = MirroringVertical
+		case 1:
+			m.mirroring = MirroringHorizontal
+		case 2:
+			m.mirroring = MirroringSingleScreenLower
+		case 3:
+			m.mirroring = MirroringSingleScreenUpper
+		}
+		return
+	case command == 0xD:
+		// bit0: IRQの有効化, bit7: カウンタの有効化 (書き込むと保留中のIRQは解除される)
+		m.irqEnabled = value&0x01 != 0
+		m.counterEnabled = value&0x80 != 0
+		m.irqPending = false
+		return
+	case command == 0xE:
+		m.counter = m.counter&0xFF00 | uint16(value)
+		return
+	case command == 0xF:
+		m.counter = m.counter&0x00FF | uint16(value)<<8
+		return
+	}
+	m.updateBanks()
+}
+
+// MARK: バンクの更新
+func (m *fme7) updateBanks() {
+	// $6000: bit7 PRG-RAMの有効化, bit6 PRG-RAM(1) / PRG-ROM(0), bit0-5 バンク
+	m.prgRAMBank = int(m.prg[0] & 0x3F)
+	m.prgRAMEnabled = m.ramSelected() && m.prg[0]&0x80 != 0
+	for slot := range 3 {
+		m.setPRGBank8k(slot, int(m.prg[slot+1]&0x3F))
+	}
+	m.setPRGBank8k(3, -1)
+
+	for slot, bank := range m.chr {
+		m.setCHRBank1k(slot, int(bank))
+	}
+}
+
+// MARK: IRQの取得
+func (m *fme7) IRQ() bool {
+	return m.irqPending
+}
+
+// MARK: CPUサイクルの通知
+func (m *fme7) Tick() {
+	// 16bitのカウンタはCPUサイクルごとに減算され、$0000から$FFFFへの桁借りでIRQを発生させる
+	if m.counterEnabled {
+		m.counter--
+		if m.counter == 0xFFFF && m.irqEnabled {
+			m.irqPending = true
+		}
+	}
+	m.audio.tick()
+}
+
+// MARK: 拡張音源の出力
+func (m *fme7) AudioOutput() float32 {
+	return m.audio.output()
+}
@@ -0,0 +1,103 @@
+package cartridge
+
+import "testing"
+
+// MARK: FME-7のコマンドとパラメータの書き込み
+func writeFME7(c *Cartridge, command uint8, value uint8) {
+	c.WriteByteAt(0x8000, command)
+	c.WriteByteAt(0xA000, value)
+}
+
+// MARK: FME-7のバンク切り替えのテスト
+func TestFME7Banks(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 69, prgROM: 256 * 1024, chrROM: 256 * 1024, prgRAM: 8 * 1024})
+	for i := range uint8(8) {
+		writeFME7(c, i, 0x20+i)
+	}
+	writeFME7(c, 0x8, 0x03) // $6000: PRG-ROMのバンク3
+	writeFME7(c, 0x9, 0x04)
+	writeFME7(c, 0xA, 0x05)
+	writeFME7(c, 0xB, 0x06)
+
+	for address, want := range map[uint16]int{0x6000: 3, 0x8000: 4, 0xA000: 5, 0xC000: 6, 0xE000: 31} {
+		if got := prgBankAt(t, c, address); got != want {
+			t.Errorf("$%04X: bank %d, want %d", address, got, want)
+		}
+	}
+	for slot := range 8 {
+		if got := chrBankAt(c, uint16(slot)*CHR_BANK_SIZE); got != 0x20+slot {
+			t.Errorf("CHR slot %d: bank $%02X, want $%02X", slot, got, 0x20+slot)
+		}
+	}
+
+	// bit6でPRG-RAMを選択し、bit7が0の場合はオープンバスになる
+	writeFME7(c, 0x8, 0x40)
+	if _, driven := c.PeekByteFrom(0x6000); driven != DRIVEN_NO_BITS {
+		t.Fatal("PRG-RAM without bit7 is driven")
+	}
+	writeFME7(c, 0x8, 0xC0)
+	c.WriteByteAt(0x6000, 0x5A)
+	if got, _ := c.PeekByteFrom(0x6000); got != 0x5A {
+		t.Fatalf("PRG-RAM = $%02X, want $5A", got)
+	}
+}
+
+// MARK: 16bitのIRQカウンタのテスト
+func TestFME7IRQ(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 69, prgROM: 128 * 1024, chrROM: 128 * 1024})
+	writeFME7(c, 0xE, 0x02)
+	writeFME7(c, 0xF, 0x00)
+	writeFME7(c, 0xD, 0x81) // カウンタとIRQの有効化
+
+	// $0000までは発生せず、$FFFFへの桁借りで発生する
+	c.Tick(2)
+	if c.IRQ() {
+		t.Fatal("IRQ when the counter reached $0000")
+	}
+	c.Tick(1)
+	if !c.IRQ() {
+		t.Fatal("no IRQ when the counter wrapped to $FFFF")
+	}
+
+	// コマンド$Dへの書き込みで解除される
+	writeFME7(c, 0xD, 0x81)
+	if c.IRQ() {
+		t.Fatal("writing command $D did not acknowledge the IRQ")
+	}
+
+	// カウンタは再ロードされずに$FFFFから数え続ける
+	c.Tick(0xFFFF)
+	if c.IRQ() {
+		t.Fatal("IRQ before the counter wrapped again")
+	}
+	c.Tick(1)
+	if !c.IRQ() {
+		t.Fatal("no IRQ after a full 16-bit period")
+	}
+}
+
+// MARK: カウンタとIRQの有効化を個別に制御できるかのテスト
+func TestFME7IRQEnables(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 69, prgROM: 128 * 1024, chrROM: 128 * 1024})
+
+	// IRQのみ有効: カウンタは止まったまま
+	writeFME7(c, 0xE, 0x00)
+	writeFME7(c, 0xF, 0x00)
+	writeFME7(c, 0xD, 0x01)
+	c.Tick(0x20000)
+	if c.IRQ() {
+		t.Fatal("IRQ while the counter is stopped")
+	}
+
+	// カウンタのみ有効: 桁借りしてもIRQは発生しない
+	writeFME7(c, 0xD, 0x80)
+	c.Tick(1)
+	if c.IRQ() {
+		t.Fatal("IRQ while IRQs are disabled")
+	}
+	writeFME7(c, 0xD, 0x81)
+	c.Tick(0x10000)
+	if !c.IRQ() {
+		t.Fatal("counter did not keep running while IRQs were disabled")
+	}
+}
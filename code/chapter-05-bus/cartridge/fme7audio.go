@@ -0,0 +1,196 @@
+package cartridge
+
+import (
+	"math"
+
+	"fc-emu/audio"
+)
+
+const (
+	SUNSOFT5B_CHANNELS        = 3
+	SUNSOFT5B_PRESCALER       = 16 // 内部のクロックはCPUクロックの1/16
+	SUNSOFT5B_ENVELOPE_STEPS  = 32
+	SUNSOFT5B_STEP_DB         = 1.5 // 5bitの音量の1ステップあたりの変化量
+	SUNSOFT5B_NOISE_LFSR_SEED = 1
+	SUNSOFT5B_NOISE_LFSR_BITS = 17 // 17bitのLFSR (bit0とbit3のXORを最上位bitに戻す)
+
+	// 最大音量のトーン1チャンネルとAPUの矩形波 (音量15) の音量比
+	// 5BはAPUよりかなり大きな音で出力される (約+6dB)
+	SUNSOFT5B_MIX_LEVEL = 2.0
+)
+
+// MARK: 5bitの音量から振幅への変換テーブル (対数)
+var sunsoft5bVolumes = func() [SUNSOFT5B_ENVELOPE_STEPS]float64 {
+	var table [SUNSOFT5B_ENVELOPE_STEPS]float64
+	for i := 1; i < SUNSOFT5B_ENVELOPE_STEPS; i++ {
+		table[i] = math.Pow(10, -float64(SUNSOFT5B_ENVELOPE_STEPS-1-i)*SUNSOFT5B_STEP_DB/20)
+	}
+	return table
+}()
+
+// MARK: 矩形波チャンネルの定義
+type sunsoft5bTone struct {
+	period  uint16 // 12bit
+	counter uint16
+	output  bool
+	volume  uint8 // bit0-3: 音量, bit4: エンベロープを使用する
+}
+
+// MARK: Sunsoft 5B (AY-3-8910互換) の定義
+type sunsoft5b struct {
+	address uint8 // $C000
+
+	tones        [SUNSOFT5B_CHANNELS]sunsoft5bTone
+	mixer        uint8 // レジスタ7 (bit0-2: 矩形波の無効化, bit3-5: ノイズの無効化)
+	noisePeriod  uint8 // 5bit
+	noiseCounter uint16
+	noiseLFSR    uint32
+	noiseOutput  bool
+
+	envelopePeriod  uint16
+	envelopeCounter uint32
+	envelopeShape   uint8 // bit3: 継続, bit2: アタック, bit1: 交互, bit0: ホールド
+	envelopeStep    uint8
+	envelopeAttack  bool
+	envelopeHolding bool
+
+	prescaler int
+}
+
+// MARK: アドレスレジスタへの書き込み ($C000-$DFFF)
+func (a *sunsoft5b) writeAddress(value uint8) {
+	a.address = value & 0x0F
+}
+
+// MARK: データレジスタへの書き込み ($E000-$FFFF)
+func (a *sunsoft5b) writeData(value uint8) {
+	switch r := a.address; {
+	case r <= 0x5:
+		t := &a.tones[r/2]
+		if r%2 == 0 {
+			t.period = t.period&0x0F00 | uint16(value)
+		} else {
+			t.period = t.period&0x00FF | uint16(value&0x0F)<<8
+		}
+	case r == 0x6:
+		a.noisePeriod = value & 0x1F
+	case r == 0x7:
+		a.mixer = value
+	case r <= 0xA:
+		a.tones[r-0x8].volume = value & 0x1F
+	case r == 0xB:
+		a.envelopePeriod = a.envelopePeriod&0xFF00 | uint16(value)
+	case r == 0xC:
+		a.envelopePeriod = a.envelopePeriod&0x00FF | uint16(value)<<8
+	case r == 0xD:
+		// 形状の書き込みでエンベロープは最初からやり直す
+		a.envelopeShape = value & 0x0F
+		a.envelopeAttack = value&0x04 != 0
+		a.envelopeStep = 0
+		a.envelopeCounter = 0
+		a.envelopeHolding = false
+	}
+}
+
+// MARK: CPUサイクルの通知
+func (a *sunsoft5b) tick() {
+	a.prescaler++
+	if a.prescaler < SUNSOFT5B_PRESCALER {
+		return
+	}
+	a.prescaler = 0
+
+	// 矩形波: 周期ごとに出力を反転する (周波数 = CPUクロック / (32 x 周期))
+	for i := range a.tones {
+		t := &a.tones[i]
+		t.counter++
+		if t.counter >= max(t.period, 1) {
+			t.counter = 0
+			t.output = !t.output
+		}
+	}
+
+	// ノイズ: 矩形波の半分の速さでLFSRを進める
+	a.noiseCounter++
+	if a.noiseCounter >= 2*uint16(max(a.noisePeriod, 1)) {
+		a.noiseCounter = 0
+		if a.noiseLFSR == 0 {
+			a.noiseLFSR = SUNSOFT5B_NOISE_LFSR_SEED
+		}
+		feedback := (a.noiseLFSR ^ a.noiseLFSR>>3) & 0x01
+		a.noiseLFSR = a.noiseLFSR>>1 | feedback<<(SUNSOFT5B_NOISE_LFSR_BITS-1)
+		a.noiseOutput = a.noiseLFSR&0x01 != 0
+	}
+
+	// エンベロープ: 32ステップ (周波数 = CPUクロック / (512 x 周期))
+	a.envelopeCounter++
+	if a.envelopeCounter >= uint32(max(a.envelopePeriod, 1)) {
+		a.envelopeCounter = 0
+		a.clockEnvelope()
+	}
+}
+
+// MARK: エンベロープのクロック
+func (a *sunsoft5b) clockEnvelope() {
+	if a.envelopeHolding {
+		return
+	}
+	a.envelopeStep++
+	if a.envelopeStep < SUNSOFT5B_ENVELOPE_STEPS {
+		return
+	}
+
+	// 1周期の終わり
+	continues := a.envelopeShape&0x08 != 0
+	alternate := a.envelopeShape&0x02 != 0
+	hold := a.envelopeShape&0x01 != 0
+	switch {
+	case !continues:
+		// 継続しない場合は0で止まる
+		a.envelopeAttack = false
+		a.envelopeHolding = true
+		a.envelopeStep = SUNSOFT5B_ENVELOPE_STEPS - 1
+	case hold:
+		if alternate {
+			a.envelopeAttack = !a.envelopeAttack
+		}
+		a.envelopeHolding = true
+		a.envelopeStep = SUNSOFT5B_ENVELOPE_STEPS - 1
+	default:
+		if alternate {
+			a.envelopeAttack = !a.envelopeAttack
+		}
+		a.envelopeStep = 0
+	}
+}
+
+// MARK: エンベロープの現在の音量 (0-31)
+func (a *sunsoft5b) envelopeLevel() uint8 {
+	if a.envelopeAttack {
+		return a.envelopeStep
+	}
+	return SUNSOFT5B_ENVELOPE_STEPS - 1 - a.envelopeStep
+}
+
+// MARK: 拡張音源の出力
+func (a *sunsoft5b) output() float32 {
+	var sum float64
+	for i, t := range a.tones {
+		toneOff := a.mixer&(1<<i) != 0
+		noiseOff := a.mixer&(1<<(i+3)) != 0
+		if !(t.output || toneOff) || !(a.noiseOutput || noiseOff) {
+			continue
+		}
+
+		// 4bitの音量は5bitのエンベロープの奇数の段階に対応する
+		level := t.volume&0x0F<<1 | 0x01
+		if t.volume&0x0F == 0 {
+			level = 0
+		}
+		if t.volume&0x10 != 0 {
+			level = a.envelopeLevel()
+		}
+		sum += sunsoft5bVolumes[level]
+	}
+	return float32(sum) * SUNSOFT5B_MIX_LEVEL * audio.PULSE_MAX_LEVEL
+}
@@ -0,0 +1,137 @@
+package cartridge
+
+import (
+	"math"
+	"testing"
+)
+
+// MARK: 5Bのレジスタへの書き込み
+func writeSunsoft5B(a *sunsoft5b, register uint8, value uint8) {
+	a.writeAddress(register)
+	a.writeData(value)
+}
+
+// MARK: エンベロープの形状ごとの音量の変化のテスト
+func TestSunsoft5BEnvelopeShapes(t *testing.T) {
+	tests := []struct {
+		name  string
+		shape uint8
+		want  []uint8 // 0, 31, 32, 63, 64, 95 回クロックした後の音量
+	}{
+		{"decay then off", 0x00, []uint8{31, 0, 0, 0, 0, 0}},
+		{"attack then off", 0x04, []uint8{0, 31, 0, 0, 0, 0}},
+		{"sawtooth down", 0x08, []uint8{31, 0, 31, 0, 31, 0}},
+		{"decay and hold", 0x09, []uint8{31, 0, 0, 0, 0, 0}},
+		{"triangle down", 0x0A, []uint8{31, 0, 0, 31, 31, 0}},
+		{"decay then hold high", 0x0B, []uint8{31, 0, 31, 31, 31, 31}},
+		{"sawtooth up", 0x0C, []uint8{0, 31, 0, 31, 0, 31}},
+		{"attack and hold", 0x0D, []uint8{0, 31, 31, 31, 31, 31}},
+		{"triangle up", 0x0E, []uint8{0, 31, 31, 0, 0, 31}},
+		{"attack then hold low", 0x0F, []uint8{0, 31, 0, 0, 0, 0}},
+	}
+	clocks := []int{0, 31, 32, 63, 64, 95}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var a sunsoft5b
+			writeSunsoft5B(&a, 0xD, tt.shape)
+			done := 0
+			for i, n := range clocks {
+				for ; done < n; done++ {
+					a.clockEnvelope()
+				}
+				if got := a.envelopeLevel(); got != tt.want[i] {
+					t.Errorf("after %d clocks: level %d, want %d", n, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// MARK: エンベロープの周期のテスト
+func TestSunsoft5BEnvelopePeriod(t *testing.T) {
+	var a sunsoft5b
+	writeSunsoft5B(&a, 0xB, 0x02) // 周期2: 32 CPUサイクルごとに1ステップ
+	writeSunsoft5B(&a, 0xC, 0x00)
+	writeSunsoft5B(&a, 0xD, 0x0D)
+
+	for range 2*SUNSOFT5B_PRESCALER - 1 {
+		a.tick()
+	}
+	if got := a.envelopeLevel(); got != 0 {
+		t.Fatalf("level %d before one period, want 0", got)
+	}
+	a.tick()
+	if got := a.envelopeLevel(); got != 1 {
+		t.Fatalf("level %d after one period, want 1", got)
+	}
+
+	// 形状の書き込みでエンベロープは最初からやり直す
+	writeSunsoft5B(&a, 0xD, 0x0D)
+	if got := a.envelopeLevel(); got != 0 {
+		t.Fatalf("level %d after rewriting the shape, want 0", got)
+	}
+}
+
+// MARK: ノイズのLFSRが17bitの最大周期で、周期2×16 CPUサイクルごとに進むかのテスト
+func TestSunsoft5BNoise(t *testing.T) {
+	const period = 1<<17 - 1
+
+	var a sunsoft5b
+	writeSunsoft5B(&a, 0x6, 0x01)
+	writeSunsoft5B(&a, 0x7, 0x37) // チャンネル1のノイズのみ
+	writeSunsoft5B(&a, 0x8, 0x0F)
+
+	for range 2 * SUNSOFT5B_PRESCALER {
+		a.tick()
+	}
+	start := a.noiseLFSR
+	if start == 0 {
+		t.Fatal("LFSR did not start")
+	}
+
+	ones := 0
+	for step := 1; step <= period; step++ {
+		for range 2 * SUNSOFT5B_PRESCALER {
+			a.tick()
+		}
+		if a.noiseOutput {
+			ones++
+			if a.output() == 0 {
+				t.Fatal("noise output high but channel is silent")
+			}
+		} else if a.output() != 0 {
+			t.Fatal("noise output low but channel is audible")
+		}
+		if a.noiseLFSR == start && step != period {
+			t.Fatalf("LFSR repeated after %d steps, want %d", step, period)
+		}
+	}
+	if a.noiseLFSR != start {
+		t.Fatalf("LFSR did not repeat after %d steps", period)
+	}
+	// 最大周期のLFSRは1周期で2^16回1を出力する
+	if ones != 1<<16 {
+		t.Fatalf("noise was high %d times in one period, want %d", ones, 1<<16)
+	}
+}
+
+// MARK: 4bitの音量が1段階あたり3dBの対数で変化するかのテスト
+func TestSunsoft5BVolume(t *testing.T) {
+	level := func(volume uint8) float64 {
+		var a sunsoft5b
+		writeSunsoft5B(&a, 0x7, 0x3F) // 矩形波とノイズをすべて無効にすると常に出力される
+		writeSunsoft5B(&a, 0x8, volume)
+		return float64(a.output())
+	}
+
+	if level(0) != 0 {
+		t.Fatal("volume 0 is audible")
+	}
+	for volume := uint8(2); volume <= 0x0F; volume++ {
+		db := 20 * math.Log10(level(volume)/level(volume-1))
+		if math.Abs(db-2*SUNSOFT5B_STEP_DB) > 1e-3 {
+			t.Errorf("volume %d -> %d: %+.3f dB, want %+.1f dB", volume-1, volume, db, 2*SUNSOFT5B_STEP_DB)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package cartridge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+const (
+	INES_HEADER_SIZE = 16
+
+	INES_FLAGS6_MIRRORING   = 1 << 0 // 0: 水平ミラー, 1: 垂直ミラー
+	INES_FLAGS6_BATTERY     = 1 << 1 // $6000-$7FFFのPRG-RAMがバッテリーバックアップされている
+	INES_FLAGS6_TRAINER     = 1 << 2 // 512バイトのトレーナーが存在する
+	INES_FLAGS6_FOUR_SCREEN = 1 << 3 // 4画面ミラー
+
+	INES_FLAGS7_CONSOLE_MASK = 0x03
+	INES_FLAGS7_FORMAT_MASK  = 0x0C
+	INES_FLAGS7_FORMAT_NES20 = 0x08
+
+	INES_FLAGS9_PAL = 1 << 0
+
+	INES_DEFAULT_PRG_RAM_SIZE = 8 * 1024 // iNESではサイズが不明なため8kBとみなす
+	INES_DEFAULT_CHR_RAM_SIZE = 8 * 1024
+)
+
+var INES_MAGIC = []uint8{'N', 'E', 'S', 0x1A}
+
+var (
+	ErrHeaderTooShort = errors.New("file is too short to contain an iNES header")
+	ErrInvalidMagic   = errors.New("missing iNES magic number \"NES\\x1A\"")
+	ErrNoPRGROM       = errors.New("header declares no PRG-ROM")
+	ErrTruncated      = errors.New("file is shorter than the sizes declared in the header")
+	ErrROMSize        = errors.New("ROM size is not a multiple of the mapper bank size")
+)
+
+// MARK: ヘッダの形式の定義
+type Format uint8
+
+const (
+	FormatINES  Format = iota // iNES
+	FormatNES20               // NES 2.0
+	FormatUNIF                // UNIF
+)
+
+// MARK: ヘッダの形式の文字列表現
+func (f Format) String() string {
+	switch f {
+	case FormatINES:
+		return "iNES"
+	case FormatNES20:
+		return "NES 2.0"
+	case FormatUNIF:
+		return "UNIF"
+	default:
+		return fmt.Sprintf("Format(%d)", uint8(f))
+	}
+}
+
+// MARK: ROMヘッダの定義
+type Header struct {
+	Format     Format
+	PRGROMSize int // バイト単位
+	CHRROMSize int // バイト単位 (0の場合はCHR-RAM)
+
+	PRGRAMSize   int // 揮発性のPRG-RAM (バイト単位)
+	PRGNVRAMSize int // バッテリーバックアップされたPRG-RAM (バイト単位)
+	CHRRAMSize   int // 揮発性のCHR-RAM (バイト単位)
+	CHRNVRAMSize int // バッテリーバックアップされたCHR-RAM (バイト単位)
+
+	Mapper    uint16 // NES 2.0では12bit
+	Submapper uint8  // NES 2.0 / UNIFのみ
+	Mirroring Mirroring
+	Battery   bool
+
+	HasTrainer      bool
+	ConsoleType     ConsoleType
+	Timing          Timing
+	VsPPUType       uint8 // ConsoleTypeVsSystemの場合のみ
+	VsHardwareType  uint8 // ConsoleTypeVsSystemの場合のみ
+	ExtendedConsole uint8 // ConsoleTypeExtendedの場合のみ
+	MiscROMs        uint8
+	ExpansionDevice ExpansionDevice
+}
+
+// MARK: ヘッダの文字列表現
+func (h Header) String() string {
+	return fmt.Sprintf(
+		"%s, mapper %d.%d, PRG-ROM %dkB, CHR-ROM %dkB, PRG-RAM %dkB + %dkB NV, CHR-RAM %dkB + %dkB NV, "+
+			"%s mirroring, battery=%t, trainer=%t, %s, %s",
+		h.Format,
+		h.Mapper,
+		h.Submapper,
+		h.PRGROMSize/1024,
+		h.CHRROMSize/1024,
+		h.PRGRAMSize/1024,
+		h.PRGNVRAMSize/1024,
+		h.CHRRAMSize/1024,
+		h.CHRNVRAMSize/1024,
+		h.Mirroring,
+		h.Battery,
+		h.HasTrainer,
+		h.ConsoleType,
+		h.Timing,
+	)
+}
+
+// MARK: iNESヘッダを解析する関数
+func parseINESHeader(data []uint8) (Header, error) {
+	/*
+		iNES ヘッダ (16バイト)
+
+		0-3   "NES" + $1A
+		4     PRG-ROMのサイズ (16kB単位)
+		5     CHR-ROMのサイズ (8kB単位, 0の場合はCHR-RAM)
+		6     フラグ6 (ミラーリング / バッテリー / トレーナー / 4画面 / マッパー番号の下位4bit)
+		7     フラグ7 (コンソールの種類 / フォーマット / マッパー番号の上位4bit)
+		8     PRG-RAMのサイズ (8kB単位, 0の場合は8kB)
+		9     フラグ9 (TV方式)
+		10-15 未使用
+
+		フラグ7のbit2-3が 0b10 の場合は NES 2.0 として8-15バイト目を解釈する
+	*/
+	if len(data) < INES_HEADER_SIZE {
+		return Header{}, fmt.Errorf("%w: got %d bytes, need %d", ErrHeaderTooShort, len(data), INES_HEADER_SIZE)
+	}
+	if !bytes.Equal(data[0:4], INES_MAGIC) {
+		return Header{}, fmt.Errorf("%w: got % X", ErrInvalidMagic, data[0:4])
+	}
+
+	flags6 := data[6]
+	flags7 := data[7]
+
+	header := Header{
+		Format:      FormatINES,
+		Mapper:      uint16(flags7&0xF0 | flags6>>4),
+		Battery:     flags6&INES_FLAGS6_BATTERY != 0,
+		HasTrainer:  flags6&INES_FLAGS6_TRAINER != 0,
+		ConsoleType: ConsoleType(flags7 & INES_FLAGS7_CONSOLE_MASK),
+	}
+
+	switch {
+	case flags6&INES_FLAGS6_FOUR_SCREEN != 0:
+		header.Mirroring = MirroringFourScreen
+	case flags6&INES_FLAGS6_MIRRORING != 0:
+		header.Mirroring = MirroringVertical
+	default:
+		header.Mirroring = MirroringHorizontal
+	}
+
+	if flags7&INES_FLAGS7_FORMAT_MASK == INES_FLAGS7_FORMAT_NES20 {
+		if err := parseNES20Header(data, &header); err != nil {
+			return Header{}, err
+		}
+	} else {
+		parseINES1Header(data, &header)
+	}
+
+	if header.PRGROMSize == 0 {
+		return Header{}, ErrNoPRGROM
+	}
+	if err := validateROMSizes(header.PRGROMSize, header.CHRROMSize); err != nil {
+		return Header{}, err
+	}
+
+	return header, nil
+}
+
+// MARK: ROMのサイズがマッパーのバンクの単位で割り切れるかを検証する関数
+func validateROMSizes(prgROMSize int, chrROMSize int) error {
+	// NES 2.0の指数表現では8kB未満や端数のサイズも表現できるが、
+	// マッパーはバンクの単位でしかアクセスしないため範囲外の読み取りになる
+	if prgROMSize%PRG_BANK_SIZE != 0 {
+		return fmt.Errorf("%w: PRG-ROM is %d bytes, need a multiple of %d", ErrROMSize, prgROMSize, PRG_BANK_SIZE)
+	}
+	if chrROMSize%CHR_BANK_SIZE != 0 {
+		return fmt.Errorf("%w: CHR-ROM is %d bytes, need a multiple of %d", ErrROMSize, chrROMSize, CHR_BANK_SIZE)
+	}
+	return nil
+}
+
+// MARK: iNES 1.0 固有のフィールドを解析する関数
+func parseINES1Header(data []uint8, header *Header) {
+	header.PRGROMSize = int(data[4]) * PRG_ROM_BANK_SIZE
+	header.CHRROMSize = int(data[5]) * CHR_ROM_BANK_SIZE
+
+	// 古いダンプツールは12-15バイト目に "DiskDude!" 等の文字列を書き込んでいるため、
+	// その場合はマッパー番号の上位4bitを信用しない
+	if !isZero(data[12:16]) {
+		header.Mapper &= 0x0F
+	}
+
+	// iNESではPlayChoice以外の拡張コンソールを表現できない
+	if header.ConsoleType == ConsoleTypeExtended {
+		header.ConsoleType = ConsoleTypeNES
+	}
+
+	// iNESではRAMのサイズが表現できないため、一般的な値を仮定する
+	prgRAMSize := max(int(data[8]), 1) * INES_DEFAULT_PRG_RAM_SIZE
+	if header.Battery {
+		header.PRGNVRAMSize = prgRAMSize
+	} else {
+		header.PRGRAMSize = prgRAMSize
+	}
+	if header.CHRROMSize == 0 {
+		header.CHRRAMSize = INES_DEFAULT_CHR_RAM_SIZE
+	}
+
+	if data[9]&INES_FLAGS9_PAL != 0 {
+		header.Timing = TimingPAL
+	} else {
+		header.Timing = TimingNTSC
+	}
+}
+
+// MARK: iNESファイルを解析する関数
+func parseINES(data []uint8) (*Cartridge, error) {
+	header, err := parseINESHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := INES_HEADER_SIZE
+	trainerSize := 0
+	if header.HasTrainer {
+		trainerSize = TRAINER_SIZE
+	}
+
+	expected := offset + trainerSize + header.PRGROMSize + header.CHRROMSize
+	if len(data) < expected {
+		return nil, fmt.Errorf(
+			"%w: got %d bytes, header declares %d (trainer %d + PRG-ROM %d + CHR-ROM %d)",
+			ErrTruncated,
+			len(data),
+			expected,
+			trainerSize,
+			header.PRGROMSize,
+			header.CHRROMSize,
+		)
+	}
+
+	trainer := cloneBytes(data[offset : offset+trainerSize])
+	offset += trainerSize
+
+	prgROM := cloneBytes(data[offset : offset+header.PRGROMSize])
+	offset += header.PRGROMSize
+
+	chrROM := cloneBytes(data[offset : offset+header.CHRROMSize])
+
+	return newCartridge(header, prgROM, chrROM, trainer)
+}
+
+// MARK: すべて0のバイト列かを判定する関数
+func isZero(data []uint8) bool {
+	for _, b := range data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// MARK: バイト列を複製する関数 (元のバッファを保持しないようにする)
+func cloneBytes(data []uint8) []uint8 {
+	if len(data) == 0 {
+		return nil
+	}
+	return bytes.Clone(data)
+}
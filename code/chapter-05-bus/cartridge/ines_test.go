@@ -0,0 +1,37 @@
+package cartridge
+
+import (
+	"errors"
+	"testing"
+)
+
+// MARK: NES 2.0の指数表現でバンクの単位に満たないROMサイズを拒否するかのテスト
+func TestParseNES20RejectsUnalignedROMSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		prgLSB uint8 // PRG-ROMサイズの下位8bit (上位4bitは指数表現のマーカー)
+		chrLSB uint8 // CHR-ROMサイズの下位8bit
+		chrMSB uint8
+		ok     bool
+	}{
+		{"PRG 2^12", 12 << 2, 0, 0, false},
+		{"PRG 2^12 x3", 12<<2 | 1, 0, 0, false},
+		{"PRG 2^13", 13 << 2, 0, 0, true},
+		{"PRG 2^13 x3", 13<<2 | 1, 0, 0, true},
+		{"CHR 2^9", 14 << 2, 9 << 2, NES20_SIZE_EXPONENT_MARKER, false},
+		{"CHR 2^10", 14 << 2, 10 << 2, NES20_SIZE_EXPONENT_MARKER, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := []uint8{'N', 'E', 'S', 0x1A, tt.prgLSB, tt.chrLSB, 0x00, INES_FLAGS7_FORMAT_NES20, 0, NES20_SIZE_EXPONENT_MARKER | tt.chrMSB<<4, 0, 0, 0, 0, 0, 0}
+			_, err := parseINESHeader(header)
+			if tt.ok && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrROMSize) {
+				t.Fatalf("error = %v, want %v", err, ErrROMSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package cartridge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fc-emu/patch"
+)
+
+// ROMと同じ名前で自動的に適用するパッチの拡張子 (優先順)
+var patchExtensions = []string{".ips", ".ups", ".bps"}
+
+// MARK: ROM読み込み時のオプションの定義
+type LoadOptions struct {
+	ArchiveEntry     string    // zip内に複数のROMがある場合に読み込むエントリの名前
+	PatchPath        string    // 適用するパッチ (空の場合はROMと同じ名前のパッチを探す)
+	DisableAutoPatch bool      // trueの場合はROMと同じ名前のパッチを適用しない
+	Database         *Database // ヘッダの修正に使用するデータベース (nilの場合は組み込みのもの)
+	DisableDatabase  bool      // trueの場合はヘッダを修正しない
+	SavePath         string    // セーブファイル (空の場合はROMと同じ名前の .sav)
+	DisableSave      bool      // trueの場合はセーブファイルを読み書きしない
+	SmoothAudio      bool      // trueの場合は時分割多重の拡張音源を平均して出力する (高周波ノイズを避ける)
+}
+
+// MARK: ROM読み込み結果の報告の定義
+type LoadReport struct {
+	PatchPath   string       // 適用したパッチ (適用していない場合は空)
+	SavePath    string       // 関連付けたセーブファイル (バッテリーを持たない場合は空)
+	Corrections []Correction // データベースによるヘッダの修正内容
+}
+
+// MARK: ファイルからカートリッジを読み込む関数
+func LoadFromFile(path string) (*Cartridge, error) {
+	cartridge, _, err := Load(path, LoadOptions{})
+	return cartridge, err
+}
+
+// MARK: オプションを指定してファイルからカートリッジを読み込む関数
+func Load(path string, opts LoadOptions) (*Cartridge, LoadReport, error) {
+	var report LoadReport
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, report, err
+	}
+
+	// zip / gzip で圧縮されている場合は展開する
+	if data, err = extractROM(data, opts.ArchiveEntry); err != nil {
+		return nil, report, fmt.Errorf("%s: %w", path, err)
+	}
+
+	// パッチはヘッダを含むファイル全体に対してメモリ上で適用する (ファイルは変更しない)
+	patchPath := opts.PatchPath
+	if patchPath == "" && !opts.DisableAutoPatch {
+		patchPath = findPatch(path)
+	}
+	if patchPath != "" {
+		patchData, err := os.ReadFile(patchPath)
+		if err != nil {
+			return nil, report, err
+		}
+		if data, err = patch.Apply(data, patchData); err != nil {
+			return nil, report, fmt.Errorf("%s: %w", patchPath, err)
+		}
+		report.PatchPath = patchPath
+	}
+
+	cartridge, err := Parse(data)
+	if err != nil {
+		return nil, report, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if !opts.DisableDatabase {
+		db := opts.Database
+		if db == nil {
+			db = BuiltinDatabase()
+		}
+		if report.Corrections, err = db.Correct(cartridge); err != nil {
+			return nil, report, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	// マッパーは出力のたびにカートリッジの設定を参照する (データベースの修正後も同じカートリッジを指す)
+	cartridge.smoothAudio = opts.SmoothAudio
+
+	// データベースでバッテリーの有無が修正されている可能性があるため最後に行う
+	// (EEPROMはバッテリーを持たなくても保存するため、保存の要否はAttachSaveFileが判断する)
+	if !opts.DisableSave {
+		savePath := opts.SavePath
+		if savePath == "" {
+			savePath = SavePathFor(path)
+		}
+		if err := cartridge.AttachSaveFile(savePath); err != nil {
+			return nil, report, err
+		}
+		report.SavePath = cartridge.savePath
+	}
+
+	return cartridge, report, nil
+}
+
+// MARK: ROMと同じ名前のパッチを探す関数
+func findPatch(romPath string) string {
+	// game.nes → game.ips, game.nes.gz → game.nes.ips / game.ips の順に探す
+	var bases []string
+	for base := romPath; filepath.Ext(base) != ""; {
+		base = strings.TrimSuffix(base, filepath.Ext(base))
+		bases = append(bases, base)
+	}
+
+	for _, base := range bases {
+		for _, ext := range patchExtensions {
+			candidate := base + ext
+			if _, err := os.Stat(candidate); err == nil {
+				return candidate
+			}
+		}
+	}
+	return ""
+}
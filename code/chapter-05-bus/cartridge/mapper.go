@@ -0,0 +1,383 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	PRG_BANK_SIZE = 8 * 1024 // PRG-ROMのバンク切り替えの最小単位
+	CHR_BANK_SIZE = 1 * 1024 // CHRのバンク切り替えの最小単位
+
+	PRG_BANK_SLOTS = 4 // $8000, $A000, $C000, $E000
+	CHR_BANK_SLOTS = 8 // $0000, $0400, ... $1C00
+
+	PPU_PATTERN_TABLE_END = 0x1FFF
+
+	// UxROM / CNROM / AxROM 等のディスクリートロジックのボードのサブマッパー番号
+	DISCRETE_SUBMAPPER_NO_BUS_CONFLICTS = 1
+	DISCRETE_SUBMAPPER_BUS_CONFLICTS    = 2
+)
+
+var ErrUnsupportedMapper = errors.New("unsupported mapper")
+
+// MARK: マッパーの定義
+type Mapper interface {
+	// CPU側のアクセス ($4020-$FFFF, 応答しないアドレスでは false を返す)
+	ReadPRG(address uint16) (uint8, bool)
+	WritePRG(address uint16, value uint8)
+	PeekPRG(address uint16) (uint8, bool)
+	PokePRG(address uint16, value uint8)
+
+	// PPU側のアクセス (パターンテーブル $0000-$1FFF)
+	ReadCHR(address uint16) uint8
+	WriteCHR(address uint16, value uint8)
+	PeekCHR(address uint16) uint8
+
+	// ネームテーブルのミラーリング
+	Mirroring() Mirroring
+
+	// CPUへのIRQ出力 (trueの間はIRQをアサートし続ける)
+	IRQ() bool
+
+	// タイミングの通知
+	Tick()                     // CPUの1サイクルごと
+	Scanline()                 // PPUの1スキャンラインごと (描画中のみ)
+	PPUAddress(address uint16) // PPUがアドレスバスにアドレスを出力するごと
+}
+
+// MARK: ネームテーブルを個別に切り替えられるマッパーの定義
+type nametableMapper interface {
+	// ネームテーブル (0-3) に割り当てるCIRAMのページを返す
+	NametablePage(table int) int
+}
+
+// MARK: カートリッジ側でネームテーブルの読み書きに応答するマッパーの定義
+type nametableDevice interface {
+	// 応答しないアドレスでは false を返す (本体のCIRAMが使われる)
+	ReadNametable(address uint16) (uint8, bool)
+	WriteNametable(address uint16, value uint8) bool
+	PeekNametable(address uint16) (uint8, bool)
+}
+
+// MARK: PPUレジスタへの書き込みを監視するマッパーの定義
+type ppuRegisterObserver interface {
+	ObservePPURegister(address uint16, value uint8)
+}
+
+// MARK: データバスの一部のbitのみを駆動するマッパーの定義
+type partialBusMapper interface {
+	// ReadPRG / PeekPRG で応答したアドレスのうち、実際に駆動するbitのマスクを返す
+	// (残りのbitはオープンバスになる)
+	DrivenBits(address uint16) uint8
+}
+
+// MARK: 拡張音源を持つマッパーの定義
+type audioMapper interface {
+	// 拡張音源の出力 (APUの出力と同じスケール)
+	AudioOutput() float32
+}
+
+// MARK: PRG-RAM以外にバッテリーバックアップされたメモリを持つマッパーの定義
+type batteryMapper interface {
+	// セーブファイルにはPRG-RAMとCHR-RAMの後に保存される (保存するメモリがない場合はnil)
+	BatteryData() []uint8
+}
+
+// MARK: ヘッダのPRG-NVRAMを内蔵のメモリ (EEPROMなど) として扱うマッパーの定義
+type nvramMapper interface {
+	batteryMapper
+
+	// trueの場合はPRG-RAMを保存せず、BatteryDataのみをバッテリーの有無に関わらず保存する
+	ReplacesPRGNVRAM() bool
+}
+
+// MARK: マッパー番号からマッパーを生成する関数
+func newMapper(c *Cartridge) (Mapper, error) {
+	switch c.Header.Mapper {
+	case 0:
+		return newNROM(c), nil
+	case 1:
+		return newMMC1(c), nil
+	case 2:
+		return newUxROM(c), nil
+	case 3:
+		return newCNROM(c), nil
+	case 4:
+		if c.Header.Submapper == MMC3_SUBMAPPER_MMC6 {
+			return newMMC6(c), nil
+		}
+		return newMMC3(c), nil
+	case 5:
+		return newMMC5(c), nil
+	case 7:
+		return newAxROM(c), nil
+	case 9:
+		return newMMC2(c), nil
+	case 10:
+		return newMMC4(c), nil
+	case 16, 153, 159:
+		return newBandai(c), nil
+	case 19:
+		return newNamco163(c), nil
+	case 21, 22, 23, 25:
+		return newVRC4(c), nil
+	case 24, 26:
+		return newVRC6(c), nil
+	case 69:
+		return newFME7(c), nil
+	case 85:
+		return newVRC7(c), nil
+	case 118:
+		return newTxSROM(c), nil
+	case 119:
+		return newTQROM(c), nil
+	default:
+		return nil, fmt.Errorf("%w %d", ErrUnsupportedMapper, c.Header.Mapper)
+	}
+}
+
+// MARK: マッパー共通の実装の定義
+type baseMapper struct {
+	cartridge *Cartridge
+
+	prgBanks    [PRG_BANK_SLOTS]int  // 各8kBスロットに割り当てられたPRG-ROMのオフセット
+	chrBanks    [CHR_BANK_SLOTS]int  // 各1kBスロットに割り当てられたCHRのオフセット
+	chrRAMSlots [CHR_BANK_SLOTS]bool // CHR-ROMを持つ基板でCHR-RAMを割り当てたスロット (TQROM)
+
+	chr         []uint8 // CHR-ROM または CHR-RAM
+	chrWritable bool    // CHR-RAMの場合はtrue
+
+	mirroring Mirroring
+
+	prgRAMBank     int  // $6000-$7FFFに割り当てられたPRG-RAMのバンク (8kB単位)
+	prgRAMEnabled  bool // PRG-RAMの有効化
+	prgRAMWritable bool // PRG-RAMの書き込み保護の解除
+}
+
+// MARK: マッパー共通の実装のコンストラクタ
+func newBaseMapper(c *Cartridge) baseMapper {
+	m := baseMapper{
+		cartridge:      c,
+		chr:            c.CHRROM,
+		mirroring:      c.Header.Mirroring,
+		prgRAMEnabled:  true,
+		prgRAMWritable: true,
+	}
+
+	if len(m.chr) == 0 {
+		if len(c.CHRRAM) == 0 {
+			c.CHRRAM = make([]uint8, INES_DEFAULT_CHR_RAM_SIZE) // ヘッダにサイズがない場合も8kBは確保する
+		}
+		m.chr = c.CHRRAM
+		m.chrWritable = true
+	}
+
+	// 電源投入時は先頭から順に割り当てておく (各マッパーが上書きする)
+	m.setPRGBank32k(0)
+	m.setCHRBank8k(0)
+	return m
+}
+
+// MARK: バンク番号からオフセットを算出する関数
+func bankOffset(bank int, bankSize int, memorySize int) int {
+	// 実装されていない上位ビットは無視される (メモリサイズでラップアラウンド)
+	// 負の値は末尾からのバンク番号として扱う (-1 は最後のバンク)
+	banks := max(memorySize/bankSize, 1)
+	bank %= banks
+	if bank < 0 {
+		bank += banks
+	}
+	return bank * bankSize
+}
+
+// MARK: PRG-ROMのバンク切り替え
+func (m *baseMapper) setPRGBank8k(slot int, bank int) {
+	m.prgBanks[slot] = bankOffset(bank, PRG_BANK_SIZE, len(m.cartridge.PRGROM))
+}
+
+func (m *baseMapper) setPRGBank16k(slot int, bank int) {
+	// slot は 0 ($8000) または 1 ($C000)
+	offset := bankOffset(bank, 2*PRG_BANK_SIZE, len(m.cartridge.PRGROM))
+	m.prgBanks[slot*2] = offset
+	m.prgBanks[slot*2+1] = (offset + PRG_BANK_SIZE) % len(m.cartridge.PRGROM)
+}
+
+func (m *baseMapper) setPRGBank32k(bank int) {
+	offset := bankOffset(bank, 4*PRG_BANK_SIZE, len(m.cartridge.PRGROM))
+	for slot := range PRG_BANK_SLOTS {
+		// 32kBに満たないROMはミラーリングされる
+		m.prgBanks[slot] = (offset + slot*PRG_BANK_SIZE) % len(m.cartridge.PRGROM)
+	}
+}
+
+// MARK: CHRのバンク切り替え
+func (m *baseMapper) setCHRBank1k(slot int, bank int) {
+	m.chrBanks[slot] = bankOffset(bank, CHR_BANK_SIZE, len(m.chr))
+	m.chrRAMSlots[slot] = false
+}
+
+func (m *baseMapper) setCHRBank2k(slot int, bank int) {
+	// slot は 0-3 (2kB単位)
+	offset := bankOffset(bank, 2*CHR_BANK_SIZE, len(m.chr))
+	for i := range 2 {
+		m.chrBanks[slot*2+i] = (offset + i*CHR_BANK_SIZE) % len(m.chr)
+		m.chrRAMSlots[slot*2+i] = false
+	}
+}
+
+func (m *baseMapper) setCHRBank4k(slot int, bank int) {
+	// slot は 0 ($0000) または 1 ($1000)
+	offset := bankOffset(bank, 4*CHR_BANK_SIZE, len(m.chr))
+	for i := range 4 {
+		m.chrBanks[slot*4+i] = (offset + i*CHR_BANK_SIZE) % len(m.chr)
+		m.chrRAMSlots[slot*4+i] = false
+	}
+}
+
+func (m *baseMapper) setCHRBank8k(bank int) {
+	offset := bankOffset(bank, 8*CHR_BANK_SIZE, len(m.chr))
+	for slot := range CHR_BANK_SLOTS {
+		m.chrBanks[slot] = (offset + slot*CHR_BANK_SIZE) % len(m.chr)
+		m.chrRAMSlots[slot] = false
+	}
+}
+
+// MARK: CHR-ROMとCHR-RAMを併せ持つ基板でCHR-RAMを割り当てる
+func (m *baseMapper) setCHRRAMBank1k(slot int, bank int) {
+	m.chrBanks[slot] = bankOffset(bank, CHR_BANK_SIZE, len(m.cartridge.CHRRAM))
+	m.chrRAMSlots[slot] = true
+}
+
+// MARK: PRG-ROMのアドレスを解決するメソッド ($8000-$FFFF)
+func (m *baseMapper) prgROMIndex(address uint16) int {
+	slot := int(address-CPU_PRG_ROM_START) / PRG_BANK_SIZE
+	return m.prgBanks[slot] + int(address)%PRG_BANK_SIZE
+}
+
+// MARK: CHRのアドレスを解決するメソッド ($0000-$1FFF)
+func (m *baseMapper) chrIndex(address uint16) (memory []uint8, index int, writable bool) {
+	address &= PPU_PATTERN_TABLE_END
+	slot := int(address) / CHR_BANK_SIZE
+	index = m.chrBanks[slot] + int(address)%CHR_BANK_SIZE
+	if m.chrRAMSlots[slot] {
+		return m.cartridge.CHRRAM, index, true
+	}
+	return m.chr, index, m.chrWritable
+}
+
+// MARK: PRG-RAMのアドレスを解決するメソッド ($6000-$7FFF)
+func (m *baseMapper) prgRAMIndex(address uint16) (int, bool) {
+	size := len(m.cartridge.PRGRAM)
+	if size == 0 {
+		return 0, false
+	}
+	// 8kB未満のPRG-RAMは$6000-$7FFFでミラーリングされる
+	offset := bankOffset(m.prgRAMBank, PRG_BANK_SIZE, size)
+	return (offset + int(address-CPU_PRG_RAM_START)) % size, true
+}
+
+// MARK: PRG-RAMの読み取り ($6000-$7FFF)
+func (m *baseMapper) readPRGRAM(address uint16) (uint8, bool) {
+	index, ok := m.prgRAMIndex(address)
+	if !ok || !m.prgRAMEnabled {
+		return 0x00, false
+	}
+	return m.cartridge.PRGRAM[index], true
+}
+
+// MARK: PRG-RAMへの書き込み ($6000-$7FFF)
+func (m *baseMapper) writePRGRAM(address uint16, value uint8) {
+	index, ok := m.prgRAMIndex(address)
+	if ok && m.prgRAMEnabled && m.prgRAMWritable {
+		m.cartridge.writePRGRAM(index, value)
+	}
+}
+
+// MARK: バスの競合を再現するメソッド
+func (m *baseMapper) busConflict(address uint16, value uint8) uint8 {
+	// ROMの出力を無効化しない基板では、書き込んだ値とそのアドレスのROMの値が同時に
+	// データバスへ出力され、結果としてANDを取った値がレジスタに書き込まれる
+	if m.cartridge.Header.Submapper != DISCRETE_SUBMAPPER_BUS_CONFLICTS {
+		return value
+	}
+	return value & m.cartridge.PRGROM[m.prgROMIndex(address)]
+}
+
+// MARK: CPUからの読み取り
+func (m *baseMapper) ReadPRG(address uint16) (uint8, bool) {
+	return m.PeekPRG(address)
+}
+
+// MARK: CPUからの書き込み
+func (m *baseMapper) WritePRG(address uint16, value uint8) {
+	if CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END {
+		m.writePRGRAM(address, value)
+	}
+}
+
+// MARK: CPUからの読み取り (副作用なし)
+func (m *baseMapper) PeekPRG(address uint16) (uint8, bool) {
+	switch {
+	case CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END:
+		return m.readPRGRAM(address)
+	case CPU_PRG_ROM_START <= address:
+		return m.cartridge.PRGROM[m.prgROMIndex(address)], true
+	default:
+		return 0x00, false
+	}
+}
+
+// MARK: CPUからの書き込み (副作用なし)
+func (m *baseMapper) PokePRG(address uint16, value uint8) {
+	// ツールからは現在のバンクのROMの内容や書き込み保護されたRAMも書き換えられるようにする
+	switch {
+	case CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END:
+		if index, ok := m.prgRAMIndex(address); ok {
+			m.cartridge.writePRGRAM(index, value)
+		}
+	case CPU_PRG_ROM_START <= address:
+		m.cartridge.PRGROM[m.prgROMIndex(address)] = value
+	}
+}
+
+// MARK: PPUからの読み取り
+func (m *baseMapper) ReadCHR(address uint16) uint8 {
+	return m.PeekCHR(address)
+}
+
+// MARK: PPUからの書き込み
+func (m *baseMapper) WriteCHR(address uint16, value uint8) {
+	// 書き込み可能なメモリは常にカートリッジのCHR-RAM
+	if _, index, writable := m.chrIndex(address); writable {
+		m.cartridge.writeCHRRAM(index, value)
+	}
+}
+
+// MARK: PPUからの読み取り (副作用なし)
+func (m *baseMapper) PeekCHR(address uint16) uint8 {
+	memory, index, _ := m.chrIndex(address)
+	return memory[index]
+}
+
+// MARK: ミラーリングの取得
+func (m *baseMapper) Mirroring() Mirroring {
+	return m.mirroring
+}
+
+// MARK: IRQの取得
+func (m *baseMapper) IRQ() bool {
+	return false
+}
+
+// MARK: CPUサイクルの通知
+func (m *baseMapper) Tick() {
+}
+
+// MARK: スキャンラインの通知
+func (m *baseMapper) Scanline() {
+}
+
+// MARK: PPUのアドレスの通知
+func (m *baseMapper) PPUAddress(address uint16) {
+}
@@ -0,0 +1,110 @@
+package cartridge
+
+import "testing"
+
+// MARK: テスト用の基板の定義
+type testBoard struct {
+	mapper    uint16
+	submapper uint8
+	prgROM    int // 各8kBバンクはバンク番号で埋める
+	chrROM    int // 各1kBバンクはバンク番号で埋める (0の場合は8kBのCHR-RAM)
+	prgRAM    int
+	prgNVRAM  int
+	chrNVRAM  int // 0以外の場合はCHR-ROMの代わりにバッテリーバックアップされたCHR-RAMを持つ
+	mirroring Mirroring
+}
+
+// MARK: テスト用のカートリッジ
+func newTestCartridge(t testing.TB, b testBoard) *Cartridge {
+	t.Helper()
+	header := Header{
+		Format:       FormatNES20,
+		Mapper:       b.mapper,
+		Submapper:    b.submapper,
+		PRGROMSize:   b.prgROM,
+		CHRROMSize:   b.chrROM,
+		PRGRAMSize:   b.prgRAM,
+		PRGNVRAMSize: b.prgNVRAM,
+		CHRNVRAMSize: b.chrNVRAM,
+		Battery:      b.prgNVRAM > 0 || b.chrNVRAM > 0,
+		Mirroring:    b.mirroring,
+	}
+	if b.chrROM == 0 && b.chrNVRAM == 0 {
+		header.CHRRAMSize = CHR_ROM_BANK_SIZE
+	}
+
+	prgROM := make([]uint8, b.prgROM)
+	for i := range prgROM {
+		prgROM[i] = uint8(i / PRG_BANK_SIZE)
+	}
+	chrROM := make([]uint8, b.chrROM)
+	for i := range chrROM {
+		chrROM[i] = uint8(i / CHR_BANK_SIZE)
+	}
+
+	c, err := newCartridge(header, prgROM, chrROM, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// MARK: PRG-ROMのみのテスト用のカートリッジ
+func testCartridge(t testing.TB, mapper uint16, prgROMSize int, prgRAMSize int) *Cartridge {
+	t.Helper()
+	return newTestCartridge(t, testBoard{mapper: mapper, prgROM: prgROMSize, prgRAM: prgRAMSize})
+}
+
+// MARK: CPUのアドレスに割り当てられた8kBのPRG-ROMのバンク番号
+func prgBankAt(t testing.TB, c *Cartridge, address uint16) int {
+	t.Helper()
+	value, driven := c.PeekByteFrom(address)
+	if driven != DRIVEN_ALL_BITS {
+		t.Fatalf("$%04X is not driven", address)
+	}
+	return int(value)
+}
+
+// MARK: PPUのアドレスに割り当てられた1kBのCHR-ROMのバンク番号
+func chrBankAt(c *Cartridge, address uint16) int {
+	return int(c.PeekCHR(address))
+}
+
+// MARK: MMC5でPRG-RAMが割り当てられた$8000-$DFFFへのPokeのテスト
+func TestMMC5PokePRGRAMSlot(t *testing.T) {
+	c := testCartridge(t, 5, 128*1024, 64*1024)
+	c.WriteByteAt(0x5100, 0x03) // PRGモード3 (8kB x4)
+	c.WriteByteAt(0x5114, 0x01) // $8000-$9FFF: PRG-RAMのバンク1
+
+	rom := cloneBytes(c.PRGROM)
+	c.PokeByteAt(0x8010, 0x77)
+	if got := c.PRGRAM[PRG_BANK_SIZE+0x10]; got != 0x77 {
+		t.Fatalf("PRG-RAM = $%02X, want $77", got)
+	}
+	if got, _ := c.PeekByteFrom(0x8010); got != 0x77 {
+		t.Fatalf("PeekByteFrom($8010) = $%02X, want $77", got)
+	}
+	for i, b := range c.PRGROM {
+		if b != rom[i] {
+			t.Fatalf("PRG-ROM[$%X] was overwritten with $%02X", i, b)
+		}
+	}
+}
+
+// MARK: FME-7で$6000-$7FFFにPRG-ROMが割り当てられている場合のPokeのテスト
+func TestFME7PokePRGROMAt6000(t *testing.T) {
+	c := testCartridge(t, 69, 128*1024, 8*1024)
+	c.WriteByteAt(0x8000, 0x08) // コマンド8: $6000-$7FFF
+	c.WriteByteAt(0xA000, 0x02) // PRG-ROMのバンク2
+
+	c.PokeByteAt(0x6010, 0x55)
+	if got := c.PRGROM[2*PRG_BANK_SIZE+0x10]; got != 0x55 {
+		t.Fatalf("PRG-ROM = $%02X, want $55", got)
+	}
+	if got, _ := c.PeekByteFrom(0x6010); got != 0x55 {
+		t.Fatalf("PeekByteFrom($6010) = $%02X, want $55", got)
+	}
+	if got := c.PRGRAM[0x10]; got != 0 {
+		t.Fatalf("PRG-RAM was overwritten with $%02X", got)
+	}
+}
@@ -0,0 +1,152 @@
+package cartridge
+
+const (
+	MMC1_SHIFT_RESET     = 0x80 // bit7が立った値の書き込みでシフトレジスタをリセット
+	MMC1_SHIFT_INITIAL   = 0x10 // 5回目の書き込みでbit0に1が到達する
+	MMC1_CONTROL_INITIAL = 0x0C // PRGモード3 (最後のバンクを$C000に固定)
+
+	MMC1_PRG_OUTER_BANK_SIZE = 256 * 1024 // SUROM / SXROM の PRG-ROM の外側のバンク
+	MMC1_SUBMAPPER_FIXED_PRG = 5          // SEROM / SHROM (PRGのバンク切り替えなし)
+)
+
+// MARK: MMC1 (マッパー1, SxROM) の定義
+type mmc1 struct {
+	baseMapper
+
+	shift   uint8 // 5bitのシリアルシフトレジスタ
+	control uint8 // $8000-$9FFF
+	chr0    uint8 // $A000-$BFFF
+	chr1    uint8 // $C000-$DFFF
+	prg     uint8 // $E000-$FFFF
+
+	cycles          uint64 // 電源投入からのCPUサイクル数
+	lastWriteCycles uint64 // 最後にシリアルポートへ書き込んだCPUサイクル数
+	written         bool   // 一度でもシリアルポートへ書き込んだか
+
+	fixedPRG bool // SEROM / SHROM
+}
+
+// MARK: MMC1のコンストラクタ
+func newMMC1(c *Cartridge) *mmc1 {
+	m := &mmc1{
+		baseMapper: newBaseMapper(c),
+		shift:      MMC1_SHIFT_INITIAL,
+		control:    MMC1_CONTROL_INITIAL,
+		fixedPRG:   c.Header.Submapper == MMC1_SUBMAPPER_FIXED_PRG,
+	}
+	m.updateBanks()
+	return m
+}
+
+// MARK: CPUサイクルの通知
+func (m *mmc1) Tick() {
+	m.cycles++
+}
+
+// MARK: CPUからの書き込み
+func (m *mmc1) WritePRG(address uint16, value uint8) {
+	if address < CPU_PRG_ROM_START {
+		m.baseMapper.WritePRG(address, value)
+		return
+	}
+
+	// リードモディファイライト命令による連続したサイクルの書き込みは2回目以降が無視される
+	consecutive := m.written && m.cycles-m.lastWriteCycles <= 1
+	m.lastWriteCycles = m.cycles
+	m.written = true
+	if consecutive {
+		return
+	}
+
+	if value&MMC1_SHIFT_RESET != 0 {
+		m.shift = MMC1_SHIFT_INITIAL
+		m.control |= MMC1_CONTROL_INITIAL
+		m.updateBanks()
+		return
+	}
+
+	// LSBから順に1bitずつシフトレジスタへ送る
+	complete := m.shift&0x01 != 0
+	m.shift = m.shift>>1 | (value&0x01)<<4
+	if !complete {
+		return
+	}
+
+	// 5回目の書き込みのアドレス (bit13-14) で書き込み先のレジスタが決まる
+	switch (address >> 13) & 0x03 {
+	case 0:
+		m.control = m.shift
+	case 1:
+		m.chr0 = m.shift
+	case 2:
+		m.chr1 = m.shift
+	case 3:
+		m.prg = m.shift
+	}
+	m.shift = MMC1_SHIFT_INITIAL
+	m.updateBanks()
+}
+
+// MARK: レジスタの値をバンクの割り当てに反映する
+func (m *mmc1) updateBanks() {
+	/*
+		コントロールレジスタ ($8000)
+		bit0-1: ミラーリング (0: 1画面下位, 1: 1画面上位, 2: 垂直, 3: 水平)
+		bit2-3: PRGモード (0, 1: 32kB切り替え, 2: $8000を先頭に固定, 3: $C000を末尾に固定)
+		bit4:   CHRモード (0: 8kB切り替え, 1: 4kB x2 切り替え)
+	*/
+	switch m.control & 0x03 {
+	case 0:
+		m.mirroring = MirroringSingleScreenLower
+	case 1:
+		m.mirroring = MirroringSingleScreenUpper
+	case 2:
+		m.mirroring = MirroringVertical
+	case 3:
+		m.mirroring = MirroringHorizontal
+	}
+
+	// CHR
+	if m.control&0x10 == 0 {
+		m.setCHRBank8k(int(m.chr0 >> 1))
+	} else {
+		m.setCHRBank4k(0, int(m.chr0))
+		m.setCHRBank4k(1, int(m.chr1))
+	}
+
+	// PRG
+	// 512kBのPRG-ROMを持つボード (SUROM / SXROM) ではCHRレジスタのbit4が256kBの外側のバンクを選ぶ
+	// (外側のバンクは16kBのバンク16個分なので、bit4の値がそのまま16kB単位のオフセットになる)
+	outer := 0
+	if len(m.cartridge.PRGROM) > MMC1_PRG_OUTER_BANK_SIZE {
+		outer = int(m.chr0 & 0x10)
+	}
+	bank := int(m.prg & 0x0F)
+	lastBank := 0x0F
+
+	switch {
+	case m.fixedPRG:
+		m.setPRGBank32k(0)
+	case m.control&0x08 == 0:
+		m.setPRGBank32k((outer + bank) >> 1)
+	case m.control&0x04 == 0:
+		m.setPRGBank16k(0, outer)
+		m.setPRGBank16k(1, outer+bank)
+	default:
+		m.setPRGBank16k(0, outer+bank)
+		m.setPRGBank16k(1, outer+lastBank)
+	}
+
+	// PRG-RAM
+	// SXROM (32kB) はCHRレジスタのbit2-3, SOROM (16kB) はbit3でバンクを選ぶ
+	// (4kB CHRモードでは本来PPUが最後に参照したバンクのレジスタが使われるが、$0000側で代表させる)
+	switch {
+	case len(m.cartridge.PRGRAM) > 2*PRG_BANK_SIZE:
+		m.prgRAMBank = int(m.chr0>>2) & 0x03
+	case len(m.cartridge.PRGRAM) > PRG_BANK_SIZE:
+		m.prgRAMBank = int(m.chr0>>3) & 0x01
+	default:
+		m.prgRAMBank = 0
+	}
+	m.prgRAMEnabled = m.prg&0x10 == 0 // MMC1B以降: 0で有効
+}
@@ -0,0 +1,109 @@
+package cartridge
+
+const (
+	MMC2_LATCH_FD = 0xFD
+	MMC2_LATCH_FE = 0xFE
+)
+
+// MARK: MMC2 (マッパー9, PxROM) / MMC4 (マッパー10, FxROM) の定義
+type mmc2 struct {
+	baseMapper
+
+	prg     uint8       // $A000
+	chr     [2][2]uint8 // $B000-$E000 (パターンテーブルごとにラッチが$FD / $FEの場合のバンク)
+	latches [2]uint8    // $0000-$0FFF / $1000-$1FFF のラッチ ($FD / $FE)
+
+	mmc4 bool // PRGを16kB単位で切り替え、ラッチの範囲が8バイトに広がる
+}
+
+// MARK: MMC2のコンストラクタ
+func newMMC2(c *Cartridge) *mmc2 {
+	/*
+		$8000-$9FFF: 8kB 切り替え可能なバンク
+		$A000-$FFFF: 最後の3つの8kBバンクに固定
+		CHRは4kB単位で、PPUが$FD / $FEのタイルを読み取るとバンクが切り替わる
+	*/
+	m := &mmc2{
+		baseMapper: newBaseMapper(c),
+		latches:    [2]uint8{MMC2_LATCH_FE, MMC2_LATCH_FE},
+	}
+	m.updateBanks()
+	return m
+}
+
+// MARK: CPUからの書き込み
+func (m *mmc2) WritePRG(address uint16, value uint8) {
+	if address < CPU_PRG_ROM_START {
+		m.baseMapper.WritePRG(address, value)
+		return
+	}
+
+	switch address & 0xF000 {
+	case 0xA000:
+		m.prg = value & 0x0F
+	case 0xB000:
+		m.chr[0][0] = value & 0x1F
+	case 0xC000:
+		m.chr[0][1] = value & 0x1F
+	case 0xD000:
+		m.chr[1][0] = value & 0x1F
+	case 0xE000:
+		m.chr[1][1] = value & 0x1F
+	case 0xF000:
+		if value&0x01 == 0 {
+			m.mirroring = MirroringVertical
+		} else {
+			m.mirroring = MirroringHorizontal
+		}
+		return
+	default:
+		return
+	}
+	m.updateBanks()
+}
+
+// MARK: バンクの更新
+func (m *mmc2) updateBanks() {
+	if m.mmc4 {
+		m.setPRGBank16k(0, int(m.prg))
+		m.setPRGBank16k(1, -1)
+	} else {
+		m.setPRGBank8k(0, int(m.prg))
+		m.setPRGBank8k(1, -3)
+		m.setPRGBank8k(2, -2)
+		m.setPRGBank8k(3, -1)
+	}
+
+	for table, latch := range m.latches {
+		bank := m.chr[table][0]
+		if latch == MMC2_LATCH_FE {
+			bank = m.chr[table][1]
+		}
+		m.setCHRBank4k(table, int(bank))
+	}
+}
+
+// MARK: PPUからの読み取り ($0000-$1FFF)
+func (m *mmc2) ReadCHR(address uint16) uint8 {
+	// 読み取り自体は切り替え前のバンクから行われ、次の読み取りから新しいバンクになる
+	value := m.PeekCHR(address)
+
+	/*
+		$0FD8 (MMC4: $0FD8-$0FDF): ラッチ0を$FDに
+		$0FE8 (MMC4: $0FE8-$0FEF): ラッチ0を$FEに
+		$1FD8-$1FDF: ラッチ1を$FDに
+		$1FE8-$1FEF: ラッチ1を$FEに
+	*/
+	address &= PPU_PATTERN_TABLE_END
+	table := int(address >> 12)
+	tile := uint8(address >> 4)
+	row := address & 0x0F
+	wideRange := table == 1 || m.mmc4 // $xFD8-$xFDF / $xFE8-$xFEF の8バイト
+	if (tile == MMC2_LATCH_FD || tile == MMC2_LATCH_FE) && (row == 0x08 || wideRange && row > 0x08) {
+		if m.latches[table] != tile {
+			m.latches[table] = tile
+			m.updateBanks()
+		}
+	}
+	return value
+}
@@ -0,0 +1,102 @@
+package cartridge
+
+import "testing"
+
+// MARK: CHRのバンクを設定したMMC2 / MMC4のテスト用のカートリッジ
+func testMMC2(t *testing.T, mapper uint16) *Cartridge {
+	t.Helper()
+	c := newTestCartridge(t, testBoard{mapper: mapper, prgROM: 128 * 1024, chrROM: 128 * 1024})
+	c.WriteByteAt(0xB000, 1) // $0000-$0FFF, ラッチ$FD
+	c.WriteByteAt(0xC000, 2) // $0000-$0FFF, ラッチ$FE
+	c.WriteByteAt(0xD000, 3) // $1000-$1FFF, ラッチ$FD
+	c.WriteByteAt(0xE000, 4) // $1000-$1FFF, ラッチ$FE
+	return c
+}
+
+// MARK: パターンテーブルに割り当てられた4kBバンクの番号
+func chr4kBankAt(c *Cartridge, address uint16) int {
+	return chrBankAt(c, address) / 4
+}
+
+// MARK: ラッチによるCHRバンクの切り替えのテスト
+func TestMMC2Latches(t *testing.T) {
+	tests := []struct {
+		name   string
+		mapper uint16
+		reads  []uint16 // 順に読み取るアドレス
+		low    int      // 読み取り後の$0000-$0FFFのバンク
+		high   int      // 読み取り後の$1000-$1FFFのバンク
+	}{
+		{"power-on", 9, nil, 2, 4},
+		{"MMC2 $0FD8", 9, []uint16{0x0FD8}, 1, 4},
+		{"MMC2 $0FD8 then $0FE8", 9, []uint16{0x0FD8, 0x0FE8}, 2, 4},
+		// MMC2の$0000-$0FFFは$0FD8 / $0FE8のみで切り替わる
+		{"MMC2 $0FD9", 9, []uint16{0x0FD9}, 2, 4},
+		{"MMC2 $0FDF", 9, []uint16{0x0FDF}, 2, 4},
+		{"MMC2 $1FD8", 9, []uint16{0x1FD8}, 2, 3},
+		{"MMC2 $1FDF", 9, []uint16{0x1FDF}, 2, 3},
+		{"MMC2 $1FDF then $1FEF", 9, []uint16{0x1FDF, 0x1FEF}, 2, 4},
+		{"MMC2 $1FD7", 9, []uint16{0x1FD7}, 2, 4},
+		{"MMC2 $1FC8", 9, []uint16{0x1FC8}, 2, 4},
+		// MMC4は両方のパターンテーブルで8バイトの範囲
+		{"MMC4 $0FD8", 10, []uint16{0x0FD8}, 1, 4},
+		{"MMC4 $0FDF", 10, []uint16{0x0FDF}, 1, 4},
+		{"MMC4 $0FDF then $0FEC", 10, []uint16{0x0FDF, 0x0FEC}, 2, 4},
+		{"MMC4 $0FD7", 10, []uint16{0x0FD7}, 2, 4},
+		{"MMC4 $1FDA", 10, []uint16{0x1FDA}, 2, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testMMC2(t, tt.mapper)
+			for _, address := range tt.reads {
+				c.ReadCHR(address)
+			}
+			if got := chr4kBankAt(c, 0x0000); got != tt.low {
+				t.Errorf("$0000 = 4kB bank %d, want %d", got, tt.low)
+			}
+			if got := chr4kBankAt(c, 0x1000); got != tt.high {
+				t.Errorf("$1000 = 4kB bank %d, want %d", got, tt.high)
+			}
+		})
+	}
+}
+
+// MARK: ラッチを切り替えた読み取り自体は切り替え前のバンクから行われるかのテスト
+func TestMMC2LatchAppliesAfterRead(t *testing.T) {
+	c := testMMC2(t, 9)
+
+	// $0FD8は4kBバンク2の4つ目の1kB (1kBバンク11)
+	if got := c.ReadCHR(0x0FD8); got != 11 {
+		t.Fatalf("latching read = $%02X, want $0B from the old bank", got)
+	}
+	if got := c.ReadCHR(0x0FD8); got != 7 {
+		t.Fatalf("next read = $%02X, want $07 from the new bank", got)
+	}
+
+	// Peekはラッチを切り替えない
+	c.PeekCHR(0x0FE8)
+	if got := chr4kBankAt(c, 0x0000); got != 1 {
+		t.Fatalf("PeekCHR switched the latch: bank %d, want 1", got)
+	}
+}
+
+// MARK: MMC2 / MMC4のPRGバンクのテスト
+func TestMMC2PRGBanks(t *testing.T) {
+	// MMC2: $8000の8kBのみ切り替え可能
+	c := testMMC2(t, 9)
+	c.WriteByteAt(0xA000, 0x05)
+	for address, want := range map[uint16]int{0x8000: 5, 0xA000: 13, 0xC000: 14, 0xE000: 15} {
+		if got := prgBankAt(t, c, address); got != want {
+			t.Errorf("MMC2 $%04X = 8kB bank %d, want %d", address, got, want)
+		}
+	}
+
+	// MMC4: $8000の16kBを切り替え、$C000は最後の16kBに固定
+	c = testMMC2(t, 10)
+	c.WriteByteAt(0xA000, 0x03)
+	for address, want := range map[uint16]int{0x8000: 6, 0xA000: 7, 0xC000: 14, 0xE000: 15} {
+		if got := prgBankAt(t, c, address); got != want {
+			t.Errorf("MMC4 $%04X = 8kB bank %d, want %d", address, got, want)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+package cartridge
+
+const (
+	MMC3_SUBMAPPER_MMC6  = 1 // HKROM
+	MMC3_SUBMAPPER_MMC3A = 4 // NEC製のMMC3A (旧来のIRQの挙動)
+
+	MMC3_A12_FILTER_CYCLES = 3 // A12がこのCPUサイクル数以上Lowだった場合のみ立ち上がりを数える
+	PPU_A12                = 1 << 12
+)
+
+// MARK: MMC3 (マッパー4, TxROM) の定義
+type mmc3 struct {
+	baseMapper
+
+	bankSelect uint8    // $8000
+	registers  [8]uint8 // R0-R7 ($8001)
+
+	// IRQ
+	irqLatch   uint8 // $C000
+	irqCounter uint8
+	irqReload  bool // $C001
+	irqEnabled bool // $E000 / $E001
+	irqPending bool
+	oldIRQ     bool // MMC3A: カウンタを0へ再ロードした場合はIRQを発生させない
+
+	// A12の立ち上がりの検出
+	a12        bool
+	a12LowTime uint64 // A12がLowになってからのCPUサイクル数
+
+	// 基板ごとの差異 (MMC6 / TxSROM / TQROM)
+	board         mmc3Board
+	mmc6Candidate bool // $8000 bit5 (MMC6のPRG-RAMの有効化) が書き込まれた時点でMMC6とみなす
+}
+
+// MARK: MMC3を使用する基板ごとの差異の定義
+type mmc3Board interface {
+	// CHRのバンク (1kB単位) を割り当てる
+	applyCHRBanks(m *mmc3, banks [CHR_BANK_SLOTS]int)
+	// ミラーリングレジスタ ($A000) への書き込み
+	writeMirroring(m *mmc3, value uint8)
+	// PRG-RAMのアクセス ($6000-$7FFF) と保護レジスタ ($A001)
+	readPRGRAM(m *mmc3, address uint16) (uint8, bool)
+	writePRGRAM(m *mmc3, address uint16, value uint8)
+	writePRGRAMProtect(m *mmc3, value uint8)
+	// ネームテーブルに割り当てるCIRAMのページ
+	nametablePage(m *mmc3, table int) int
+}
+
+// MARK: MMC3のコンストラクタ
+func newMMC3(c *Cartridge) *mmc3 {
+	m := newMMC3WithBoard(c, txrom{})
+	m.mmc6Candidate = mmc6Candidate(c.Header)
+	return m
+}
+
+// MARK: 基板を指定するMMC3のコンストラクタ
+func newMMC3WithBoard(c *Cartridge, board mmc3Board) *mmc3 {
+	m := &mmc3{
+		baseMapper: newBaseMapper(c),
+		oldIRQ:     c.Header.Submapper == MMC3_SUBMAPPER_MMC3A,
+		board:      board,
+	}
+	m.updatePRGBanks()
+	m.updateCHRBanks()
+	return m
+}
+
+// MARK: CPUからの読み取り
+func (m *mmc3) ReadPRG(address uint16) (uint8, bool) {
+	return m.PeekPRG(address)
+}
+
+// MARK: CPUからの読み取り (副作用なし)
+func (m *mmc3) PeekPRG(address uint16) (uint8, bool) {
+	if CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END {
+		return m.board.readPRGRAM(m, address)
+	}
+	return m.baseMapper.PeekPRG(address)
+}
+
+// MARK: CPUからの書き込み
+func (m *mmc3) WritePRG(address uint16, value uint8) {
+	/*
+		$8000 (偶数) バンクセレクト
+		$8001 (奇数) バンクデータ
+		$A000 (偶数) ミラーリング
+		$A001 (奇数) PRG-RAMの保護
+		$C000 (偶数) IRQラッチ
+		$C001 (奇数) IRQリロード
+		$E000 (偶数) IRQ無効化 (と保留中のIRQの解除)
+		$E001 (奇数) IRQ有効化
+	*/
+	if address < CPU_PRG_ROM_START {
+		if CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END {
+			m.board.writePRGRAM(m, address, value)
+		}
+		return
+	}
+
+	odd := address&0x01 != 0
+	switch address & 0xE000 {
+	case 0x8000:
+		if odd {
+			m.registers[m.bankSelect&0x07] = value
+		} else {
+			m.bankSelect = value
+			if m.mmc6Candidate && value&MMC6_PRG_RAM_ENABLE != 0 {
+				// MMC3ではbit5は使われないため、書き込むゲームはMMC6を前提としている
+				m.board = &mmc6{}
+				m.mmc6Candidate = false
+			}
+		}
+		m.updatePRGBanks()
+		m.updateCHRBanks()
+	case 0xA000:
+		if odd {
+			m.board.writePRGRAMProtect(m, value)
+		} else {
+			m.board.writeMirroring(m, value)
+		}
+	case 0xC000:
+		if odd {
+			m.irqCounter = 0
+			m.irqReload = true
+		} else {
+			m.irqLatch = value
+		}
+	case 0xE000:
+		if odd {
+			m.irqEnabled = true
+		} else {
+			m.irqEnabled = false
+			m.irqPending = false
+		}
+	}
+}
+
+// MARK: PRG-ROMのバンクの更新
+func (m *mmc3) updatePRGBanks() {
+	// PRGモード (bit6) が1の場合は$8000と$C000の役割が入れ替わる
+	if m.bankSelect&0x40 == 0 {
+		m.setPRGBank8k(0, int(m.registers[6]))
+		m.setPRGBank8k(2, -2)
+	} else {
+		m.setPRGBank8k(0, -2)
+		m.setPRGBank8k(2, int(m.registers[6]))
+	}
+	m.setPRGBank8k(1, int(m.registers[7]))
+	m.setPRGBank8k(3, -1)
+}
+
+// MARK: CHRのバンクの更新
+func (m *mmc3) updateCHRBanks() {
+	// R0, R1 は2kB (下位bitは無視), R2-R5 は1kB
+	// CHR反転 (bit7) が1の場合は$0000と$1000の役割が入れ替わる
+	r := m.registers
+	banks := [CHR_BANK_SLOTS]int{
+		int(r[0] & 0xFE), int(r[0] | 0x01),
+		int(r[1] & 0xFE), int(r[1] | 0x01),
+		int(r[2]), int(r[3]), int(r[4]), int(r[5]),
+	}
+	if m.bankSelect&0x80 != 0 {
+		banks = [CHR_BANK_SLOTS]int{
+			banks[4], banks[5], banks[6], banks[7],
+			banks[0], banks[1], banks[2], banks[3],
+		}
+	}
+	m.board.applyCHRBanks(m, banks)
+}
+
+// MARK: ネームテーブルに割り当てるCIRAMのページの取得
+func (m *mmc3) NametablePage(table int) int {
+	return m.board.nametablePage(m, table)
+}
+
+// MARK: IRQの取得
+func (m *mmc3) IRQ() bool {
+	return m.irqPending
+}
+
+// MARK: CPUサイクルの通知
+func (m *mmc3) Tick() {
+	if !m.a12 {
+		m.a12LowTime++
+	}
+}
+
+// MARK: PPUのアドレスの通知
+func (m *mmc3) PPUAddress(address uint16) {
+	a12 := address&PPU_A12 != 0
+
+	// A12の立ち上がりのうち、直前に十分な時間Lowだったもののみを数える
+	// (8x16スプライトのフェッチ等による短いパルスを除外する)
+	if a12 && !m.a12 && m.a12LowTime >= MMC3_A12_FILTER_CYCLES {
+		m.clockIRQCounter()
+	}
+	if !a12 && m.a12 {
+		m.a12LowTime = 0
+	}
+	m.a12 = a12
+}
+
+// MARK: IRQカウンタのクロック
+func (m *mmc3) clockIRQCounter() {
+	previous := m.irqCounter
+	reload := m.irqReload
+
+	if m.irqCounter == 0 || m.irqReload {
+		m.irqCounter = m.irqLatch
+	} else {
+		m.irqCounter--
+	}
+	m.irqReload = false
+
+	// 新しいMMC3 (Sharp製) はクロック後にカウンタが0であれば常にIRQを発生させる
+	// MMC3A (NEC製) はカウンタが0へ減算された場合か、$C001によるリロードの場合のみ発生させる
+	trigger := m.irqCounter == 0
+	if m.oldIRQ {
+		trigger = trigger && (previous != 0 || reload)
+	}
+	if trigger && m.irqEnabled {
+		m.irqPending = true
+	}
+}
+
+// MARK: 標準的なMMC3の基板 (TxROM) の定義
+type txrom struct{}
+
+func (txrom) applyCHRBanks(m *mmc3, banks [CHR_BANK_SLOTS]int) {
+	for slot, bank := range banks {
+		m.setCHRBank1k(slot, bank)
+	}
+}
+
+func (txrom) writeMirroring(m *mmc3, value uint8) {
+	// 4画面の基板 (TR1ROM / TVROM) ではミラーリングを変更できない
+	if m.cartridge.Header.Mirroring == MirroringFourScreen {
+		return
+	}
+	if value&0x01 == 0 {
+		m.mirroring = MirroringVertical
+	} else {
+		m.mirroring = MirroringHorizontal
+	}
+}
+
+func (txrom) readPRGRAM(m *mmc3, address uint16) (uint8, bool) {
+	return m.readPRGRAM(address)
+}
+
+func (txrom) writePRGRAM(m *mmc3, address uint16, value uint8) {
+	m.writePRGRAM(address, value)
+}
+
+func (txrom) writePRGRAMProtect(m *mmc3, value uint8) {
+	// bit7: PRG-RAMの有効化, bit6: 書き込み禁止
+	m.prgRAMEnabled = value&0x80 != 0
+	m.prgRAMWritable = value&0x40 == 0
+}
+
+func (txrom) nametablePage(m *mmc3, table int) int {
+	return m.mirroring.Page(table)
+}
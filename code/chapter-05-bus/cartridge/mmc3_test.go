@@ -0,0 +1,153 @@
+package cartridge
+
+import "testing"
+
+// MARK: A12の立ち上がり (十分な時間Lowだった後) を1回発生させる
+func clockA12(c *Cartridge) {
+	c.PPUAddress(0x0000)
+	c.Tick(MMC3_A12_FILTER_CYCLES)
+	c.PPUAddress(PPU_A12)
+}
+
+// MARK: IRQを設定したMMC3のテスト用のカートリッジ
+func testMMC3IRQ(t *testing.T, submapper uint8, latch uint8) *Cartridge {
+	t.Helper()
+	c := newTestCartridge(t, testBoard{mapper: 4, submapper: submapper, prgROM: 128 * 1024, chrROM: 128 * 1024})
+	c.WriteByteAt(0xC000, latch) // ラッチ
+	c.WriteByteAt(0xC001, 0x00)  // リロード
+	c.WriteByteAt(0xE001, 0x00)  // 有効化
+	return c
+}
+
+// MARK: A12で数えるIRQカウンタのテスト
+func TestMMC3IRQCounter(t *testing.T) {
+	c := testMMC3IRQ(t, 0, 3)
+
+	// 1回目の立ち上がりでラッチの値を読み込み、以降は減算して0になるとIRQを発生させる
+	for i := 1; i <= 3; i++ {
+		clockA12(c)
+		if c.IRQ() {
+			t.Fatalf("IRQ after %d edges, want after 4", i)
+		}
+	}
+	clockA12(c)
+	if !c.IRQ() {
+		t.Fatal("no IRQ when the counter reached 0")
+	}
+
+	// $E000で解除し、$E001で再び有効化してもIRQは保留されない
+	c.WriteByteAt(0xE000, 0x00)
+	if c.IRQ() {
+		t.Fatal("$E000 did not acknowledge the IRQ")
+	}
+	c.WriteByteAt(0xE001, 0x00)
+	if c.IRQ() {
+		t.Fatal("$E001 re-asserted an acknowledged IRQ")
+	}
+
+	// カウンタが0の場合は次の立ち上がりでラッチの値を読み込み直す
+	for i := 1; i <= 3; i++ {
+		clockA12(c)
+		if c.IRQ() {
+			t.Fatalf("IRQ %d edges after reload, want after 4", i)
+		}
+	}
+	clockA12(c)
+	if !c.IRQ() {
+		t.Fatal("no IRQ after the counter was reloaded")
+	}
+}
+
+// MARK: IRQが無効な場合のテスト
+func TestMMC3IRQDisabled(t *testing.T) {
+	c := testMMC3IRQ(t, 0, 1)
+	c.WriteByteAt(0xE000, 0x00)
+
+	// 無効な間もカウンタは動作し、有効化した後の0でIRQが発生する
+	clockA12(c)
+	clockA12(c)
+	if c.IRQ() {
+		t.Fatal("IRQ while disabled")
+	}
+	c.WriteByteAt(0xE001, 0x00)
+	clockA12(c) // 0 → ラッチ (1)
+	clockA12(c) // 1 → 0
+	if !c.IRQ() {
+		t.Fatal("no IRQ after re-enabling")
+	}
+}
+
+// MARK: $C001によるリロードのテスト
+func TestMMC3IRQReload(t *testing.T) {
+	c := testMMC3IRQ(t, 0, 5)
+	clockA12(c) // 5
+	clockA12(c) // 4
+
+	// $C001はカウンタを0にし、次の立ち上がりで新しいラッチの値を読み込む
+	c.WriteByteAt(0xC000, 2)
+	c.WriteByteAt(0xC001, 0x00)
+	clockA12(c) // 2
+	clockA12(c) // 1
+	if c.IRQ() {
+		t.Fatal("IRQ before the reloaded counter reached 0")
+	}
+	clockA12(c) // 0
+	if !c.IRQ() {
+		t.Fatal("no IRQ after the reloaded counter reached 0")
+	}
+}
+
+// MARK: ラッチが0の場合の新旧のIRQの挙動のテスト
+func TestMMC3IRQLatchZero(t *testing.T) {
+	tests := []struct {
+		name      string
+		submapper uint8
+		want      []bool // 各立ち上がりの後のIRQ
+	}{
+		// Sharp製: クロック後に0であれば毎回発生する
+		{"new", 0, []bool{true, true, true}},
+		// NEC製 (MMC3A): $C001によるリロードの場合のみ発生し、0からの再読み込みでは発生しない
+		{"old", MMC3_SUBMAPPER_MMC3A, []bool{true, false, false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testMMC3IRQ(t, tt.submapper, 0)
+			for i, want := range tt.want {
+				clockA12(c)
+				if got := c.IRQ(); got != want {
+					t.Fatalf("edge %d: IRQ = %v, want %v", i+1, got, want)
+				}
+				c.WriteByteAt(0xE000, 0x00)
+				c.WriteByteAt(0xE001, 0x00)
+			}
+		})
+	}
+}
+
+// MARK: A12のLowの時間によるフィルタのテスト
+func TestMMC3A12Filter(t *testing.T) {
+	c := testMMC3IRQ(t, 0, 0)
+
+	// 直前にLowだった時間が短い立ち上がり (8x16スプライトのフェッチ等) は数えない
+	c.PPUAddress(PPU_A12)
+	c.PPUAddress(0x0000)
+	c.Tick(MMC3_A12_FILTER_CYCLES - 1)
+	c.PPUAddress(PPU_A12)
+	if c.IRQ() {
+		t.Fatal("short A12 pulse clocked the counter")
+	}
+
+	// Highのまま続くアドレスは新たな立ち上がりにならない
+	c.Tick(MMC3_A12_FILTER_CYCLES)
+	c.PPUAddress(PPU_A12 | 0x0010)
+	if c.IRQ() {
+		t.Fatal("A12 staying high clocked the counter")
+	}
+
+	c.PPUAddress(0x0000)
+	c.Tick(MMC3_A12_FILTER_CYCLES)
+	c.PPUAddress(PPU_A12)
+	if !c.IRQ() {
+		t.Fatal("A12 edge after a long low time did not clock the counter")
+	}
+}
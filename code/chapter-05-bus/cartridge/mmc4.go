@@ -0,0 +1,18 @@
+package cartridge
+
+// MARK: MMC4のコンストラクタ
+func newMMC4(c *Cartridge) *mmc2 {
+	/*
+		$8000-$BFFF: 16kB 切り替え可能なバンク
+		$C000-$FFFF: 16kB 最後のバンクに固定
+		$6000-$7FFF: 8kB PRG-RAM (バッテリーバックアップ)
+		CHRのラッチはMMC2と同じ
+	*/
+	m := &mmc2{
+		baseMapper: newBaseMapper(c),
+		latches:    [2]uint8{MMC2_LATCH_FE, MMC2_LATCH_FE},
+		mmc4:       true,
+	}
+	m.updateBanks()
+	return m
+}
@@ -0,0 +1,591 @@
+package cartridge
+
+const (
+	MMC5_EXRAM_SIZE  = 1 * 1024
+	MMC5_EXRAM_START = 0x5C00
+	MMC5_EXRAM_END   = 0x5FFF
+
+	MMC5_ATTRIBUTE_OFFSET = 0x03C0 // ネームテーブル内のアトリビュートテーブルの位置
+
+	MMC5_CHR_BANK_4K = 4 * CHR_BANK_SIZE
+
+	MMC5_SCREEN_HEIGHT = 240
+
+	// 1スキャンライン中のタイルのフェッチ (ネームテーブル + アトリビュートの2回の読み取り) の順序
+	// スキャンラインの検出時点で画面上の3タイル目 (2列目) をフェッチしている
+	MMC5_FETCH_PREFETCHED_TILES = 2  // 前のスキャンラインで先読みされるタイル数
+	MMC5_FETCH_SPRITE_START     = 32 // ドット257-320 スプライトのフェッチ (ゴミの読み取り)
+	MMC5_FETCH_SPRITE_END       = 40
+	MMC5_FETCH_PREFETCH_END     = 42 // ドット321-336 次のスキャンラインの先頭2タイル
+
+	MMC5_IDLE_CYCLES = 3 // PPUの読み取りがこのCPUサイクル数途絶えたら描画期間外とみなす
+
+	CPU_NMI_VECTOR = 0xFFFA
+)
+
+// MARK: ExRAMのモード ($5104)
+const (
+	mmc5ExRAMNametable         = iota // 追加のネームテーブル
+	mmc5ExRAMExtendedAttribute        // 拡張アトリビュート (タイルごとのパレット / CHRバンク)
+	mmc5ExRAMReadWrite                // CPUから読み書きできるRAM
+	mmc5ExRAMReadOnly                 // CPUから読み取り専用のRAM
+)
+
+// MARK: ネームテーブルの割り当て ($5105)
+const (
+	mmc5NametableCIRAM0 = iota
+	mmc5NametableCIRAM1
+	mmc5NametableExRAM
+	mmc5NametableFill
+)
+
+// MARK: MMC5 (マッパー5, ExROM) の定義
+type mmc5 struct {
+	baseMapper
+
+	prgMode       uint8    // $5100
+	chrMode       uint8    // $5101
+	prgRAMProtect [2]uint8 // $5102 / $5103 ($02 / $01 の場合のみ書き込み可能)
+	exRAMMode     uint8    // $5104
+	nametables    uint8    // $5105
+	fillTile      uint8    // $5106
+	fillAttribute uint8    // $5107
+
+	prgRegisters [5]uint8               // $5113-$5117
+	prgRAMSlots  [PRG_BANK_SLOTS]bool   // PRG-RAMを割り当てた$8000-$FFFFのスロット
+	prgRAMBanks  [PRG_BANK_SLOTS]int    // 各スロットに割り当てたPRG-RAMのオフセット
+	chrRegisters [12]int                // $5120-$512B ($5130の上位bitを含む)
+	chrUpper     uint8                  // $5130
+	lastCHRSetB  bool                   // 最後に書き込まれたのが$5128-$512Bか
+	chrA         [CHR_BANK_SLOTS]int    // スプライト用 ($5120-$5127)
+	chrB         [CHR_BANK_SLOTS]int    // 背景用 ($5128-$512B)
+	exRAM        [MMC5_EXRAM_SIZE]uint8 // $5C00-$5FFF
+
+	// 縦分割
+	splitControl uint8 // $5200
+	splitScroll  uint8 // $5201
+	splitBank    uint8 // $5202
+
+	// スキャンラインIRQ
+	irqCompare uint8 // $5203
+	irqEnabled bool  // $5204
+	irqPending bool
+
+	// 乗算器
+	multiplicand uint8 // $5205
+	multiplier   uint8 // $5206
+
+	// PPUの監視
+	sprite8x16     bool   // $2000 bit5
+	rendering      bool   // $2001 bit3/4
+	inFrame        bool   // 描画期間中か
+	scanline       uint8  // 描画期間の開始からのスキャンライン数
+	lastPPUAddress uint16 // 直前にPPUが読み取ったアドレス
+	sameReads      int    // 同じネームテーブルのアドレスを連続して読み取った回数
+	idleCycles     int    // PPUの読み取りが途絶えてからのCPUサイクル数
+	fetches        int    // スキャンラインの検出からのネームテーブルの読み取り回数
+	tile           int    // フェッチ中のタイルの順番 (fetches / 2)
+	exAttribute    uint8  // 拡張アトリビュートモードでフェッチ中のタイルのExRAMの値
+	splitActive    bool   // フェッチ中のタイルが分割領域内か
+	splitY         int    // 分割領域内のタイルの縦方向の位置
+
+	audio mmc5Audio
+}
+
+// MARK: MMC5のコンストラクタ
+func newMMC5(c *Cartridge) *mmc5 {
+	m := &mmc5{
+		baseMapper: newBaseMapper(c),
+		prgMode:    3,
+		chrMode:    3,
+		audio:      newMMC5Audio(c),
+	}
+	// 電源投入時は$5117が$FF (最後のバンク) になっている
+	m.prgRegisters[4] = 0xFF
+	m.prgRAMWritable = false
+	m.updatePRGBanks()
+	m.updateCHRBanks()
+	return m
+}
+
+// MARK: CPUからの読み取り
+func (m *mmc5) ReadPRG(address uint16) (uint8, bool) {
+	value, driven := m.PeekPRG(address)
+
+	switch {
+	case address == 0x5010:
+		m.audio.pcmIRQPending = false
+	case address == 0x5204:
+		m.irqPending = false
+	case address == CPU_NMI_VECTOR || address == CPU_NMI_VECTOR+1:
+		// NMIのベクタの読み取りで描画期間の終了 (VBlank) を検出する
+		m.inFrame = false
+		m.lastPPUAddress = 0
+	case CPU_PRG_ROM_START <= address && address < 0xC000 && driven:
+		m.audio.readPCM(value)
+	}
+	return value, driven
+}
+
+// MARK: CPUからの読み取り (副作用なし)
+func (m *mmc5) PeekPRG(address uint16) (uint8, bool) {
+	switch {
+	case address == 0x5010 || address == 0x5015:
+		return m.audio.read(address), true
+	case address == 0x5204:
+		var status uint8
+		if m.irqPending {
+			status |= 0x80
+		}
+		if m.inFrame {
+			status |= 0x40
+		}
+		return status, true
+	case address == 0x5205:
+		return uint8(uint16(m.multiplicand) * uint16(m.multiplier)), true
+	case address == 0x5206:
+		return uint8(uint16(m.multiplicand) * uint16(m.multiplier) >> 8), true
+	case MMC5_EXRAM_START <= address && address <= MMC5_EXRAM_END:
+		// モード0/1ではCPUから読み取れない
+		if m.exRAMMode < mmc5ExRAMReadWrite {
+			return 0x00, false
+		}
+		return m.exRAM[address-MMC5_EXRAM_START], true
+	case CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END:
+		return m.readPRGRAM(address)
+	case CPU_PRG_ROM_START <= address:
+		index, ram := m.prgSlotIndex(address)
+		if !ram {
+			return m.cartridge.PRGROM[index], true
+		}
+		if index < 0 {
+			return 0x00, false
+		}
+		return m.cartridge.PRGRAM[index], true
+	default:
+		return 0x00, false
+	}
+}
+
+// MARK: CPUからの書き込み (副作用なし)
+func (m *mmc5) PokePRG(address uint16, value uint8) {
+	switch {
+	case MMC5_EXRAM_START <= address && address <= MMC5_EXRAM_END:
+		m.exRAM[address-MMC5_EXRAM_START] = value
+	case CPU_PRG_ROM_START <= address:
+		// PeekPRGと同じく、PRG-RAMが割り当てられたスロットではRAMを書き換える
+		index, ram := m.prgSlotIndex(address)
+		switch {
+		case !ram:
+			m.cartridge.PRGROM[index] = value
+		case index >= 0:
+			m.cartridge.writePRGRAM(index, value)
+		}
+	default:
+		m.baseMapper.PokePRG(address, value)
+	}
+}
+
+// MARK: $8000-$FFFFのアドレスに割り当てられたメモリの位置を返すメソッド
+func (m *mmc5) prgSlotIndex(address uint16) (index int, ram bool) {
+	// PRG-RAMが割り当てられたスロットでは ram が true になる (PRG-RAMがない場合は index が負)
+	slot := int(address-CPU_PRG_ROM_START) / PRG_BANK_SIZE
+	if !m.prgRAMSlots[slot] {
+		return m.prgROMIndex(address), false
+	}
+	if len(m.cartridge.PRGRAM) == 0 {
+		return -1, true
+	}
+	return m.prgRAMBanks[slot] + int(address)%PRG_BANK_SIZE, true
+}
+
+// MARK: CPUからの書き込み
+func (m *mmc5) WritePRG(address uint16, value uint8) {
+	switch {
+	case 0x5000 <= address && address <= 0x5015:
+		m.audio.write(address, value)
+	case address == 0x5100:
+		m.prgMode = value & 0x03
+		m.updatePRGBanks()
+	case address == 0x5101:
+		m.chrMode = value & 0x03
+		m.updateCHRBanks()
+	case address == 0x5102 || address == 0x5103:
+		m.prgRAMProtect[address-0x5102] = value & 0x03
+		m.prgRAMWritable = m.prgRAMProtect[0] == 0x02 && m.prgRAMProtect[1] == 0x01
+	case address == 0x5104:
+		m.exRAMMode = value & 0x03
+	case address == 0x5105:
+		m.nametables = value
+	case address == 0x5106:
+		m.fillTile = value
+	case address == 0x5107:
+		m.fillAttribute = value & 0x03
+	case address == 0x5113:
+		m.prgRAMBank = int(value & 0x0F)
+	case 0x5114 <= address && address <= 0x5117:
+		m.prgRegisters[address-0x5113] = value
+		m.updatePRGBanks()
+	case 0x5120 <= address && address <= 0x512B:
+		// $5130の上位bitは書き込んだ時点のものが使われる
+		index := int(address - 0x5120)
+		m.chrRegisters[index] = int(value) | int(m.chrUpper&0x03)<<8
+		m.lastCHRSetB = index >= 8
+		m.updateCHRBanks()
+	case address == 0x5130:
+		m.chrUpper = value & 0x03
+	case address == 0x5200:
+		m.splitControl = value
+	case address == 0x5201:
+		m.splitScroll = value
+	case address == 0x5202:
+		m.splitBank = value
+	case address == 0x5203:
+		m.irqCompare = value
+	case address == 0x5204:
+		m.irqEnabled = value&0x80 != 0
+	case address == 0x5205:
+		m.multiplicand = value
+	case address == 0x5206:
+		m.multiplier = value
+	case MMC5_EXRAM_START <= address && address <= MMC5_EXRAM_END:
+		m.writeExRAM(address-MMC5_EXRAM_START, value)
+	case CPU_PRG_RAM_START <= address && address <= CPU_PRG_RAM_END:
+		m.writePRGRAM(address, value)
+	case CPU_PRG_ROM_START <= address:
+		slot := int(address-CPU_PRG_ROM_START) / PRG_BANK_SIZE
+		if m.prgRAMSlots[slot] && m.prgRAMWritable && len(m.cartridge.PRGRAM) > 0 {
+			m.cartridge.writePRGRAM(m.prgRAMBanks[slot]+int(address)%PRG_BANK_SIZE, value)
+		}
+	}
+}
+
+// MARK: ExRAMへのCPUからの書き込み
+func (m *mmc5) writeExRAM(offset uint16, value uint8) {
+	switch m.exRAMMode {
+	case mmc5ExRAMNametable, mmc5ExRAMExtendedAttribute:
+		// 描画期間外の書き込みは$00になる
+		if !m.inFrame {
+			value = 0x00
+		}
+		m.exRAM[offset] = value
+	case mmc5ExRAMReadWrite:
+		m.exRAM[offset] = value
+	}
+}
+
+// MARK: PRGのバンクの更新
+func (m *mmc5) updatePRGBanks() {
+	/*
+		モード0: $8000-$FFFF 32kB ($5117)
+		モード1: $8000-$BFFF 16kB ($5115), $C000-$FFFF 16kB ($5117)
+		モード2: $8000-$BFFF 16kB ($5115), $C000 8kB ($5116), $E000 8kB ($5117)
+		モード3: $8000, $A000, $C000, $E000 各8kB ($5114-$5117)
+		bit7が0の場合はPRG-RAMを割り当てる ($5117は常にPRG-ROM)
+	*/
+	r := m.prgRegisters
+	last := r[4] | 0x80
+	switch m.prgMode {
+	case 0:
+		for slot := range PRG_BANK_SLOTS {
+			m.setPRGSlot(slot, last&^0x03+uint8(slot))
+		}
+	case 1:
+		m.setPRGSlot16k(0, r[2])
+		m.setPRGSlot16k(1, last)
+	case 2:
+		m.setPRGSlot16k(0, r[2])
+		m.setPRGSlot(2, r[3])
+		m.setPRGSlot(3, last)
+	case 3:
+		m.setPRGSlot(0, r[1])
+		m.setPRGSlot(1, r[2])
+		m.setPRGSlot(2, r[3])
+		m.setPRGSlot(3, last)
+	}
+}
+
+func (m *mmc5) setPRGSlot16k(slot int, value uint8) {
+	m.setPRGSlot(slot*2, value&^0x01)
+	m.setPRGSlot(slot*2+1, value|0x01)
+}
+
+func (m *mmc5) setPRGSlot(slot int, value uint8) {
+	m.prgRAMSlots[slot] = value&0x80 == 0
+	if m.prgRAMSlots[slot] {
+		m.prgRAMBanks[slot] = bankOffset(int(value&0x0F), PRG_BANK_SIZE, len(m.cartridge.PRGRAM))
+	} else {
+		m.setPRGBank8k(slot, int(value&0x7F))
+	}
+}
+
+// MARK: CHRのバンクの更新
+func (m *mmc5) updateCHRBanks() {
+	/*
+		       セットA (スプライト)       セットB (背景, $1000-$1FFFは$0000-$0FFFと同じ)
+		モード0: 8kB $5127                8kB $512B
+		モード1: 4kB $5123, $5127         4kB $512B
+		モード2: 2kB $5121,$5123,$5125,$5127  2kB $5129, $512B
+		モード3: 1kB $5120-$5127          1kB $5128-$512B
+	*/
+	r := m.chrRegisters
+	switch m.chrMode {
+	case 0:
+		m.setCHRBank8k(r[7])
+		m.chrA = m.chrBanks
+		m.setCHRBank8k(r[11])
+		m.chrB = m.chrBanks
+	case 1:
+		m.setCHRBank4k(0, r[3])
+		m.setCHRBank4k(1, r[7])
+		m.chrA = m.chrBanks
+		m.setCHRBank4k(0, r[11])
+		m.setCHRBank4k(1, r[11])
+		m.chrB = m.chrBanks
+	case 2:
+		for slot := range 4 {
+			m.setCHRBank2k(slot, r[slot*2+1])
+		}
+		m.chrA = m.chrBanks
+		for slot := range 4 {
+			m.setCHRBank2k(slot, r[8+slot%2*2+1])
+		}
+		m.chrB = m.chrBanks
+	case 3:
+		for slot := range CHR_BANK_SLOTS {
+			m.setCHRBank1k(slot, r[slot])
+		}
+		m.chrA = m.chrBanks
+		for slot := range CHR_BANK_SLOTS {
+			m.setCHRBank1k(slot, r[8+slot%4])
+		}
+		m.chrB = m.chrBanks
+	}
+
+	// 描画期間外のアクセス (PPUDATA) は最後に書き込まれたセットを使う
+	if !m.lastCHRSetB {
+		m.chrBanks = m.chrA
+	}
+}
+
+// MARK: PPUからの読み取り ($0000-$1FFF)
+func (m *mmc5) ReadCHR(address uint16) uint8 {
+	m.observePPURead(address)
+	m.sameReads = 0
+
+	if !m.inFrame || len(m.chr) == 0 {
+		return m.PeekCHR(address)
+	}
+
+	sprite := MMC5_FETCH_SPRITE_START <= m.tile && m.tile < MMC5_FETCH_SPRITE_END
+	if !sprite {
+		switch {
+		case m.splitActive:
+			// 分割領域は$5202の4kBバンクを使い、縦方向の位置は分割のスクロール値で置き換える
+			offset := bankOffset(int(m.splitBank), MMC5_CHR_BANK_4K, len(m.chr))
+			return m.chr[offset+int(address&0x0FF8)|m.splitY&0x07]
+		case m.exRAMMode == mmc5ExRAMExtendedAttribute:
+			// ExRAMの下位6bitと$5130で4kBバンクを選択する
+			bank := int(m.exAttribute&0x3F) | int(m.chrUpper)<<6
+			return m.chr[bankOffset(bank, MMC5_CHR_BANK_4K, len(m.chr))+int(address&0x0FFF)]
+		}
+	}
+
+	// 8x16スプライトの場合のみ背景とスプライトで別々のセットを使う
+	banks := m.chrBanks
+	if m.sprite8x16 {
+		if sprite {
+			banks = m.chrA
+		} else {
+			banks = m.chrB
+		}
+	}
+	address &= PPU_PATTERN_TABLE_END
+	return m.chr[banks[address/CHR_BANK_SIZE]+int(address%CHR_BANK_SIZE)]
+}
+
+// MARK: PPUの読み取りの監視
+func (m *mmc5) observePPURead(address uint16) {
+	m.idleCycles = 0
+	m.lastPPUAddress = address
+}
+
+// MARK: PPUからのネームテーブルの読み取り ($2000-$2FFF)
+func (m *mmc5) ReadNametable(address uint16) (uint8, bool) {
+	// 同じネームテーブルのアドレスを3回連続して読み取った場合 (ドット337, 339, 1) にスキャンラインを検出する
+	if address == m.lastPPUAddress {
+		m.sameReads++
+	} else {
+		m.sameReads = 0
+	}
+	m.observePPURead(address)
+	if m.sameReads == 2 {
+		m.startScanline()
+	}
+
+	value, driven := m.fetchNametable(address)
+	m.fetches++
+	return value, driven
+}
+
+// MARK: スキャンラインの開始
+func (m *mmc5) startScanline() {
+	if !m.inFrame {
+		m.inFrame = true
+		m.scanline = 0
+	} else {
+		m.scanline++
+		if m.scanline == m.irqCompare {
+			m.irqPending = true
+		}
+	}
+	m.fetches = 0
+}
+
+// MARK: 描画中のネームテーブルのフェッチ
+func (m *mmc5) fetchNametable(address uint16) (uint8, bool) {
+	offset := address & (MMC5_EXRAM_SIZE - 1)
+	attribute := m.fetches%2 == 1
+
+	if m.inFrame && !attribute {
+		m.tile = m.fetches / 2
+		m.splitActive = m.inSplit()
+		if m.exRAMMode == mmc5ExRAMExtendedAttribute {
+			m.exAttribute = m.exRAM[offset]
+		}
+	}
+
+	if m.inFrame && m.splitActive {
+		column := m.column()
+		row := m.splitY / 8
+		if attribute {
+			value := m.exRAM[MMC5_ATTRIBUTE_OFFSET+row/4*8+column/4]
+			shift := (row/2%2)*4 + (column/2%2)*2
+			return (value >> shift & 0x03) * 0x55, true
+		}
+		return m.exRAM[row*32+column], true
+	}
+
+	if m.inFrame && attribute && m.exRAMMode == mmc5ExRAMExtendedAttribute {
+		// パレットはExRAMの上位2bitで指定される
+		return (m.exAttribute >> 6) * 0x55, true
+	}
+	return m.PeekNametable(address)
+}
+
+// MARK: フェッチ中のタイルの画面上の列
+func (m *mmc5) column() int {
+	if m.tile >= MMC5_FETCH_SPRITE_END {
+		return m.tile - MMC5_FETCH_SPRITE_END
+	}
+	return m.tile + MMC5_FETCH_PREFETCHED_TILES
+}
+
+// MARK: フェッチ中のタイルが縦分割の領域内かを返すメソッド
+func (m *mmc5) inSplit() bool {
+	// 縦分割はExRAMがモード0/1の場合のみ有効
+	if m.splitControl&0x80 == 0 || m.exRAMMode > mmc5ExRAMExtendedAttribute {
+		return false
+	}
+	if MMC5_FETCH_SPRITE_START <= m.tile && m.tile < MMC5_FETCH_SPRITE_END || m.tile >= MMC5_FETCH_PREFETCH_END {
+		return false
+	}
+
+	// 先読みされる2タイルは次のスキャンラインのもの
+	line := int(m.scanline)
+	if m.tile >= MMC5_FETCH_SPRITE_END {
+		line++
+	}
+	m.splitY = (line + int(m.splitScroll)) % MMC5_SCREEN_HEIGHT
+
+	column := m.column()
+	threshold := int(m.splitControl & 0x1F)
+	if m.splitControl&0x40 != 0 {
+		return column >= threshold // 右側
+	}
+	return column < threshold // 左側
+}
+
+// MARK: PPUからのネームテーブルの読み取り (副作用なし)
+func (m *mmc5) PeekNametable(address uint16) (uint8, bool) {
+	offset := address & (MMC5_EXRAM_SIZE - 1)
+	switch m.nametableSource(address) {
+	case mmc5NametableExRAM:
+		if m.exRAMMode > mmc5ExRAMExtendedAttribute {
+			return 0x00, true
+		}
+		return m.exRAM[offset], true
+	case mmc5NametableFill:
+		if offset >= MMC5_ATTRIBUTE_OFFSET {
+			return m.fillAttribute * 0x55, true
+		}
+		return m.fillTile, true
+	default:
+		return 0x00, false
+	}
+}
+
+// MARK: PPUからのネームテーブルへの書き込み ($2000-$2FFF)
+func (m *mmc5) WriteNametable(address uint16, value uint8) bool {
+	switch m.nametableSource(address) {
+	case mmc5NametableExRAM:
+		if m.exRAMMode <= mmc5ExRAMExtendedAttribute {
+			m.exRAM[address&(MMC5_EXRAM_SIZE-1)] = value
+		}
+		return true
+	case mmc5NametableFill:
+		return true
+	default:
+		return false
+	}
+}
+
+// MARK: ネームテーブルの割り当て ($5105 の2bitずつ)
+func (m *mmc5) nametableSource(address uint16) uint8 {
+	table := address >> 10 & 0x03
+	return m.nametables >> (table * 2) & 0x03
+}
+
+// MARK: ネームテーブルに割り当てるCIRAMのページの取得
+func (m *mmc5) NametablePage(table int) int {
+	if source := m.nametables >> (table * 2) & 0x03; source == mmc5NametableCIRAM1 {
+		return 1
+	}
+	return 0
+}
+
+// MARK: PPUレジスタへの書き込みの監視
+func (m *mmc5) ObservePPURegister(address uint16, value uint8) {
+	switch address {
+	case 0x2000:
+		m.sprite8x16 = value&0x20 != 0
+	case 0x2001:
+		m.rendering = value&0x18 != 0
+		if !m.rendering {
+			m.inFrame = false
+		}
+	}
+}
+
+// MARK: IRQの取得
+func (m *mmc5) IRQ() bool {
+	return m.irqPending && m.irqEnabled || m.audio.irq()
+}
+
+// MARK: CPUサイクルの通知
+func (m *mmc5) Tick() {
+	// PPUの読み取りが途絶えた場合はVBlankまたは描画の停止とみなす
+	if m.idleCycles < MMC5_IDLE_CYCLES {
+		m.idleCycles++
+		if m.idleCycles == MMC5_IDLE_CYCLES {
+			m.inFrame = false
+			m.lastPPUAddress = 0
+		}
+	}
+	m.audio.tick()
+}
+
+// MARK: 拡張音源の出力
+func (m *mmc5) AudioOutput() float32 {
+	return m.audio.output()
+}
@@ -0,0 +1,132 @@
+package cartridge
+
+import "testing"
+
+// MARK: スキャンラインの開始を1回発生させる (同じネームテーブルのアドレスを3回読み取る)
+func startMMC5Scanline(c *Cartridge) {
+	for range 3 {
+		c.ReadNametable(0x2000)
+	}
+	c.ReadNametable(0x2001) // 次のスキャンラインの検出のため連続を途切れさせる
+}
+
+// MARK: $5205/$5206の乗算器のテスト
+func TestMMC5Multiplier(t *testing.T) {
+	c := testCartridge(t, 5, 128*1024, 8*1024)
+	tests := []struct {
+		multiplicand, multiplier uint8
+		product                  uint16
+	}{
+		{200, 3, 600},
+		{0xFF, 0xFF, 0xFE01},
+		{0x12, 0x00, 0},
+	}
+	for _, tt := range tests {
+		c.WriteByteAt(0x5205, tt.multiplicand)
+		c.WriteByteAt(0x5206, tt.multiplier)
+		low, _ := c.ReadByteFrom(0x5205)
+		high, _ := c.ReadByteFrom(0x5206)
+		if got := uint16(high)<<8 | uint16(low); got != tt.product {
+			t.Errorf("%d * %d = %d, want %d", tt.multiplicand, tt.multiplier, got, tt.product)
+		}
+	}
+}
+
+// MARK: $5203/$5204のスキャンラインIRQのテスト
+func TestMMC5ScanlineIRQ(t *testing.T) {
+	c := testCartridge(t, 5, 128*1024, 8*1024)
+	c.WriteByteAt(0x5203, 2)
+	c.WriteByteAt(0x5204, 0x80)
+
+	// 最初の検出で描画期間に入り (スキャンライン0)、以降の検出で比較値に達するとIRQを発生させる
+	startMMC5Scanline(c)
+	if status, _ := c.PeekByteFrom(0x5204); status != 0x40 {
+		t.Fatalf("$5204 in frame = $%02X, want $40", status)
+	}
+	startMMC5Scanline(c)
+	if c.IRQ() {
+		t.Fatal("IRQ before the compare scanline")
+	}
+	startMMC5Scanline(c)
+	if !c.IRQ() {
+		t.Fatal("no IRQ on the compare scanline")
+	}
+
+	// $5204の読み取りで保留中のIRQが解除される
+	if status, _ := c.ReadByteFrom(0x5204); status != 0xC0 {
+		t.Fatalf("$5204 = $%02X, want $C0", status)
+	}
+	if c.IRQ() {
+		t.Fatal("reading $5204 did not acknowledge the IRQ")
+	}
+
+	// 無効な間も保留され、有効化するとIRQになる
+	c.WriteByteAt(0x5204, 0x00)
+	c.WriteByteAt(0x5203, 3)
+	startMMC5Scanline(c)
+	if c.IRQ() {
+		t.Fatal("IRQ while disabled")
+	}
+	c.WriteByteAt(0x5204, 0x80)
+	if !c.IRQ() {
+		t.Fatal("pending IRQ was not raised after enabling")
+	}
+
+	// PPUの読み取りが途絶えると描画期間外になる
+	c.Tick(MMC5_IDLE_CYCLES)
+	if status, _ := c.PeekByteFrom(0x5204); status&0x40 != 0 {
+		t.Fatalf("$5204 after idle = $%02X, want bit6 clear", status)
+	}
+}
+
+// MARK: ExRAMの各モードの読み書きのテスト
+func TestMMC5ExRAMModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      uint8
+		inFrame   bool
+		readable  bool  // CPUから読み取れるか
+		stored    uint8 // $5C00に$42を書き込んだ後の内容
+		nametable bool  // PPUからネームテーブルとして読み取れるか
+	}{
+		{"nametable outside frame", mmc5ExRAMNametable, false, false, 0x00, true},
+		{"nametable in frame", mmc5ExRAMNametable, true, false, 0x42, true},
+		{"extended attribute in frame", mmc5ExRAMExtendedAttribute, true, false, 0x42, true},
+		{"read/write", mmc5ExRAMReadWrite, false, true, 0x42, false},
+		{"read-only", mmc5ExRAMReadOnly, false, true, 0x11, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCartridge(t, 5, 128*1024, 8*1024)
+			m := c.mapper.(*mmc5)
+			m.exRAM[0] = 0x11
+			c.WriteByteAt(0x5104, tt.mode)
+			c.WriteByteAt(0x5105, mmc5NametableExRAM) // $2000をExRAMに割り当てる
+			if tt.inFrame {
+				startMMC5Scanline(c)
+			}
+
+			c.WriteByteAt(MMC5_EXRAM_START, 0x42)
+			if m.exRAM[0] != tt.stored {
+				t.Fatalf("ExRAM = $%02X, want $%02X", m.exRAM[0], tt.stored)
+			}
+
+			value, driven := c.ReadByteFrom(MMC5_EXRAM_START)
+			if readable := driven == DRIVEN_ALL_BITS; readable != tt.readable {
+				t.Fatalf("CPU read driven = $%02X, want readable %v", driven, tt.readable)
+			}
+			if tt.readable && value != tt.stored {
+				t.Fatalf("CPU read = $%02X, want $%02X", value, tt.stored)
+			}
+
+			// モード2/3ではネームテーブルとして$00が読み取れる
+			want := uint8(0x00)
+			if tt.nametable {
+				want = tt.stored
+			}
+			if got, _ := c.PeekNametable(0x2000); got != want {
+				t.Fatalf("nametable read = $%02X, want $%02X", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package cartridge
+
+import "fc-emu/audio"
+
+const (
+	MMC5_FRAME_RATE = 240 // エンベロープと長さカウンタは固定の240Hzでクロックされる
+)
+
+// MARK: MMC5の拡張音源 (矩形波2ch + PCM) の定義
+type mmc5Audio struct {
+	pulses [2]audio.Pulse // $5000-$5003, $5004-$5007 (スイープを持たない)
+
+	pcm           uint8 // $5011 (8bit)
+	pcmReadMode   bool  // $5010 bit0: $8000-$BFFFの読み取り値を出力する
+	pcmIRQEnabled bool  // $5010 bit7
+	pcmIRQPending bool
+
+	framePeriod int // CPUサイクル数
+	frameCycles int
+	apuCycle    bool // 矩形波のタイマは2CPUサイクルごとに進む
+}
+
+// MARK: MMC5の拡張音源のコンストラクタ
+func newMMC5Audio(c *Cartridge) mmc5Audio {
+	return mmc5Audio{
+		framePeriod: c.Region().CPUClockHz() / MMC5_FRAME_RATE,
+	}
+}
+
+// MARK: レジスタの読み取り (副作用なし)
+func (a *mmc5Audio) read(address uint16) uint8 {
+	var value uint8
+	switch address {
+	case 0x5010:
+		// 読み取るとIRQが解除される (ReadPRGで処理する)
+		if a.pcmIRQPending {
+			value |= 0x80
+		}
+	case 0x5015:
+		for i := range a.pulses {
+			if a.pulses[i].Length.Active() {
+				value |= 1 << i
+			}
+		}
+	}
+	return value
+}
+
+// MARK: レジスタへの書き込み
+func (a *mmc5Audio) write(address uint16, value uint8) {
+	switch address {
+	case 0x5000, 0x5004:
+		a.pulses[(address-0x5000)/4].WriteControl(value)
+	case 0x5002, 0x5006:
+		a.pulses[(address-0x5000)/4].WriteTimerLow(value)
+	case 0x5003, 0x5007:
+		a.pulses[(address-0x5000)/4].WriteTimerHigh(value)
+	case 0x5010:
+		a.pcmReadMode = value&0x01 != 0
+		a.pcmIRQEnabled = value&0x80 != 0
+	case 0x5011:
+		// 書き込みモードでは$00の書き込みは無視される
+		if !a.pcmReadMode && value != 0x00 {
+			a.pcm = value
+		}
+	case 0x5015:
+		for i := range a.pulses {
+			a.pulses[i].Length.SetEnabled(value&(1<<i) != 0)
+		}
+	}
+}
+
+// MARK: 読み取りモードのPCM ($8000-$BFFFの読み取り値を取り込む)
+func (a *mmc5Audio) readPCM(value uint8) {
+	if !a.pcmReadMode {
+		return
+	}
+	if value == 0x00 {
+		// $00を読み取るとIRQを発生させ、出力は変化しない
+		a.pcmIRQPending = true
+		return
+	}
+	a.pcm = value
+}
+
+// MARK: IRQの取得
+func (a *mmc5Audio) irq() bool {
+	return a.pcmIRQEnabled && a.pcmIRQPending
+}
+
+// MARK: CPUサイクルの通知
+func (a *mmc5Audio) tick() {
+	a.apuCycle = !a.apuCycle
+	if a.apuCycle {
+		for i := range a.pulses {
+			a.pulses[i].ClockTimer()
+		}
+	}
+
+	a.frameCycles++
+	if a.frameCycles >= a.framePeriod {
+		a.frameCycles = 0
+		for i := range a.pulses {
+			a.pulses[i].Envelope.Clock()
+			a.pulses[i].Length.Clock()
+		}
+	}
+}
+
+// MARK: 拡張音源の出力
+func (a *mmc5Audio) output() float32 {
+	// 矩形波はAPUの矩形波と同じ音量、PCMはAPUのDMC (7bit) と同程度の音量で出力される
+	pulses := float32(a.pulses[0].Output()) + float32(a.pulses[1].Output())
+	return pulses*audio.PULSE_LINEAR_GAIN + float32(a.pcm)/2*audio.DMC_LINEAR_GAIN
+}
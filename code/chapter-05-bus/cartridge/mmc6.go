@@ -0,0 +1,80 @@
+package cartridge
+
+const (
+	MMC6_PRG_RAM_SIZE = 1 * 1024 // $7000-$73FF (以降$7FFFまでミラーリング)
+	MMC6_PRG_RAM_MASK = MMC6_PRG_RAM_SIZE - 1
+	MMC6_PRG_RAM_HIGH = 0x0200 // 上位512バイト ($7200-$73FF)
+
+	MMC6_PRG_RAM_ENABLE = 0x20 // $8000 bit5
+
+	// MMC6を使用するHKROMの基板 (StarTropics / StarTropics II) のROMのサイズ
+	MMC6_HKROM_PRG_ROM_SIZE = 256 * 1024
+	MMC6_HKROM_CHR_ROM_SIZE = 128 * 1024
+)
+
+// MARK: MMC6 (マッパー4 サブマッパー1, HKROM) の定義
+type mmc6 struct {
+	txrom // バンク切り替えとミラーリングはMMC3と同じ
+
+	protect uint8 // $A001 (bit7/6: 上位の読み取り/書き込み, bit5/4: 下位の読み取り/書き込み)
+}
+
+// MARK: MMC6のコンストラクタ
+func newMMC6(c *Cartridge) *mmc3 {
+	return newMMC3WithBoard(c, &mmc6{})
+}
+
+// MARK: MMC6の可能性があるMMC3かを判定する関数
+func mmc6Candidate(h Header) bool {
+	// iNES 1.0 のヘッダはMMC6とMMC3を区別できないため、HKROMと同じサイズのROMを候補とする
+	// (データベースで修正された場合はサブマッパーで区別される)
+	return h.Format == FormatINES && h.Mapper == 4 &&
+		h.PRGROMSize == MMC6_HKROM_PRG_ROM_SIZE && h.CHRROMSize == MMC6_HKROM_CHR_ROM_SIZE
+}
+
+// MARK: 内蔵PRG-RAMのインデックスと読み書きの可否を返すメソッド
+func (b *mmc6) prgRAM(m *mmc3, address uint16) (index int, readable bool, writable bool) {
+	high := address&MMC6_PRG_RAM_HIGH != 0
+	if high {
+		readable, writable = b.protect&0x80 != 0, b.protect&0x40 != 0
+	} else {
+		readable, writable = b.protect&0x20 != 0, b.protect&0x10 != 0
+	}
+	return int(address&MMC6_PRG_RAM_MASK) % len(m.cartridge.PRGRAM), readable, writable
+}
+
+// MARK: 内蔵PRG-RAMが有効かを返すメソッド
+func (b *mmc6) enabled(m *mmc3, address uint16) bool {
+	// $6000-$6FFFには何も接続されていない
+	return len(m.cartridge.PRGRAM) > 0 && m.bankSelect&MMC6_PRG_RAM_ENABLE != 0 && address >= 0x7000
+}
+
+func (b *mmc6) readPRGRAM(m *mmc3, address uint16) (uint8, bool) {
+	if !b.enabled(m, address) || b.protect&0xA0 == 0 {
+		// どちらの半分も読み取りが無効な場合はオープンバス
+		return 0x00, false
+	}
+	index, readable, _ := b.prgRAM(m, address)
+	if !readable {
+		// もう一方の半分のみが有効な場合は0が読み取れる
+		return 0x00, true
+	}
+	return m.cartridge.PRGRAM[index], true
+}
+
+func (b *mmc6) writePRGRAM(m *mmc3, address uint16, value uint8) {
+	if !b.enabled(m, address) {
+		return
+	}
+	if index, readable, writable := b.prgRAM(m, address); readable && writable {
+		m.cartridge.writePRGRAM(index, value)
+	}
+}
+
+func (b *mmc6) writePRGRAMProtect(m *mmc3, value uint8) {
+	// $8000のbit5でPRG-RAMが有効化されていない間は無視される
+	if m.bankSelect&MMC6_PRG_RAM_ENABLE == 0 {
+		return
+	}
+	b.protect = value & 0xF0
+}
@@ -0,0 +1,97 @@
+package cartridge
+
+import "testing"
+
+// MARK: MMC6の内蔵PRG-RAMの有効化と保護のテスト
+func TestMMC6PRGRAMProtect(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 4, submapper: MMC3_SUBMAPPER_MMC6, prgROM: 256 * 1024, chrROM: 128 * 1024, prgNVRAM: MMC6_PRG_RAM_SIZE})
+	read := func(address uint16) (uint8, uint8) {
+		return c.ReadByteFrom(address)
+	}
+
+	// $8000 bit5で有効化するまではオープンバス
+	if _, driven := read(0x7000); driven != DRIVEN_NO_BITS {
+		t.Fatal("PRG-RAM is readable before $8000 bit5 is set")
+	}
+	c.WriteByteAt(0xA001, 0xF0) // 有効化前の保護レジスタへの書き込みは無視される
+	c.WriteByteAt(0x8000, MMC6_PRG_RAM_ENABLE)
+	if _, driven := read(0x7000); driven != DRIVEN_NO_BITS {
+		t.Fatal("$A001 write before enabling PRG-RAM was not ignored")
+	}
+
+	// 下位の512バイトのみ読み書き可能: 上位は0が読み取れ、書き込みは無視される
+	c.WriteByteAt(0xA001, 0x30)
+	c.WriteByteAt(0x7000, 0x11)
+	c.WriteByteAt(0x7200, 0x22)
+	if got, _ := read(0x7000); got != 0x11 {
+		t.Fatalf("$7000 = $%02X, want $11", got)
+	}
+	if got, driven := read(0x7200); got != 0x00 || driven != DRIVEN_ALL_BITS {
+		t.Fatalf("disabled upper half = $%02X (driven $%02X), want $00", got, driven)
+	}
+	if c.PRGRAM[0x200] != 0x00 {
+		t.Fatal("write to the disabled upper half was stored")
+	}
+
+	// 両方を読み書き可能にすると$7400-$7FFFは1kBのミラー
+	c.WriteByteAt(0xA001, 0xF0)
+	c.WriteByteAt(0x7200, 0x22)
+	if got, _ := read(0x7600); got != 0x22 {
+		t.Fatalf("$7600 = $%02X, want mirror of $7200 ($22)", got)
+	}
+	if got, _ := read(0x7C00); got != 0x11 {
+		t.Fatalf("$7C00 = $%02X, want mirror of $7000 ($11)", got)
+	}
+
+	// 読み取りのみ可能な場合は書き込みが無視される
+	c.WriteByteAt(0xA001, 0xA0)
+	c.WriteByteAt(0x7000, 0x99)
+	if got, _ := read(0x7000); got != 0x11 {
+		t.Fatalf("write-protected $7000 = $%02X, want $11", got)
+	}
+
+	// $6000-$6FFFには何も接続されていない
+	if _, driven := read(0x6000); driven != DRIVEN_NO_BITS {
+		t.Fatal("$6000 is driven on MMC6")
+	}
+
+	// どちらの半分も読み取りが無効な場合はオープンバス
+	c.WriteByteAt(0xA001, 0x00)
+	if _, driven := read(0x7000); driven != DRIVEN_NO_BITS {
+		t.Fatal("PRG-RAM is driven with both halves disabled")
+	}
+}
+
+// MARK: iNES 1.0 のHKROMと同じサイズのMMC3がMMC6として動作するかのテスト
+func TestMMC6DetectedFromINES(t *testing.T) {
+	image := []uint8{'N', 'E', 'S', 0x1A, 16, 16, 0x42, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]uint8, MMC6_HKROM_PRG_ROM_SIZE+MMC6_HKROM_CHR_ROM_SIZE)...)
+	c, err := Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := c.mapper.(*mmc3)
+	if _, ok := m.board.(txrom); !ok {
+		t.Fatalf("board before $8000 bit5 = %T, want txrom", m.board)
+	}
+
+	c.WriteByteAt(0x8000, 0x06)
+	if _, ok := m.board.(txrom); !ok {
+		t.Fatal("board changed without $8000 bit5")
+	}
+	c.WriteByteAt(0x8000, MMC6_PRG_RAM_ENABLE|0x06)
+	if _, ok := m.board.(*mmc6); !ok {
+		t.Fatalf("board after $8000 bit5 = %T, want *mmc6", m.board)
+	}
+
+	// 他のサイズのMMC3はbit5を書き込んでもMMC3のまま
+	image = []uint8{'N', 'E', 'S', 0x1A, 8, 16, 0x42, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]uint8, 128*1024+MMC6_HKROM_CHR_ROM_SIZE)...)
+	if c, err = Parse(image); err != nil {
+		t.Fatal(err)
+	}
+	c.WriteByteAt(0x8000, MMC6_PRG_RAM_ENABLE)
+	if _, ok := c.mapper.(*mmc3).board.(txrom); !ok {
+		t.Fatal("non-HKROM-sized MMC3 switched to MMC6")
+	}
+}
@@ -0,0 +1,236 @@
+package cartridge
+
+const (
+	N163_INTERNAL_RAM_SIZE = 128
+	N163_CIRAM_BANK        = 0xE0 // これ以上のバンク番号はCIRAM (bit0でページを選択) を割り当てる
+	N163_IRQ_COUNTER_MAX   = 0x7FFF
+	N163_PRG_RAM_WINDOW    = 2 * 1024 // 書き込み保護の単位
+)
+
+// MARK: Namco 163 (マッパー19) の定義
+type namco163 struct {
+	baseMapper
+
+	chrRegisters [8]uint8 // $8000-$BFFF
+	nametables   [4]uint8 // $C000-$DFFF
+	prg          [3]uint8 // $E000, $E800, $F000
+	ciramCHR     [2]bool  // $E800 bit6 / bit7 が0の場合、$E0以上のバンクでパターンテーブルにCIRAMを使う
+
+	ram           [N163_INTERNAL_RAM_SIZE]uint8 // 内蔵RAM (音源のレジスタを兼ねる)
+	ramAddress    uint8                         // $F800 bit0-6
+	autoIncrement bool                          // $F800 bit7
+	prgRAMProtect uint8                         // $F800 ($40の上位4bitと各2kBの保護bit)
+
+	irqCounter uint16 // 15bit
+	irqEnabled bool
+	irqPending bool
+
+	audio n163Audio
+}
+
+// MARK: Namco 163のコンストラクタ
+func newNamco163(c *Cartridge) *namco163 {
+	/*
+		$8000-$DFFF: 8kB 切り替え可能なバンク x3
+		$E000-$FFFF: 最後のバンクに固定
+		$6000-$7FFF: 8kB PRG-RAM (2kBごとに書き込み保護)
+		CHRは1kB単位で8つ、ネームテーブルも1kB単位でCHR-ROMまたはCIRAMを割り当てる
+	*/
+	m := &namco163{
+		baseMapper: newBaseMapper(c),
+	}
+	m.nametables = [4]uint8{N163_CIRAM_BANK, N163_CIRAM_BANK, N163_CIRAM_BANK + 1, N163_CIRAM_BANK + 1}
+	m.updateBanks()
+	return m
+}
+
+// MARK: CPUからの読み取り
+func (m *namco163) ReadPRG(address uint16) (uint8, bool) {
+	value, driven := m.PeekPRG(address)
+	if address&0xF800 == 0x4800 && m.autoIncrement {
+		m.ramAddress = (m.ramAddress + 1) & (N163_INTERNAL_RAM_SIZE - 1)
+	}
+	return value, driven
+}
+
+// MARK: CPUからの読み取り (副作用なし)
+func (m *namco163) PeekPRG(address uint16) (uint8, bool) {
+	switch address & 0xF800 {
+	case 0x4800:
+		return m.ram[m.ramAddress], true
+	case 0x5000:
+		return uint8(m.irqCounter), true
+	case 0x5800:
+		value := uint8(m.irqCounter >> 8)
+		if m.irqEnabled {
+			value |= 0x80
+		}
+		return value, true
+	default:
+		return m.baseMapper.PeekPRG(address)
+	}
+}
+
+// MARK: CPUからの書き込み
+func (m *namco163) WritePRG(address uint16, value uint8) {
+	switch address & 0xF800 {
+	case 0x4800:
+		// 内蔵RAMのデータポート
+		if m.ram[m.ramAddress] != value && m.cartridge.Header.Battery {
+			m.cartridge.markSaveDirty()
+		}
+		m.ram[m.ramAddress] = value
+		if m.autoIncrement {
+			m.ramAddress = (m.ramAddress + 1) & (N163_INTERNAL_RAM_SIZE - 1)
+		}
+		return
+	case 0x5000:
+		m.irqCounter = m.irqCounter&0x7F00 | uint16(value)
+		m.irqPending = false
+		return
+	case 0x5800:
+		m.irqCounter = m.irqCounter&0x00FF | uint16(value&0x7F)<<8
+		m.irqEnabled = value&0x80 != 0
+		m.irqPending = false
+		return
+	case 0x6000, 0x6800, 0x7000, 0x7800:
+		if m.prgRAMWritableAt(address) {
+			m.writePRGRAM(address, value)
+		}
+		return
+	case 0x8000, 0x8800, 0x9000, 0x9800, 0xA000, 0xA800, 0xB000, 0xB800:
+		m.chrRegisters[(address-0x8000)>>11] = value
+	case 0xC000, 0xC800, 0xD000, 0xD800:
+		m.nametables[(address-0xC000)>>11] = value
+		return
+	case 0xE000:
+		// bit6: 音源の無効化
+		m.prg[0] = value & 0x3F
+		m.audio.disabled = value&0x40 != 0
+	case 0xE800:
+		m.prg[1] = value & 0x3F
+		m.ciramCHR[0] = value&0x40 == 0
+		m.ciramCHR[1] = value&0x80 == 0
+	case 0xF000:
+		m.prg[2] = value & 0x3F
+	case 0xF800:
+		m.ramAddress = value & (N163_INTERNAL_RAM_SIZE - 1)
+		m.autoIncrement = value&0x80 != 0
+		m.prgRAMProtect = value
+		return
+	default:
+		return
+	}
+	m.updateBanks()
+}
+
+// MARK: PRG-RAMの書き込み保護の判定
+func (m *namco163) prgRAMWritableAt(address uint16) bool {
+	// $F800の上位4bitが$4で、対応する2kBの保護bitが0の場合のみ書き込める
+	if m.prgRAMProtect&0xF0 != 0x40 {
+		return false
+	}
+	window := (address - CPU_PRG_RAM_START) / N163_PRG_RAM_WINDOW
+	return m.prgRAMProtect&(1<<window) == 0
+}
+
+// MARK: バンクの更新
+func (m *namco163) updateBanks() {
+	for slot, bank := range m.prg {
+		m.setPRGBank8k(slot, int(bank))
+	}
+	m.setPRGBank8k(3, -1)
+
+	for slot, bank := range m.chrRegisters {
+		m.setCHRBank1k(slot, int(bank))
+	}
+}
+
+// MARK: パターンテーブルにCIRAMが割り当てられている場合のインデックス
+func (m *namco163) ciramIndex(address uint16) (int, bool) {
+	address &= PPU_PATTERN_TABLE_END
+	slot := address / CHR_BANK_SIZE
+	bank := m.chrRegisters[slot]
+	if bank < N163_CIRAM_BANK || !m.ciramCHR[slot/4] || len(m.cartridge.ciram) < 2*CHR_BANK_SIZE {
+		return 0, false
+	}
+	return int(bank&0x01)*CHR_BANK_SIZE + int(address%CHR_BANK_SIZE), true
+}
+
+// MARK: PPUからの読み取り ($0000-$1FFF)
+func (m *namco163) ReadCHR(address uint16) uint8 {
+	return m.PeekCHR(address)
+}
+
+// MARK: PPUからの書き込み ($0000-$1FFF)
+func (m *namco163) WriteCHR(address uint16, value uint8) {
+	if index, ok := m.ciramIndex(address); ok {
+		m.cartridge.ciram[index] = value
+		return
+	}
+	m.baseMapper.WriteCHR(address, value)
+}
+
+// MARK: PPUからの読み取り (副作用なし)
+func (m *namco163) PeekCHR(address uint16) uint8 {
+	if index, ok := m.ciramIndex(address); ok {
+		return m.cartridge.ciram[index]
+	}
+	return m.baseMapper.PeekCHR(address)
+}
+
+// MARK: PPUからのネームテーブルの読み取り ($2000-$2FFF)
+func (m *namco163) ReadNametable(address uint16) (uint8, bool) {
+	return m.PeekNametable(address)
+}
+
+// MARK: PPUからのネームテーブルの読み取り (副作用なし)
+func (m *namco163) PeekNametable(address uint16) (uint8, bool) {
+	// $E0未満のバンクはCHR-ROMの1kBをネームテーブルとして使う
+	bank := m.nametables[address>>10&0x03]
+	if bank >= N163_CIRAM_BANK {
+		return 0x00, false
+	}
+	return m.chr[bankOffset(int(bank), CHR_BANK_SIZE, len(m.chr))+int(address%CHR_BANK_SIZE)], true
+}
+
+// MARK: PPUからのネームテーブルへの書き込み ($2000-$2FFF)
+func (m *namco163) WriteNametable(address uint16, value uint8) bool {
+	// CHR-ROMが割り当てられている場合は書き込めない
+	return m.nametables[address>>10&0x03] < N163_CIRAM_BANK
+}
+
+// MARK: ネームテーブルに割り当てるCIRAMのページの取得
+func (m *namco163) NametablePage(table int) int {
+	return int(m.nametables[table&0x03] & 0x01)
+}
+
+// MARK: IRQの取得
+func (m *namco163) IRQ() bool {
+	return m.irqPending
+}
+
+// MARK: CPUサイクルの通知
+func (m *namco163) Tick() {
+	// 15bitのカウンタは$7FFFに達するとIRQを発生させて停止する
+	if m.irqEnabled && m.irqCounter < N163_IRQ_COUNTER_MAX {
+		m.irqCounter++
+		if m.irqCounter == N163_IRQ_COUNTER_MAX {
+			m.irqPending = true
+		}
+	}
+	m.audio.tick(&m.ram)
+}
+
+// MARK: 拡張音源の出力
+func (m *namco163) AudioOutput() float32 {
+	return m.audio.output(m.cartridge.smoothAudio)
+}
+
+// MARK: バッテリーバックアップされた内蔵RAM
+func (m *namco163) BatteryData() []uint8 {
+	if !m.cartridge.Header.Battery {
+		return nil
+	}
+	return m.ram[:]
+}
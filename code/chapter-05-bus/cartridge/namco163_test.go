@@ -0,0 +1,219 @@
+package cartridge
+
+import (
+	"math"
+	"testing"
+
+	"fc-emu/audio"
+)
+
+// MARK: テスト用のNamco 163
+func testNamco163(t *testing.T) *Cartridge {
+	t.Helper()
+	return newTestCartridge(t, testBoard{mapper: 19, prgROM: 256 * 1024, chrROM: 256 * 1024, prgRAM: 8 * 1024})
+}
+
+// MARK: 内蔵RAMへの書き込み (自動インクリメントで連続して書き込む)
+func writeN163RAM(c *Cartridge, address uint8, values ...uint8) {
+	c.WriteByteAt(0xF800, 0x80|address)
+	for _, value := range values {
+		c.WriteByteAt(0x4800, value)
+	}
+}
+
+// MARK: 15bitのIRQカウンタのテスト
+func TestNamco163IRQ(t *testing.T) {
+	c := testNamco163(t)
+	c.WriteByteAt(0x5000, 0xFD)
+	c.WriteByteAt(0x5800, 0xFF) // bit7: 有効化, bit0-6: 上位7bit
+
+	// 加算して$7FFFに達するとIRQを発生させる
+	c.Tick(1)
+	if c.IRQ() {
+		t.Fatal("IRQ before the counter reached $7FFF")
+	}
+	c.Tick(1)
+	if !c.IRQ() {
+		t.Fatal("no IRQ when the counter reached $7FFF")
+	}
+
+	// $7FFFで停止し、桁あふれしない
+	c.Tick(0x100)
+	low, _ := c.ReadByteFrom(0x5000)
+	high, _ := c.ReadByteFrom(0x5800)
+	if low != 0xFF || high != 0xFF {
+		t.Fatalf("counter = $%02X%02X, want $FFFF (enabled, $7FFF)", high, low)
+	}
+
+	// カウンタへの書き込みでIRQは解除される
+	c.WriteByteAt(0x5000, 0x00)
+	if c.IRQ() {
+		t.Fatal("writing $5000 did not acknowledge the IRQ")
+	}
+
+	// 無効な間はカウンタは進まない
+	c.WriteByteAt(0x5800, 0x7F)
+	c.Tick(0x10)
+	if low, _ := c.ReadByteFrom(0x5000); low != 0x00 || c.IRQ() {
+		t.Fatalf("disabled counter moved to $%02X", low)
+	}
+}
+
+// MARK: $F800によるPRG-RAMの書き込み保護のテスト
+func TestNamco163PRGRAMProtect(t *testing.T) {
+	tests := []struct {
+		name     string
+		protect  uint8   // $F800
+		writable [4]bool // $6000, $6800, $7000, $7800
+	}{
+		{"unlocked", 0x40, [4]bool{true, true, true, true}},
+		{"$6000 protected", 0x41, [4]bool{false, true, true, true}},
+		{"$6800 and $7800 protected", 0x4A, [4]bool{true, false, true, false}},
+		{"all protected", 0x4F, [4]bool{false, false, false, false}},
+		{"locked", 0x00, [4]bool{false, false, false, false}},
+		{"wrong key", 0x50, [4]bool{false, false, false, false}},
+		{"auto-increment bit set", 0xC0, [4]bool{false, false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testNamco163(t)
+			c.WriteByteAt(0xF800, tt.protect)
+			for window, want := range tt.writable {
+				address := CPU_PRG_RAM_START + uint16(window)*N163_PRG_RAM_WINDOW
+				c.WriteByteAt(address, 0xA5)
+				got, _ := c.PeekByteFrom(address)
+				if (got == 0xA5) != want {
+					t.Errorf("$%04X: writable = %v, want %v", address, got == 0xA5, want)
+				}
+			}
+		})
+	}
+}
+
+// MARK: $E0以上のバンクでCIRAMをパターンテーブルとネームテーブルに使うテスト
+func TestNamco163CIRAM(t *testing.T) {
+	c := testNamco163(t)
+	ciram := make([]uint8, 2*CHR_BANK_SIZE)
+	c.ConnectCIRAM(ciram)
+
+	// $E800 bit6が0の場合、$0000-$0FFFの$E0以上のバンクはCIRAM (bit0でページを選択)
+	c.WriteByteAt(0x8000, 0xE1)
+	c.WriteByteAt(0xE800, 0x00)
+	c.WriteCHR(0x0010, 0x77)
+	if ciram[CHR_BANK_SIZE+0x10] != 0x77 {
+		t.Fatal("CHR write did not reach CIRAM page 1")
+	}
+	if got := c.PeekCHR(0x0010); got != 0x77 {
+		t.Fatalf("PeekCHR($0010) = $%02X, want $77 from CIRAM", got)
+	}
+
+	// bit6が1の場合は同じバンク番号でもCHR-ROMを使う
+	c.WriteByteAt(0xE800, 0x40)
+	if got := chrBankAt(c, 0x0000); got != 0xE1 {
+		t.Fatalf("CHR slot 0: bank $%02X, want CHR-ROM bank $E1", got)
+	}
+
+	// $E800 bit7は$1000-$1FFFを制御する
+	c.WriteByteAt(0xA000, 0xE0)
+	c.WriteByteAt(0xE800, 0x40)
+	ciram[0x20] = 0x42
+	if got := c.PeekCHR(0x1020); got != 0x42 {
+		t.Fatalf("PeekCHR($1020) = $%02X, want $42 from CIRAM page 0", got)
+	}
+	c.WriteByteAt(0xE800, 0xC0)
+	if got := chrBankAt(c, 0x1000); got != 0xE0 {
+		t.Fatalf("CHR slot 4: bank $%02X, want CHR-ROM bank $E0", got)
+	}
+
+	// ネームテーブル: $E0未満はCHR-ROM (書き込み不可)、$E0以上はbit0のページのCIRAM
+	c.WriteByteAt(0xC000, 0x05)
+	c.WriteByteAt(0xC800, 0xE1)
+	if got, ok := c.ReadNametable(0x2000); !ok || got != 0x05 {
+		t.Fatalf("nametable 0 = $%02X (ok %v), want CHR-ROM bank 5", got, ok)
+	}
+	if !c.WriteNametable(0x2000, 0x99) {
+		t.Fatal("write to a CHR-ROM nametable reached CIRAM")
+	}
+	if _, ok := c.ReadNametable(0x2400); ok {
+		t.Fatal("nametable 1 did not fall through to CIRAM")
+	}
+	if page := c.NametablePage(1); page != 1 {
+		t.Fatalf("nametable 1 page = %d, want 1", page)
+	}
+}
+
+// MARK: $4800の内蔵RAMのデータポートの自動インクリメントのテスト
+func TestNamco163RAMPort(t *testing.T) {
+	c := testNamco163(t)
+	writeN163RAM(c, 0x7E, 0x11, 0x22, 0x33) // $7Fから$00へ折り返す
+
+	c.WriteByteAt(0xF800, 0x80|0x7E)
+	var got []uint8
+	for range 3 {
+		value, _ := c.ReadByteFrom(0x4800)
+		got = append(got, value)
+	}
+	if got[0] != 0x11 || got[1] != 0x22 || got[2] != 0x33 {
+		t.Fatalf("auto-increment read % X, want 11 22 33", got)
+	}
+
+	// bit7が0の場合はアドレスが進まない
+	c.WriteByteAt(0xF800, 0x7F)
+	c.WriteByteAt(0x4800, 0x44)
+	c.WriteByteAt(0x4800, 0x55)
+	for range 2 {
+		if value, _ := c.ReadByteFrom(0x4800); value != 0x55 {
+			t.Fatalf("read $%02X without auto-increment, want $55", value)
+		}
+	}
+	c.WriteByteAt(0xF800, 0x00)
+	if value, _ := c.ReadByteFrom(0x4800); value != 0x33 {
+		t.Fatalf("RAM[$00] = $%02X, want $33", value)
+	}
+}
+
+// MARK: 時分割多重と平均化した出力のテスト
+func TestNamco163AudioOutput(t *testing.T) {
+	c := testNamco163(t)
+	m := c.mapper.(*namco163)
+
+	// 波形: $00-$0Fはサンプル+7、$10-$1Fはサンプル-8
+	for address := uint8(0x00); address < 0x10; address++ {
+		writeN163RAM(c, address, 0xFF)
+		writeN163RAM(c, address+0x10, 0x00)
+	}
+	// チャンネル6: 波形$20 (-8)、チャンネル7: 波形$00 (+7)、ともに音量15で周波数0
+	writeN163RAM(c, 0x70, 0x00, 0x00, 0x00, 0x00, 0xF8, 0x00, 0x20, 0x0F)
+	writeN163RAM(c, 0x78, 0x00, 0x00, 0x00, 0x00, 0xF8, 0x00, 0x00, 0x1F) // bit4-6: 2チャンネル
+
+	level := func(value float64) float32 {
+		return float32(value / N163_CHANNEL_PEAK * N163_MIX_LEVEL * audio.PULSE_MAX_LEVEL)
+	}
+	near := func(got float32, want float32) bool {
+		return math.Abs(float64(got-want)) < 1e-6
+	}
+
+	// 時分割多重: 15 CPUサイクルごとにチャンネル7, 6, 7, ... の出力に切り替わる
+	for i, want := range []float64{105, -120, 105, -120} {
+		c.Tick(N163_CYCLES_PER_CHANNEL)
+		if got := m.AudioOutput(); !near(got, level(want)) {
+			t.Errorf("multiplexed step %d = %f, want %f", i, got, level(want))
+		}
+	}
+
+	// 平均化: 有効なチャンネルの平均を常に出力する
+	c.smoothAudio = true
+	for i := range 2 {
+		c.Tick(N163_CYCLES_PER_CHANNEL)
+		if got := m.AudioOutput(); !near(got, level(-7.5)) {
+			t.Errorf("smoothed step %d = %f, want %f", i, got, level(-7.5))
+		}
+	}
+
+	// $E000 bit6で音源を無効にすると無音になる
+	c.WriteByteAt(0xE000, 0x40)
+	if got := m.AudioOutput(); got != 0 {
+		t.Fatalf("disabled output = %f, want 0", got)
+	}
+}
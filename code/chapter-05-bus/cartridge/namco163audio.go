@@ -0,0 +1,95 @@
+package cartridge
+
+import "fc-emu/audio"
+
+const (
+	N163_MAX_CHANNELS          = 8
+	N163_CYCLES_PER_CHANNEL    = 15   // 1チャンネルの更新にかかるCPUサイクル数
+	N163_CHANNEL_REGISTERS     = 0x40 // 内蔵RAMの$40-$7Fがチャンネルのレジスタ
+	N163_CHANNEL_COUNT_ADDRESS = 0x7F // bit4-6: 有効なチャンネル数 - 1
+	N163_CHANNEL_PEAK          = 120  // 1チャンネルの出力の最大の振れ幅 (サンプル -8 x 音量15)
+
+	// 音量15の1チャンネルとAPUの矩形波 (音量15) の音量比
+	// N163は5Bよりもさらに大きな音で出力される (約+9dB)
+	N163_MIX_LEVEL = 2.8
+)
+
+// MARK: Namco 163の波形メモリ音源の定義
+type n163Audio struct {
+	disabled bool // $E000 bit6
+
+	cycles  int
+	channel int                    // 次に更新するチャンネル (7から降順)
+	current int                    // 現在出力しているチャンネル
+	active  int                    // 有効なチャンネル数
+	outputs [N163_MAX_CHANNELS]int // 各チャンネルの最後の出力 (-120 - 105)
+}
+
+// MARK: 有効なチャンネル数
+func (a *n163Audio) channels(ram *[N163_INTERNAL_RAM_SIZE]uint8) int {
+	return int(ram[N163_CHANNEL_COUNT_ADDRESS]>>4&0x07) + 1
+}
+
+// MARK: CPUサイクルの通知
+func (a *n163Audio) tick(ram *[N163_INTERNAL_RAM_SIZE]uint8) {
+	if a.disabled {
+		return
+	}
+	a.cycles++
+	if a.cycles < N163_CYCLES_PER_CHANNEL {
+		return
+	}
+	a.cycles = 0
+
+	// 有効なチャンネルを7から順に1つずつ更新し、出力も1チャンネルずつ切り替わる (時分割多重)
+	a.active = a.channels(ram)
+	if a.channel < N163_MAX_CHANNELS-a.active || a.channel >= N163_MAX_CHANNELS {
+		a.channel = N163_MAX_CHANNELS - 1
+	}
+	a.outputs[a.channel] = a.clockChannel(ram, a.channel)
+	a.current = a.channel
+	a.channel--
+}
+
+// MARK: 1チャンネル分の更新
+func (a *n163Audio) clockChannel(ram *[N163_INTERNAL_RAM_SIZE]uint8, channel int) int {
+	/*
+		+0 周波数 (下位), +1 位相 (下位)
+		+2 周波数 (中位), +3 位相 (中位)
+		+4 bit0-1 周波数 (上位), bit2-7 波形の長さ (256 - 4n), +5 位相 (上位)
+		+6 波形のアドレス (4bitサンプル単位)
+		+7 bit0-3 音量
+	*/
+	r := ram[N163_CHANNEL_REGISTERS+channel*8:][:8]
+	frequency := uint32(r[4]&0x03)<<16 | uint32(r[2])<<8 | uint32(r[0])
+	phase := uint32(r[5])<<16 | uint32(r[3])<<8 | uint32(r[1])
+	length := (256 - uint32(r[4]&0xFC)) << 16
+
+	phase = (phase + frequency) % length
+	r[1], r[3], r[5] = uint8(phase), uint8(phase>>8), uint8(phase>>16)
+
+	// 4bitのサンプルは下位ニブルが先
+	index := uint8(phase>>16) + r[6]
+	sample := ram[index>>1&(N163_INTERNAL_RAM_SIZE-1)]
+	if index&0x01 != 0 {
+		sample >>= 4
+	}
+	return (int(sample&0x0F) - 8) * int(r[7]&0x0F)
+}
+
+// MARK: 拡張音源の出力
+func (a *n163Audio) output(smooth bool) float32 {
+	if a.disabled {
+		return 0
+	}
+	value := float32(a.outputs[a.current])
+	if smooth {
+		// 有効なチャンネルの平均 (時分割多重をフィルタした場合と同じ音量になる)
+		var sum int
+		for channel := N163_MAX_CHANNELS - a.active; channel < N163_MAX_CHANNELS; channel++ {
+			sum += a.outputs[channel]
+		}
+		value = float32(sum) / float32(max(a.active, 1))
+	}
+	return value / N163_CHANNEL_PEAK * N163_MIX_LEVEL * audio.PULSE_MAX_LEVEL
+}
@@ -0,0 +1,200 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	NES20_SIZE_EXPONENT_MARKER = 0x0F // サイズの上位4bitがこの値の場合は指数表現
+	NES20_RAM_SHIFT_BASE       = 64   // RAMのサイズは 64 << シフト数 バイト
+	NES20_MAX_RAM_SHIFT        = 14   // 規格上の最大値 (1MB)
+	NES20_TIMING_MASK          = 0x03
+	NES20_MISC_ROMS_MASK       = 0x03
+	NES20_EXPANSION_MASK       = 0x3F
+)
+
+var (
+	ErrInvalidROMSize = errors.New("NES 2.0 header declares an invalid ROM size")
+	ErrInvalidRAMSize = errors.New("NES 2.0 header declares an invalid RAM size")
+)
+
+// MARK: コンソールの種類の定義
+type ConsoleType uint8
+
+const (
+	ConsoleTypeNES        ConsoleType = iota // ファミコン / NES
+	ConsoleTypeVsSystem                      // Vs. System
+	ConsoleTypePlayChoice                    // PlayChoice-10
+	ConsoleTypeExtended                      // 拡張コンソール (13バイト目で種類を指定)
+)
+
+// MARK: コンソールの種類の文字列表現
+func (c ConsoleType) String() string {
+	switch c {
+	case ConsoleTypeNES:
+		return "NES/Famicom"
+	case ConsoleTypeVsSystem:
+		return "Vs. System"
+	case ConsoleTypePlayChoice:
+		return "PlayChoice-10"
+	case ConsoleTypeExtended:
+		return "extended console"
+	default:
+		return fmt.Sprintf("ConsoleType(%d)", uint8(c))
+	}
+}
+
+// MARK: CPU/PPUのタイミング (地域) の定義
+type Timing uint8
+
+const (
+	TimingNTSC  Timing = iota // RP2C02 (北米 / 日本)
+	TimingPAL                 // RP2C07 (欧州)
+	TimingMulti               // 複数地域に対応
+	TimingDendy               // UMC 6527P (ロシア等の互換機)
+)
+
+const (
+	NTSC_CPU_CLOCK_HZ  = 1789773
+	PAL_CPU_CLOCK_HZ   = 1662607
+	DENDY_CPU_CLOCK_HZ = 1773448
+)
+
+// MARK: タイミングの文字列表現
+func (t Timing) String() string {
+	switch t {
+	case TimingNTSC:
+		return "NTSC"
+	case TimingPAL:
+		return "PAL"
+	case TimingMulti:
+		return "multi-region"
+	case TimingDendy:
+		return "Dendy"
+	default:
+		return fmt.Sprintf("Timing(%d)", uint8(t))
+	}
+}
+
+// MARK: 実行に使用する地域を返すメソッド
+func (t Timing) Region() Timing {
+	// 複数地域に対応したROMはNTSCで動作させる
+	if t == TimingMulti {
+		return TimingNTSC
+	}
+	return t
+}
+
+// MARK: CPUのクロック周波数を返すメソッド
+func (t Timing) CPUClockHz() int {
+	switch t.Region() {
+	case TimingPAL:
+		return PAL_CPU_CLOCK_HZ
+	case TimingDendy:
+		return DENDY_CPU_CLOCK_HZ
+	default:
+		return NTSC_CPU_CLOCK_HZ
+	}
+}
+
+// MARK: デフォルトの拡張デバイスの定義 (主要なもののみ名前を定義)
+type ExpansionDevice uint8
+
+const (
+	ExpansionUnspecified         ExpansionDevice = 0x00
+	ExpansionStandardControllers ExpansionDevice = 0x01
+	ExpansionFourScore           ExpansionDevice = 0x02
+	ExpansionFamicomFourPlayers  ExpansionDevice = 0x03
+	ExpansionVsSystem4016        ExpansionDevice = 0x04
+	ExpansionVsSystem4017        ExpansionDevice = 0x05
+	ExpansionZapper              ExpansionDevice = 0x08
+	ExpansionPowerPadSideA       ExpansionDevice = 0x0B
+	ExpansionArkanoidNES         ExpansionDevice = 0x0F
+	ExpansionArkanoidFamicom     ExpansionDevice = 0x10
+	ExpansionFamilyBasicKeyboard ExpansionDevice = 0x23
+)
+
+// MARK: NES 2.0 のROMサイズを算出する関数
+func nes20ROMSize(lsb uint8, msb uint8, unit int) (int, error) {
+	if msb != NES20_SIZE_EXPONENT_MARKER {
+		return (int(msb)<<8 | int(lsb)) * unit, nil
+	}
+
+	// 指数-乗数表現: EEEEEEMM → 2^E * (MM*2+1) バイト
+	exponent := lsb >> 2
+	multiplier := int(lsb&0x03)*2 + 1
+	if exponent > 30 {
+		return 0, fmt.Errorf("%w: 2^%d * %d bytes", ErrInvalidROMSize, exponent, multiplier)
+	}
+	return (1 << exponent) * multiplier, nil
+}
+
+// MARK: NES 2.0 のRAMサイズを算出する関数
+func nes20RAMSize(shift uint8) (int, error) {
+	if shift == 0 {
+		return 0, nil
+	}
+	if shift > NES20_MAX_RAM_SHIFT {
+		return 0, fmt.Errorf("%w: shift count %d", ErrInvalidRAMSize, shift)
+	}
+	return NES20_RAM_SHIFT_BASE << shift, nil
+}
+
+// MARK: NES 2.0 固有のフィールドを解析する関数
+func parseNES20Header(data []uint8, header *Header) error {
+	/*
+		NES 2.0 拡張フィールド
+
+		8  マッパー番号の上位4bit (bit0-3) / サブマッパー番号 (bit4-7)
+		9  PRG-ROMサイズの上位4bit (bit0-3) / CHR-ROMサイズの上位4bit (bit4-7)
+		10 PRG-RAMのシフト数 (bit0-3) / PRG-NVRAMのシフト数 (bit4-7)
+		11 CHR-RAMのシフト数 (bit0-3) / CHR-NVRAMのシフト数 (bit4-7)
+		12 CPU/PPUのタイミング (bit0-1)
+		13 Vs. SystemのPPU種別 (bit0-3) とハードウェア種別 (bit4-7) / 拡張コンソールの種類
+		14 その他のROMの数 (bit0-1)
+		15 デフォルトの拡張デバイス (bit0-5)
+	*/
+	header.Format = FormatNES20
+	header.Mapper |= uint16(data[8]&0x0F) << 8
+	header.Submapper = data[8] >> 4
+
+	var err error
+	if header.PRGROMSize, err = nes20ROMSize(data[4], data[9]&0x0F, PRG_ROM_BANK_SIZE); err != nil {
+		return fmt.Errorf("PRG-ROM: %w", err)
+	}
+	if header.CHRROMSize, err = nes20ROMSize(data[5], data[9]>>4, CHR_ROM_BANK_SIZE); err != nil {
+		return fmt.Errorf("CHR-ROM: %w", err)
+	}
+
+	ramSizes := []struct {
+		name  string
+		shift uint8
+		size  *int
+	}{
+		{"PRG-RAM", data[10] & 0x0F, &header.PRGRAMSize},
+		{"PRG-NVRAM", data[10] >> 4, &header.PRGNVRAMSize},
+		{"CHR-RAM", data[11] & 0x0F, &header.CHRRAMSize},
+		{"CHR-NVRAM", data[11] >> 4, &header.CHRNVRAMSize},
+	}
+	for _, ram := range ramSizes {
+		if *ram.size, err = nes20RAMSize(ram.shift); err != nil {
+			return fmt.Errorf("%s: %w", ram.name, err)
+		}
+	}
+
+	header.Timing = Timing(data[12] & NES20_TIMING_MASK)
+
+	switch header.ConsoleType {
+	case ConsoleTypeVsSystem:
+		header.VsPPUType = data[13] & 0x0F
+		header.VsHardwareType = data[13] >> 4
+	case ConsoleTypeExtended:
+		header.ExtendedConsole = data[13] & 0x0F
+	}
+
+	header.MiscROMs = data[14] & NES20_MISC_ROMS_MASK
+	header.ExpansionDevice = ExpansionDevice(data[15] & NES20_EXPANSION_MASK)
+
+	return nil
+}
@@ -0,0 +1,19 @@
+package cartridge
+
+// MARK: NROM (マッパー0) の定義
+type nrom struct {
+	baseMapper
+}
+
+// MARK: NROMのコンストラクタ
+func newNROM(c *Cartridge) *nrom {
+	/*
+		NROM-128: PRG-ROM 16kB ($C000-$FFFFは$8000-$BFFFのミラー)
+		NROM-256: PRG-ROM 32kB
+		CHR-ROM 8kB (CHR-ROMを持たない場合は8kBのCHR-RAM)
+		バンク切り替えやレジスタを持たず、ミラーリングははんだジャンパで固定
+	*/
+	return &nrom{
+		baseMapper: newBaseMapper(c),
+	}
+}
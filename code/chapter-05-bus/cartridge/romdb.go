@@ -0,0 +1,259 @@
+package cartridge
+
+import (
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+//go:embed romdb.xml
+var builtinDatabase []uint8
+
+var (
+	ErrDatabaseFormat = errors.New("unknown ROM database format (want .xml or .json)")
+	ErrDatabaseEntry  = errors.New("invalid ROM database entry")
+)
+
+// MARK: ROMデータベースのエントリの定義 (NES 2.0 データベース形式)
+type dbGame struct {
+	ROM      dbChecksum `xml:"rom"       json:"rom"`
+	PRGRAM   *dbSize    `xml:"prgram"    json:"prgram,omitempty"`
+	PRGNVRAM *dbSize    `xml:"prgnvram"  json:"prgnvram,omitempty"`
+	CHRRAM   *dbSize    `xml:"chrram"    json:"chrram,omitempty"`
+	CHRNVRAM *dbSize    `xml:"chrnvram"  json:"chrnvram,omitempty"`
+	PCB      *dbPCB     `xml:"pcb"       json:"pcb,omitempty"`
+	Console  *dbConsole `xml:"console"   json:"console,omitempty"`
+}
+
+type dbChecksum struct {
+	CRC32 string `xml:"crc32,attr" json:"crc32"`
+	SHA1  string `xml:"sha1,attr"  json:"sha1"`
+}
+
+type dbSize struct {
+	Size int `xml:"size,attr" json:"size"`
+}
+
+type dbPCB struct {
+	Mapper    *int   `xml:"mapper,attr"    json:"mapper,omitempty"`
+	Submapper *int   `xml:"submapper,attr" json:"submapper,omitempty"`
+	Mirroring string `xml:"mirroring,attr" json:"mirroring,omitempty"`
+	Battery   *int   `xml:"battery,attr"   json:"battery,omitempty"`
+}
+
+type dbConsole struct {
+	Type   *int `xml:"type,attr"   json:"type,omitempty"`
+	Region *int `xml:"region,attr" json:"region,omitempty"`
+}
+
+type dbFile struct {
+	Games []dbGame `xml:"game" json:"games"`
+}
+
+// MARK: ROMデータベースの定義
+type Database struct {
+	bySHA1  map[string]dbGame
+	byCRC32 map[uint32]dbGame
+}
+
+// MARK: 空のROMデータベースのコンストラクタ
+func NewDatabase() *Database {
+	return &Database{
+		bySHA1:  make(map[string]dbGame),
+		byCRC32: make(map[uint32]dbGame),
+	}
+}
+
+// MARK: 組み込みのROMデータベースを返す関数
+func BuiltinDatabase() *Database {
+	// romdb.xml は現在エントリを持たないため、ユーザーのデータベースを読み込むための土台となる
+	db := NewDatabase()
+	if err := db.LoadXML(bytes.NewReader(builtinDatabase)); err != nil {
+		panic(fmt.Sprintf("builtin ROM database is broken: %v", err)) // ビルド時に埋め込まれるため発生しない
+	}
+	return db
+}
+
+// MARK: ファイルからエントリを追加するメソッド (既存のエントリは上書きされる)
+func (db *Database) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".xml":
+		err = db.LoadXML(f)
+	case ".json":
+		err = db.LoadJSON(f)
+	default:
+		err = ErrDatabaseFormat
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// MARK: XML形式のエントリを追加するメソッド
+func (db *Database) LoadXML(r io.Reader) error {
+	var file dbFile
+	if err := xml.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	}
+	return db.add(file.Games)
+}
+
+// MARK: JSON形式のエントリを追加するメソッド
+func (db *Database) LoadJSON(r io.Reader) error {
+	var file dbFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return err
+	}
+	return db.add(file.Games)
+}
+
+// MARK: エントリを索引に追加するメソッド
+func (db *Database) add(games []dbGame) error {
+	for i, game := range games {
+		if game.ROM.SHA1 == "" && game.ROM.CRC32 == "" {
+			return fmt.Errorf("%w: game #%d has neither crc32 nor sha1", ErrDatabaseEntry, i)
+		}
+		if game.ROM.SHA1 != "" {
+			db.bySHA1[strings.ToUpper(game.ROM.SHA1)] = game
+		}
+		if game.ROM.CRC32 != "" {
+			crc, err := strconv.ParseUint(game.ROM.CRC32, 16, 32)
+			if err != nil {
+				return fmt.Errorf("%w: game #%d has crc32 %q", ErrDatabaseEntry, i, game.ROM.CRC32)
+			}
+			db.byCRC32[uint32(crc)] = game
+		}
+	}
+	return nil
+}
+
+// MARK: ヘッダの修正内容の定義
+type Correction struct {
+	Field string
+	From  string
+	To    string
+}
+
+// MARK: 修正内容の文字列表現
+func (c Correction) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Field, c.From, c.To)
+}
+
+// MARK: カートリッジのヘッダをデータベースに従って修正するメソッド
+func (db *Database) Correct(c *Cartridge) ([]Correction, error) {
+	// キーはPRG-ROMとCHR-ROMを連結したデータのチェックサム (ヘッダとトレーナーは含まない)
+	rom := make([]uint8, 0, len(c.PRGROM)+len(c.CHRROM))
+	rom = append(rom, c.PRGROM...)
+	rom = append(rom, c.CHRROM...)
+
+	sum := sha1.Sum(rom)
+	game, ok := db.bySHA1[strings.ToUpper(hex.EncodeToString(sum[:]))]
+	if !ok {
+		if game, ok = db.byCRC32[crc32.ChecksumIEEE(rom)]; !ok {
+			return nil, nil
+		}
+	}
+
+	header := c.Header
+	var corrections []Correction
+	correct := func(field string, from, to any) {
+		if from != to {
+			corrections = append(corrections, Correction{Field: field, From: fmt.Sprint(from), To: fmt.Sprint(to)})
+		}
+	}
+
+	if pcb := game.PCB; pcb != nil {
+		if pcb.Mapper != nil {
+			correct("mapper", header.Mapper, uint16(*pcb.Mapper))
+			header.Mapper = uint16(*pcb.Mapper)
+		}
+		if pcb.Submapper != nil {
+			correct("submapper", header.Submapper, uint8(*pcb.Submapper))
+			header.Submapper = uint8(*pcb.Submapper)
+		}
+		if mirroring, ok := dbMirroring(pcb.Mirroring); ok {
+			correct("mirroring", header.Mirroring, mirroring)
+			header.Mirroring = mirroring
+		}
+		if pcb.Battery != nil {
+			correct("battery", header.Battery, *pcb.Battery != 0)
+			header.Battery = *pcb.Battery != 0
+		}
+	}
+
+	// RAMのサイズはデータベースに記載がなければ0とみなす
+	ramSizes := []struct {
+		name string
+		db   *dbSize
+		size *int
+	}{
+		{"PRG-RAM", game.PRGRAM, &header.PRGRAMSize},
+		{"PRG-NVRAM", game.PRGNVRAM, &header.PRGNVRAMSize},
+		{"CHR-RAM", game.CHRRAM, &header.CHRRAMSize},
+		{"CHR-NVRAM", game.CHRNVRAM, &header.CHRNVRAMSize},
+	}
+	for _, ram := range ramSizes {
+		size := 0
+		if ram.db != nil {
+			size = ram.db.Size
+		}
+		correct(ram.name+" size", *ram.size, size)
+		*ram.size = size
+	}
+
+	if console := game.Console; console != nil {
+		if console.Type != nil {
+			correct("console type", header.ConsoleType, ConsoleType(*console.Type))
+			header.ConsoleType = ConsoleType(*console.Type)
+		}
+		if console.Region != nil {
+			correct("region", header.Timing, Timing(*console.Region))
+			header.Timing = Timing(*console.Region)
+		}
+	}
+
+	if len(corrections) > 0 {
+		// RAMのサイズやマッパーが変わっている可能性があるため作り直す
+		if err := c.applyHeader(header); err != nil {
+			return corrections, err
+		}
+	}
+	return corrections, nil
+}
+
+// MARK: データベースのミラーリング表記を解釈する関数
+func dbMirroring(value string) (Mirroring, bool) {
+	// "M" (マッパーが制御する) や未記載の場合はヘッダの値をそのまま使う
+	switch strings.ToUpper(value) {
+	case "H":
+		return MirroringHorizontal, true
+	case "V":
+		return MirroringVertical, true
+	case "4":
+		return MirroringFourScreen, true
+	case "1", "L", "A":
+		return MirroringSingleScreenLower, true
+	case "U", "B":
+		return MirroringSingleScreenUpper, true
+	default:
+		return MirroringHorizontal, false
+	}
+}
@@ -0,0 +1,234 @@
+package cartridge
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// MARK: テスト用のiNESイメージ (NROM, 16kB PRG-ROM + 8kB CHR-ROM, バッテリーなし)
+func testINESImage() []uint8 {
+	data := []uint8{'N', 'E', 'S', 0x1A, 1, 1, 0x00, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	for i := 0; i < PRG_ROM_BANK_SIZE+CHR_ROM_BANK_SIZE; i++ {
+		data = append(data, uint8(i*7))
+	}
+	return data
+}
+
+// MARK: データベースで作り直したカートリッジにマッパーが接続されているかのテスト
+func TestDatabaseCorrectKeepsMapperConnected(t *testing.T) {
+	image := testINESImage()
+	c, err := Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := NewDatabase()
+	entry := fmt.Sprintf(`<nes20db><game>
+		<rom crc32="%08X"/>
+		<pcb mapper="0" mirroring="V" battery="1"/>
+		<prgnvram size="8192"/>
+	</game></nes20db>`, crc32.ChecksumIEEE(image[INES_HEADER_SIZE:]))
+	if err := db.LoadXML(strings.NewReader(entry)); err != nil {
+		t.Fatal(err)
+	}
+
+	corrections, err := db.Correct(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corrections) == 0 {
+		t.Fatal("expected the header to be corrected")
+	}
+
+	if m := c.mapper.(*nrom); m.cartridge != c {
+		t.Fatal("mapper still points at the discarded cartridge")
+	}
+
+	// バッテリーバックアップされたPRG-RAMへの書き込みで保存が必要になる
+	c.WriteByteAt(CPU_PRG_RAM_START, 0x42)
+	if !c.saveDirty {
+		t.Fatal("write to PRG-NVRAM did not mark the save dirty")
+	}
+	if got, _ := c.ReadByteFrom(CPU_PRG_RAM_START); got != 0x42 {
+		t.Fatalf("PRG-RAM read = $%02X, want $42", got)
+	}
+}
+
+// MARK: テスト用のROM部分 (ヘッダを除いたPRG-ROM + CHR-ROM) のチェックサム
+func testROMChecksums(image []uint8) (crc string, sha string) {
+	sum := sha1.Sum(image[INES_HEADER_SIZE:])
+	return fmt.Sprintf("%08X", crc32.ChecksumIEEE(image[INES_HEADER_SIZE:])), hex.EncodeToString(sum[:])
+}
+
+// MARK: XML形式のエントリによる修正内容のテスト
+func TestDatabaseLoadXMLCorrections(t *testing.T) {
+	image := testINESImage()
+	c, err := Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crc, _ := testROMChecksums(image)
+
+	db := NewDatabase()
+	entry := fmt.Sprintf(`<nes20db><game>
+		<rom crc32="%s"/>
+		<pcb mapper="7" submapper="0" mirroring="1" battery="0"/>
+		<chrram size="8192"/>
+		<console type="0" region="1"/>
+	</game></nes20db>`, crc)
+	if err := db.LoadXML(strings.NewReader(entry)); err != nil {
+		t.Fatal(err)
+	}
+
+	corrections, err := db.Correct(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, correction := range corrections {
+		got = append(got, correction.String())
+	}
+	want := []string{
+		"mapper: 0 -> 7",
+		"mirroring: horizontal -> single-screen (lower)",
+		"PRG-RAM size: 8192 -> 0",
+		"CHR-RAM size: 0 -> 8192",
+		"region: NTSC -> PAL",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("corrections =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if _, ok := c.mapper.(*axrom); !ok {
+		t.Fatalf("mapper = %T, want *axrom", c.mapper)
+	}
+	if len(c.CHRRAM) != 8192 {
+		t.Fatalf("CHR-RAM = %d bytes, want 8192", len(c.CHRRAM))
+	}
+}
+
+// MARK: JSON形式のエントリとSHA-1による検索のテスト
+func TestDatabaseLoadJSONPrefersSHA1(t *testing.T) {
+	image := testINESImage()
+	crc, sha := testROMChecksums(image)
+
+	// 同じCRC32を持つ別のエントリよりもSHA-1が一致するエントリが優先される
+	db := NewDatabase()
+	entries := fmt.Sprintf(`{"games": [
+		{"rom": {"crc32": "%s"}, "pcb": {"mapper": 3}},
+		{"rom": {"sha1": "%s"}, "pcb": {"mapper": 2, "mirroring": "V"}}
+	]}`, crc, sha)
+	if err := db.LoadJSON(strings.NewReader(entries)); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Correct(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Header.Mapper != 2 || c.Header.Mirroring != MirroringVertical {
+		t.Fatalf("header = mapper %d, %v; want mapper 2, vertical", c.Header.Mapper, c.Header.Mirroring)
+	}
+
+	// SHA-1がなければCRC32で検索する
+	db = NewDatabase()
+	if err := db.LoadJSON(strings.NewReader(fmt.Sprintf(`{"games": [{"rom": {"crc32": "%s"}, "pcb": {"mapper": 3}}]}`, crc))); err != nil {
+		t.Fatal(err)
+	}
+	if c, err = Parse(image); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Correct(c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Header.Mapper != 3 {
+		t.Fatalf("mapper = %d, want 3 from the CRC32 entry", c.Header.Mapper)
+	}
+}
+
+// MARK: 一致しないROMや不正なエントリのテスト
+func TestDatabaseMissAndInvalidEntries(t *testing.T) {
+	c, err := Parse(testINESImage())
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrections, err := BuiltinDatabase().Correct(c)
+	if err != nil || corrections != nil {
+		t.Fatalf("Correct on unknown ROM = %v, %v; want no corrections", corrections, err)
+	}
+
+	invalid := []string{
+		`<nes20db><game><pcb mapper="1"/></game></nes20db>`,
+		`<nes20db><game><rom crc32="XYZ"/></game></nes20db>`,
+	}
+	for _, entry := range invalid {
+		if err := NewDatabase().LoadXML(strings.NewReader(entry)); !errors.Is(err, ErrDatabaseEntry) {
+			t.Errorf("LoadXML(%q) = %v, want ErrDatabaseEntry", entry, err)
+		}
+	}
+}
+
+// MARK: ミラーリング表記の解釈のテスト
+func TestDatabaseMirroring(t *testing.T) {
+	tests := []struct {
+		value     string
+		mirroring Mirroring
+		ok        bool
+	}{
+		{"H", MirroringHorizontal, true},
+		{"v", MirroringVertical, true},
+		{"4", MirroringFourScreen, true},
+		{"1", MirroringSingleScreenLower, true},
+		{"L", MirroringSingleScreenLower, true},
+		{"A", MirroringSingleScreenLower, true},
+		{"U", MirroringSingleScreenUpper, true},
+		{"B", MirroringSingleScreenUpper, true},
+		{"M", MirroringHorizontal, false},
+		{"", MirroringHorizontal, false},
+	}
+	for _, tt := range tests {
+		mirroring, ok := dbMirroring(tt.value)
+		if mirroring != tt.mirroring || ok != tt.ok {
+			t.Errorf("dbMirroring(%q) = %v, %v; want %v, %v", tt.value, mirroring, ok, tt.mirroring, tt.ok)
+		}
+	}
+}
+
+// MARK: データベースのサブマッパーでMMC6が選ばれるかのテスト
+func TestDatabaseSelectsMMC6(t *testing.T) {
+	// iNES 1.0 のマッパー4 (32kB PRG-ROM + 8kB CHR-ROM) はMMC3として読み込まれる
+	image := []uint8{'N', 'E', 'S', 0x1A, 2, 1, 0x40, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	image = append(image, make([]uint8, 2*PRG_ROM_BANK_SIZE+CHR_ROM_BANK_SIZE)...)
+	c, err := Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crc, _ := testROMChecksums(image)
+
+	db := NewDatabase()
+	entry := fmt.Sprintf(`<nes20db><game>
+		<rom crc32="%s"/>
+		<pcb mapper="4" submapper="1" mirroring="V" battery="1"/>
+		<prgnvram size="1024"/>
+	</game></nes20db>`, crc)
+	if err := db.LoadXML(strings.NewReader(entry)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Correct(c); err != nil {
+		t.Fatal(err)
+	}
+	m, ok := c.mapper.(*mmc3)
+	if !ok {
+		t.Fatalf("mapper = %T, want *mmc3", c.mapper)
+	}
+	if _, ok := m.board.(*mmc6); !ok {
+		t.Fatalf("board = %T, want *mmc6", m.board)
+	}
+}
@@ -0,0 +1,190 @@
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const (
+	SAVE_FILE_EXTENSION = ".sav"
+	SAVE_FILE_MODE      = 0o644
+	AUTOSAVE_INTERVAL   = 5 // 秒 (エミュレーション上の時間)
+)
+
+// セーブファイルの名前を決める際にROMのパスから取り除く拡張子
+var romFileExtensions = []string{".gz", ".zip", ".nes", ".unf", ".unif"}
+
+// MARK: ROMのパスからセーブファイルのパスを決める関数
+func SavePathFor(romPath string) string {
+	// game.nes.gz → game.sav
+	base := romPath
+	for {
+		ext := strings.ToLower(filepath.Ext(base))
+		trimmed := false
+		for _, romExt := range romFileExtensions {
+			if ext == romExt {
+				base = base[:len(base)-len(ext)]
+				trimmed = true
+				break
+			}
+		}
+		if !trimmed {
+			return base + SAVE_FILE_EXTENSION
+		}
+	}
+}
+
+// MARK: バッテリーバックアップされた領域を返すメソッド
+func (c *Cartridge) batteryBackedData() [][]uint8 {
+	// EEPROMはバッテリーを必要としないため、ヘッダのバッテリーの有無に関わらず保存する
+	if m, ok := c.mapper.(nvramMapper); ok && m.ReplacesPRGNVRAM() {
+		if data := m.BatteryData(); len(data) > 0 {
+			return [][]uint8{data}
+		}
+		return nil
+	}
+
+	// セーブファイルにはPRG-RAM、CHR-RAM、マッパー内蔵のメモリの順に保存する
+	var regions [][]uint8
+	if c.Header.Battery && c.Header.PRGNVRAMSize > 0 {
+		regions = append(regions, c.PRGRAM[:c.Header.PRGNVRAMSize])
+	}
+	if c.Header.Battery && c.Header.CHRNVRAMSize > 0 {
+		regions = append(regions, c.CHRRAM[:c.Header.CHRNVRAMSize])
+	}
+	if m, ok := c.mapper.(batteryMapper); ok {
+		if data := m.BatteryData(); len(data) > 0 {
+			regions = append(regions, data)
+		}
+	}
+	return regions
+}
+
+// MARK: バッテリーバックアップされたメモリの変更を記録するメソッド
+func (c *Cartridge) markSaveDirty() {
+	c.saveDirty = true
+}
+
+// MARK: セーブファイルを関連付けて読み込むメソッド
+func (c *Cartridge) AttachSaveFile(path string) error {
+	regions := c.batteryBackedData()
+	if len(regions) == 0 {
+		return nil // バッテリーバックアップされたメモリを持たない
+	}
+	c.savePath = path
+
+	saved, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil // 初回起動
+	}
+	if err != nil {
+		return err
+	}
+
+	// サイズが異なる場合も読み込める分だけ復元する
+	for _, region := range regions {
+		n := copy(region, saved)
+		saved = saved[n:]
+	}
+	c.saveLoaded = true
+	return nil
+}
+
+// MARK: セーブファイルへ書き出すメソッド
+func (c *Cartridge) Save() error {
+	regions := c.batteryBackedData()
+	if c.savePath == "" || len(regions) == 0 {
+		return nil
+	}
+
+	var data []uint8
+	for _, region := range regions {
+		data = append(data, region...)
+	}
+	if err := writeFileAtomic(c.savePath, data); err != nil {
+		return fmt.Errorf("save %s: %w", c.savePath, err)
+	}
+	c.saveDirty = false
+	return nil
+}
+
+// MARK: 終了時の処理
+func (c *Cartridge) Close() error {
+	// 定期保存で発生したエラーも合わせて報告する
+	err := c.autosaveErr
+	if c.saveDirty {
+		err = errors.Join(err, c.Save())
+	}
+	return err
+}
+
+// MARK: 定期保存
+func (c *Cartridge) autosave(cycles uint64) {
+	if c.savePath == "" {
+		return
+	}
+
+	// 一定時間ごとに変更があればセーブファイルへ書き出す
+	c.autosaveCycles += cycles
+	if c.autosaveCycles < uint64(c.Region().CPUClockHz())*AUTOSAVE_INTERVAL {
+		return
+	}
+	c.autosaveCycles = 0
+
+	if c.saveDirty {
+		if err := c.Save(); err != nil {
+			c.autosaveErr = err
+		}
+	}
+}
+
+// MARK: ファイルを原子的に書き換える関数
+func writeFileAtomic(path string, data []uint8) error {
+	// 同じディレクトリの一時ファイルに書き込んでから置き換えることで、
+	// 書き込み中にクラッシュしても元のファイルが壊れないようにする
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // 置き換えに成功した場合は何もしない
+
+	if err := tmp.Chmod(SAVE_FILE_MODE); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// MARK: ディレクトリのエントリの変更をディスクへ書き出す関数
+func syncDir(dir string) error {
+	// 置き換え (リネーム) 自体を電源断から保護する
+	// Windowsではディレクトリを同期できないため何もしない
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,174 @@
+package cartridge
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fc-emu/ram"
+)
+
+// MARK: バッテリーバックアップされたPRG-RAMを持つテスト用のカートリッジ
+func testBatteryCartridge(t *testing.T) *Cartridge {
+	t.Helper()
+	return newTestCartridge(t, testBoard{mapper: 0, prgROM: 32 * 1024, chrROM: 8 * 1024, prgNVRAM: 8 * 1024, prgRAM: 8 * 1024})
+}
+
+// MARK: セーブファイルの原子的な書き込みのテスト
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "game.sav")
+
+	for _, data := range []string{"first", "second"} {
+		if err := writeFileAtomic(path, []uint8(data)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != data {
+			t.Fatalf("file = %q, want %q", got, data)
+		}
+	}
+
+	// 一時ファイルが残らない
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("directory has %d entries, want only the save file", len(entries))
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != SAVE_FILE_MODE {
+		t.Fatalf("mode = %v, want %v", info.Mode().Perm(), os.FileMode(SAVE_FILE_MODE))
+	}
+}
+
+// MARK: 保存したPRG-NVRAMを読み込み直すテスト
+func TestSaveAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+
+	c := testBatteryCartridge(t)
+	if err := c.AttachSaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	c.WriteByteAt(0x6123, 0x42)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// セーブファイルにはバッテリーバックアップされた部分のみが保存される
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 8*1024 {
+		t.Fatalf("save file has %d bytes, want %d", len(saved), 8*1024)
+	}
+
+	c = testBatteryCartridge(t)
+	if err := c.AttachSaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := c.ReadByteFrom(0x6123); got != 0x42 {
+		t.Fatalf("reloaded $6123 = $%02X, want $42", got)
+	}
+}
+
+// MARK: 定期保存のテスト
+func TestAutosave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+
+	c := testBatteryCartridge(t)
+	if err := c.AttachSaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	c.WriteByteAt(0x6000, 0x99)
+
+	// 一定時間が経過するまでは書き出さない
+	interval := uint64(c.Region().CPUClockHz()) * AUTOSAVE_INTERVAL
+	c.Tick(interval - 1)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("save file written before the autosave interval (err = %v)", err)
+	}
+	c.Tick(1)
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("autosave did not write the file: %v", err)
+	}
+	if saved[0] != 0x99 {
+		t.Fatalf("autosaved $6000 = $%02X, want $99", saved[0])
+	}
+	if c.saveDirty {
+		t.Fatal("autosave did not clear the dirty flag")
+	}
+}
+
+// MARK: 電源投入時にセーブファイルから復元したPRG-NVRAMを初期化しないかのテスト
+func TestPowerOnKeepsLoadedNVRAM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	saved := make([]uint8, 8*1024)
+	saved[0x10] = 0x42
+	if err := os.WriteFile(path, saved, SAVE_FILE_MODE); err != nil {
+		t.Fatal(err)
+	}
+
+	c := testBatteryCartridge(t)
+	if err := c.AttachSaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	c.PowerOn(ram.NewInitializer(ram.PowerOnState{Pattern: ram.InitFF}))
+	if c.PRGRAM[0x10] != 0x42 || c.PRGRAM[0x11] != 0x00 {
+		t.Fatalf("PRG-NVRAM = $%02X $%02X, want the saved $42 $00", c.PRGRAM[0x10], c.PRGRAM[0x11])
+	}
+	if c.PRGRAM[8*1024] != 0xFF {
+		t.Fatalf("volatile PRG-RAM = $%02X, want the power-on fill $FF", c.PRGRAM[8*1024])
+	}
+
+	// セーブファイルがない場合はすべて初期化する
+	c = testBatteryCartridge(t)
+	c.PowerOn(ram.NewInitializer(ram.PowerOnState{Pattern: ram.InitFF}))
+	if c.PRGRAM[0x10] != 0xFF {
+		t.Fatalf("PRG-NVRAM without a save = $%02X, want $FF", c.PRGRAM[0x10])
+	}
+}
+
+// MARK: バッテリーバックアップされたCHR-RAMの保存のテスト
+func TestSaveCHRNVRAM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game.sav")
+	board := testBoard{mapper: 0, prgROM: 32 * 1024, prgNVRAM: 8 * 1024, chrNVRAM: 8 * 1024}
+
+	c := newTestCartridge(t, board)
+	if err := c.AttachSaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	c.WriteCHR(0x0123, 0x42)
+	if !c.saveDirty {
+		t.Fatal("write to CHR-NVRAM did not mark the save dirty")
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// PRG-NVRAMの後にCHR-NVRAMが続く
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != 16*1024 || saved[8*1024+0x123] != 0x42 {
+		t.Fatalf("save file has %d bytes, want 16kB with CHR-NVRAM after PRG-NVRAM", len(saved))
+	}
+
+	c = newTestCartridge(t, board)
+	if err := c.AttachSaveFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.ReadCHR(0x0123); got != 0x42 {
+		t.Fatalf("reloaded CHR $0123 = $%02X, want $42", got)
+	}
+}
@@ -0,0 +1,29 @@
+package cartridge
+
+const (
+	TQROM_CHR_RAM_BIT = 0x40 // CHRバンクのbit6が1の場合はCHR-RAMを選択する
+)
+
+// MARK: TQROM (マッパー119) の定義
+type tqrom struct {
+	txrom
+}
+
+// MARK: TQROMのコンストラクタ
+func newTQROM(c *Cartridge) *mmc3 {
+	// CHR-ROMとは別に8kBのCHR-RAMを持つ
+	if len(c.CHRRAM) == 0 {
+		c.CHRRAM = make([]uint8, INES_DEFAULT_CHR_RAM_SIZE)
+	}
+	return newMMC3WithBoard(c, tqrom{})
+}
+
+func (tqrom) applyCHRBanks(m *mmc3, banks [CHR_BANK_SLOTS]int) {
+	for slot, bank := range banks {
+		if bank&TQROM_CHR_RAM_BIT != 0 {
+			m.setCHRRAMBank1k(slot, bank&^TQROM_CHR_RAM_BIT)
+		} else {
+			m.setCHRBank1k(slot, bank)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package cartridge
+
+import "testing"
+
+// MARK: TQROMのCHR-RAM / CHR-ROMの選択のテスト
+func TestTQROMCHRSelect(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 119, prgROM: 128 * 1024, chrROM: 64 * 1024})
+
+	// bit6が1のバンクはCHR-RAM、0のバンクはCHR-ROM
+	c.WriteByteAt(0x8000, 0x02)
+	c.WriteByteAt(0x8001, TQROM_CHR_RAM_BIT|0x01)
+	c.WriteByteAt(0x8000, 0x03)
+	c.WriteByteAt(0x8001, 0x05)
+
+	c.WriteCHR(0x1000, 0x99)
+	if got := c.CHRRAM[CHR_BANK_SIZE]; got != 0x99 {
+		t.Fatalf("CHR-RAM bank 1 = $%02X, want $99", got)
+	}
+	if got := c.ReadCHR(0x1000); got != 0x99 {
+		t.Fatalf("$1000 = $%02X, want $99 from CHR-RAM", got)
+	}
+
+	if got := chrBankAt(c, 0x1400); got != 5 {
+		t.Fatalf("$1400 maps CHR-ROM bank %d, want 5", got)
+	}
+	c.WriteCHR(0x1400, 0x99)
+	if got := chrBankAt(c, 0x1400); got != 5 {
+		t.Fatal("write to CHR-ROM was stored")
+	}
+
+	// CHR-ROMに戻すとRAMの内容は見えなくなる
+	c.WriteByteAt(0x8000, 0x02)
+	c.WriteByteAt(0x8001, 0x01)
+	if got := chrBankAt(c, 0x1000); got != 1 {
+		t.Fatalf("$1000 maps CHR-ROM bank %d, want 1", got)
+	}
+}
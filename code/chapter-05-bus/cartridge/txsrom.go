@@ -0,0 +1,39 @@
+package cartridge
+
+const (
+	TXSROM_NAMETABLE_BIT = 0x80 // CHRバンクのbit7がCIRAMのA10に接続されている
+)
+
+// MARK: TxSROM (マッパー118, TKSROM / TLSROM) の定義
+type txsrom struct {
+	txrom
+
+	nametables [4]int // 各ネームテーブルに割り当てるCIRAMのページ
+}
+
+// MARK: TxSROMのコンストラクタ
+func newTxSROM(c *Cartridge) *mmc3 {
+	return newMMC3WithBoard(c, &txsrom{})
+}
+
+func (b *txsrom) applyCHRBanks(m *mmc3, banks [CHR_BANK_SLOTS]int) {
+	b.txrom.applyCHRBanks(m, banks)
+
+	// PPU $0000-$0FFFに割り当てた1kBバンクのbit7が各ネームテーブルのページを決める
+	// (2kBバンクの場合は2つのネームテーブルが同じページになる)
+	for table := range b.nametables {
+		if banks[table]&TXSROM_NAMETABLE_BIT != 0 {
+			b.nametables[table] = 1
+		} else {
+			b.nametables[table] = 0
+		}
+	}
+}
+
+func (b *txsrom) writeMirroring(m *mmc3, value uint8) {
+	// ミラーリングレジスタはCIRAMのA10に接続されていないため無視する
+}
+
+func (b *txsrom) nametablePage(m *mmc3, table int) int {
+	return b.nametables[table&0x03]
+}
@@ -0,0 +1,35 @@
+package cartridge
+
+import "testing"
+
+// MARK: TxSROMのCHRバンクによるネームテーブルの選択のテスト
+func TestTxSROMNametables(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 118, prgROM: 128 * 1024, chrROM: 128 * 1024})
+	pages := func() [4]int {
+		return [4]int{c.NametablePage(0), c.NametablePage(1), c.NametablePage(2), c.NametablePage(3)}
+	}
+
+	// 2kBバンク (R0 / R1) の場合は2つのネームテーブルが同じページになる
+	c.WriteByteAt(0x8000, 0x00)
+	c.WriteByteAt(0x8001, TXSROM_NAMETABLE_BIT)
+	c.WriteByteAt(0x8000, 0x01)
+	c.WriteByteAt(0x8001, 0x02)
+	if got, want := pages(), [4]int{1, 1, 0, 0}; got != want {
+		t.Fatalf("pages = %v, want %v", got, want)
+	}
+
+	// ミラーリングレジスタは無視される
+	c.WriteByteAt(0xA000, 0x01)
+	if got, want := pages(), [4]int{1, 1, 0, 0}; got != want {
+		t.Fatalf("pages after $A000 = %v, want %v", got, want)
+	}
+
+	// CHR反転の場合は1kBバンク (R2-R5) が各ネームテーブルを選択する
+	for i, bank := range []uint8{0x00, TXSROM_NAMETABLE_BIT, 0x01, TXSROM_NAMETABLE_BIT | 0x01} {
+		c.WriteByteAt(0x8000, 0x80|uint8(2+i))
+		c.WriteByteAt(0x8001, bank)
+	}
+	if got, want := pages(), [4]int{0, 1, 0, 1}; got != want {
+		t.Fatalf("pages with CHR inversion = %v, want %v", got, want)
+	}
+}
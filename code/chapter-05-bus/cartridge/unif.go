@@ -0,0 +1,279 @@
+package cartridge
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	UNIF_HEADER_SIZE       = 32 // マジックナンバー + リビジョン + 予約領域
+	UNIF_CHUNK_HEADER_SIZE = 8  // チャンクID + データ長
+
+	UNIF_DEFAULT_PRG_RAM_SIZE = 8 * 1024
+	UNIF_DEFAULT_CHR_RAM_SIZE = 8 * 1024
+)
+
+var UNIF_MAGIC = []uint8{'U', 'N', 'I', 'F'}
+
+var (
+	ErrUNIFTruncated       = errors.New("UNIF chunk extends past the end of the file")
+	ErrUNIFNoBoard         = errors.New("UNIF file has no MAPR (board name) chunk")
+	ErrUnsupportedBoard    = errors.New("unsupported UNIF board")
+	ErrUNIFInvalidMirror   = errors.New("UNIF MIRR chunk has an invalid value")
+	ErrUNIFMissingPRGChunk = errors.New("UNIF file has no PRG chunks")
+)
+
+// MARK: UNIFのボードの定義
+type unifBoard struct {
+	mapper     uint16
+	submapper  uint8
+	fourScreen bool // ボード上にVRAMを持ち、MIRRチャンクに関わらず4画面となる
+	prgRAM     int  // PRG-RAMのサイズ (0の場合は UNIF_DEFAULT_PRG_RAM_SIZE)
+	prgNVRAM   int  // BATRチャンクがある場合にバッテリーバックアップされるサイズ (0の場合はPRG-RAM全体)
+}
+
+// MARK: UNIFのボード名とマッパー番号の対応表 ("NES-" / "HVC-" の接頭辞を除いた名前)
+var unifBoards = map[string]unifBoard{
+	// NROM
+	"NROM":     {mapper: 0},
+	"NROM-128": {mapper: 0},
+	"NROM-256": {mapper: 0},
+	"RROM":     {mapper: 0},
+	"RROM-128": {mapper: 0},
+	"SROM":     {mapper: 0},
+
+	// MMC1
+	"SAROM":  {mapper: 1},
+	"SBROM":  {mapper: 1},
+	"SCROM":  {mapper: 1},
+	"SC1ROM": {mapper: 1},
+	"SEROM":  {mapper: 1, submapper: 5},
+	"SFROM":  {mapper: 1},
+	"SGROM":  {mapper: 1},
+	"SHROM":  {mapper: 1, submapper: 5},
+	"SJROM":  {mapper: 1},
+	"SKROM":  {mapper: 1},
+	"SLROM":  {mapper: 1},
+	"SL1ROM": {mapper: 1},
+	"SNROM":  {mapper: 1},
+	"SOROM":  {mapper: 1, prgRAM: 16 * 1024, prgNVRAM: 8 * 1024},
+	"SUROM":  {mapper: 1},
+	"SXROM":  {mapper: 1, prgRAM: 32 * 1024},
+
+	// UxROM / CNROM / AxROM
+	"UNROM":  {mapper: 2},
+	"UOROM":  {mapper: 2},
+	"CNROM":  {mapper: 3},
+	"AMROM":  {mapper: 7},
+	"ANROM":  {mapper: 7},
+	"AN1ROM": {mapper: 7},
+	"AOROM":  {mapper: 7},
+
+	// MMC3 / MMC6
+	"TBROM":  {mapper: 4},
+	"TEROM":  {mapper: 4},
+	"TFROM":  {mapper: 4},
+	"TGROM":  {mapper: 4},
+	"TKROM":  {mapper: 4},
+	"TLROM":  {mapper: 4},
+	"TL1ROM": {mapper: 4},
+	"TNROM":  {mapper: 4},
+	"TR1ROM": {mapper: 4, fourScreen: true},
+	"TSROM":  {mapper: 4},
+	"TVROM":  {mapper: 4, fourScreen: true},
+	"HKROM":  {mapper: 4, submapper: 1},
+	"TKSROM": {mapper: 118},
+	"TLSROM": {mapper: 118},
+	"TQROM":  {mapper: 119},
+
+	// MMC5
+	"EKROM": {mapper: 5},
+	"ELROM": {mapper: 5},
+	"ETROM": {mapper: 5, prgRAM: 16 * 1024, prgNVRAM: 8 * 1024},
+	"EWROM": {mapper: 5, prgRAM: 32 * 1024},
+
+	// MMC2 / MMC4
+	"PEEOROM": {mapper: 9},
+	"PNROM":   {mapper: 9},
+	"FJROM":   {mapper: 10},
+	"FKROM":   {mapper: 10},
+}
+
+// MARK: ボード名からボードの定義を検索する関数
+func lookupUNIFBoard(name string) (unifBoard, bool) {
+	// 任天堂の基板は北米版 (NES-) と国内版 (HVC-) で同じ構成のため区別しない
+	// (UNL- / BMC- 等の基板は名前ごとに構成が異なり、対応表に含まれない)
+	for _, prefix := range []string{"NES-", "HVC-"} {
+		if strings.HasPrefix(name, prefix) {
+			name = strings.TrimPrefix(name, prefix)
+			break
+		}
+	}
+	board, ok := unifBoards[name]
+	return board, ok
+}
+
+// MARK: UNIFファイルを解析する関数
+func parseUNIF(data []uint8) (*Cartridge, error) {
+	/*
+		UNIF ファイル形式
+
+		0-3   "UNIF"
+		4-7   リビジョン (リトルエンディアン)
+		8-31  予約領域
+		32-   チャンクの列 (ID 4バイト + データ長 4バイト + データ)
+
+		MAPR       ボード名 (NULL終端)
+		PRG0-PRGF  PRG-ROM (番号順に連結する)
+		CHR0-CHRF  CHR-ROM (番号順に連結する)
+		MIRR       ミラーリング
+		BATR       バッテリーバックアップの有無
+		TVCI       TV方式
+	*/
+	if len(data) < UNIF_HEADER_SIZE {
+		return nil, fmt.Errorf("%w: got %d bytes, need %d", ErrHeaderTooShort, len(data), UNIF_HEADER_SIZE)
+	}
+
+	var (
+		boardName string
+		prgChunks [16][]uint8
+		chrChunks [16][]uint8
+		mirroring = MirroringHorizontal
+		battery   bool
+		timing    = TimingNTSC
+	)
+
+	offset := UNIF_HEADER_SIZE
+	for offset < len(data) {
+		if len(data)-offset < UNIF_CHUNK_HEADER_SIZE {
+			return nil, fmt.Errorf("%w: chunk header at offset %d", ErrUNIFTruncated, offset)
+		}
+		id := string(data[offset : offset+4])
+		length := int(binary.LittleEndian.Uint32(data[offset+4 : offset+8]))
+		offset += UNIF_CHUNK_HEADER_SIZE
+
+		if length > len(data)-offset {
+			return nil, fmt.Errorf("%w: %s chunk at offset %d declares %d bytes", ErrUNIFTruncated, id, offset, length)
+		}
+		chunk := data[offset : offset+length]
+		offset += length
+
+		switch {
+		case id == "MAPR":
+			boardName = string(bytes.TrimRight(chunk, "\x00"))
+		case strings.HasPrefix(id, "PRG"), strings.HasPrefix(id, "CHR"):
+			index, ok := unifChunkIndex(id[3])
+			if !ok {
+				continue // PRG/CHRで始まる未知のチャンクは無視する
+			}
+			if id[0] == 'P' {
+				prgChunks[index] = chunk
+			} else {
+				chrChunks[index] = chunk
+			}
+		case id == "MIRR" && length > 0:
+			var err error
+			if mirroring, err = unifMirroring(chunk[0]); err != nil {
+				return nil, err
+			}
+		case id == "BATR":
+			battery = length == 0 || chunk[0] != 0
+		case id == "TVCI" && length > 0:
+			switch chunk[0] {
+			case 1:
+				timing = TimingPAL
+			case 2:
+				timing = TimingMulti
+			}
+		default:
+			// NAME, READ, DINF, CTRL, PCK0 等の付加情報は使用しない
+		}
+	}
+
+	if boardName == "" {
+		return nil, ErrUNIFNoBoard
+	}
+	board, ok := lookupUNIFBoard(boardName)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedBoard, boardName)
+	}
+	if board.fourScreen {
+		mirroring = MirroringFourScreen
+	}
+
+	prgROM := bytes.Join(prgChunks[:], nil)
+	chrROM := bytes.Join(chrChunks[:], nil)
+	if len(prgROM) == 0 {
+		return nil, ErrUNIFMissingPRGChunk
+	}
+	if len(chrROM) == 0 {
+		chrROM = nil
+	}
+	if err := validateROMSizes(len(prgROM), len(chrROM)); err != nil {
+		return nil, err
+	}
+
+	header := Header{
+		Format:     FormatUNIF,
+		PRGROMSize: len(prgROM),
+		CHRROMSize: len(chrROM),
+		Mapper:     board.mapper,
+		Submapper:  board.submapper,
+		Mirroring:  mirroring,
+		Battery:    battery,
+		Timing:     timing,
+	}
+	prgRAM := board.prgRAM
+	if prgRAM == 0 {
+		prgRAM = UNIF_DEFAULT_PRG_RAM_SIZE
+	}
+	header.PRGRAMSize = prgRAM
+	if battery {
+		// SOROM / ETROM は片方の8kBのみバッテリーバックアップされる
+		header.PRGNVRAMSize = prgRAM
+		if board.prgNVRAM > 0 {
+			header.PRGNVRAMSize = board.prgNVRAM
+		}
+		header.PRGRAMSize = prgRAM - header.PRGNVRAMSize
+	}
+	if header.CHRROMSize == 0 {
+		header.CHRRAMSize = UNIF_DEFAULT_CHR_RAM_SIZE
+	}
+
+	return newCartridge(header, prgROM, chrROM, nil)
+}
+
+// MARK: PRG/CHRチャンクの番号を解釈する関数 (0-9, A-F)
+func unifChunkIndex(c uint8) (int, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c - '0'), true
+	case 'A' <= c && c <= 'F':
+		return int(c-'A') + 10, true
+	default:
+		return 0, false
+	}
+}
+
+// MARK: MIRRチャンクの値を解釈する関数
+func unifMirroring(value uint8) (Mirroring, error) {
+	switch value {
+	case 0:
+		return MirroringHorizontal, nil
+	case 1:
+		return MirroringVertical, nil
+	case 2:
+		return MirroringSingleScreenLower, nil
+	case 3:
+		return MirroringSingleScreenUpper, nil
+	case 4:
+		return MirroringFourScreen, nil
+	case 5:
+		return MirroringHorizontal, nil // マッパーが制御するため初期値は任意
+	default:
+		return MirroringHorizontal, fmt.Errorf("%w: %d", ErrUNIFInvalidMirror, value)
+	}
+}
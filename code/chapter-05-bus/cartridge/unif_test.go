@@ -0,0 +1,136 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// MARK: テスト用のUNIFイメージ
+func testUNIFImage(board string, chunks map[string][]uint8) []uint8 {
+	data := make([]uint8, UNIF_HEADER_SIZE)
+	copy(data, "UNIF")
+	appendChunk := func(id string, body []uint8) {
+		data = append(data, id...)
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(body)))
+		data = append(data, body...)
+	}
+	appendChunk("MAPR", append([]uint8(board), 0))
+	for _, id := range []string{"PRG0", "PRG1", "CHR0", "MIRR", "BATR", "TVCI"} {
+		if body, ok := chunks[id]; ok {
+			appendChunk(id, body)
+		}
+	}
+	return data
+}
+
+// MARK: バンクの単位に満たないPRG/CHRチャンクを拒否するかのテスト
+func TestParseUNIFRejectsUnalignedROMSize(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks map[string][]uint8
+		ok     bool
+	}{
+		{"PRG 100 bytes", map[string][]uint8{"PRG0": make([]uint8, 100)}, false},
+		{"PRG 8kB + 100 bytes", map[string][]uint8{"PRG0": make([]uint8, PRG_BANK_SIZE), "PRG1": make([]uint8, 100)}, false},
+		{"CHR 100 bytes", map[string][]uint8{"PRG0": make([]uint8, 32*1024), "CHR0": make([]uint8, 100)}, false},
+		{"PRG 32kB + CHR 8kB", map[string][]uint8{"PRG0": make([]uint8, 32*1024), "CHR0": make([]uint8, 8*1024)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := parseUNIF(testUNIFImage("NES-TLROM", tt.chunks))
+			if !tt.ok {
+				if !errors.Is(err, ErrROMSize) {
+					t.Fatalf("error = %v, want %v", err, ErrROMSize)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			c.ReadByteFrom(0xFFFC) // ベクタの読み取りでパニックしない
+		})
+	}
+}
+
+// MARK: UNIFファイルの解析のテスト
+func TestParseUNIF(t *testing.T) {
+	prg0, prg1 := make([]uint8, 64*1024), make([]uint8, 64*1024)
+	for i := range prg1 {
+		prg1[i] = 0xA5
+	}
+	data := testUNIFImage("NES-SLROM", map[string][]uint8{
+		"PRG0": prg0,
+		"PRG1": prg1,
+		"CHR0": make([]uint8, 128*1024),
+		"MIRR": {1},
+		"BATR": {1},
+		"TVCI": {1},
+	})
+	c, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := c.Header
+	if h.Format != FormatUNIF || h.Mapper != 1 {
+		t.Fatalf("format %v mapper %d, want UNIF mapper 1", h.Format, h.Mapper)
+	}
+	if h.PRGROMSize != 128*1024 || h.CHRROMSize != 128*1024 {
+		t.Fatalf("PRG %d / CHR %d bytes, want 128kB each", h.PRGROMSize, h.CHRROMSize)
+	}
+	if h.Mirroring != MirroringVertical || !h.Battery || h.Timing != TimingPAL {
+		t.Fatalf("mirroring %v battery %v timing %v, want vertical, battery, PAL", h.Mirroring, h.Battery, h.Timing)
+	}
+	if h.PRGNVRAMSize != UNIF_DEFAULT_PRG_RAM_SIZE || h.PRGRAMSize != 0 {
+		t.Fatalf("PRG-RAM %d + NVRAM %d, want 8kB NVRAM", h.PRGRAMSize, h.PRGNVRAMSize)
+	}
+	// PRGチャンクは番号順に連結される
+	if c.PRGROM[len(prg0)-1] != 0x00 || c.PRGROM[len(prg0)] != 0xA5 {
+		t.Fatal("PRG chunks were not joined in order")
+	}
+}
+
+// MARK: ボードごとのPRG-RAMのサイズのテスト
+func TestParseUNIFPRGRAMSize(t *testing.T) {
+	tests := []struct {
+		board            string
+		battery          bool
+		prgRAM, prgNVRAM int
+	}{
+		{"NES-SXROM", true, 0, 32 * 1024},
+		{"NES-SOROM", true, 8 * 1024, 8 * 1024},
+		{"NES-SOROM", false, 16 * 1024, 0},
+		{"NES-EWROM", false, 32 * 1024, 0},
+		{"NES-ETROM", true, 8 * 1024, 8 * 1024},
+		{"HVC-TLROM", false, 8 * 1024, 0},
+	}
+	for _, tt := range tests {
+		chunks := map[string][]uint8{"PRG0": make([]uint8, 128*1024), "CHR0": make([]uint8, 8*1024)}
+		if tt.battery {
+			chunks["BATR"] = []uint8{1}
+		}
+		c, err := Parse(testUNIFImage(tt.board, chunks))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.board, err)
+		}
+		if c.Header.PRGRAMSize != tt.prgRAM || c.Header.PRGNVRAMSize != tt.prgNVRAM {
+			t.Errorf("%s (battery %v): PRG-RAM %d + NVRAM %d, want %d + %d",
+				tt.board, tt.battery, c.Header.PRGRAMSize, c.Header.PRGNVRAMSize, tt.prgRAM, tt.prgNVRAM)
+		}
+		if len(c.PRGRAM) != tt.prgRAM+tt.prgNVRAM {
+			t.Errorf("%s: allocated %d bytes of PRG-RAM, want %d", tt.board, len(c.PRGRAM), tt.prgRAM+tt.prgNVRAM)
+		}
+	}
+}
+
+// MARK: 対応表にないボードを拒否するかのテスト
+func TestParseUNIFUnsupportedBoard(t *testing.T) {
+	chunks := map[string][]uint8{"PRG0": make([]uint8, 32*1024)}
+	for _, board := range []string{"UNL-TLROM", "BMC-NROM", "NES-XYZROM"} {
+		if _, err := Parse(testUNIFImage(board, chunks)); !errors.Is(err, ErrUnsupportedBoard) {
+			t.Errorf("%s: error = %v, want %v", board, err, ErrUnsupportedBoard)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package cartridge
+
+// MARK: UxROM (マッパー2) の定義
+type uxrom struct {
+	baseMapper
+}
+
+// MARK: UxROMのコンストラクタ
+func newUxROM(c *Cartridge) *uxrom {
+	/*
+		$8000-$BFFF: 16kB 切り替え可能なバンク
+		$C000-$FFFF: 16kB 最後のバンクに固定
+		$8000-$FFFFへの書き込みでバンクを選択 (UNROM: 3bit, UOROM: 4bit)
+	*/
+	m := &uxrom{
+		baseMapper: newBaseMapper(c),
+	}
+	m.setPRGBank16k(0, 0)
+	m.setPRGBank16k(1, -1)
+	return m
+}
+
+// MARK: CPUからの書き込み
+func (m *uxrom) WritePRG(address uint16, value uint8) {
+	if address < CPU_PRG_ROM_START {
+		m.baseMapper.WritePRG(address, value)
+		return
+	}
+	value = m.busConflict(address, value)
+	m.setPRGBank16k(0, int(value))
+}
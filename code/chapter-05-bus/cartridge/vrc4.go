@@ -0,0 +1,247 @@
+package cartridge
+
+const (
+	VRC_SUBMAPPER_VRC4_LOW  = 1 // 21: VRC4a, 23: VRC4f, 25: VRC4b (下位のアドレス線を使用する)
+	VRC_SUBMAPPER_VRC4_HIGH = 2 // 21: VRC4c, 23: VRC4e, 25: VRC4d (上位のアドレス線を使用する)
+	VRC_SUBMAPPER_VRC2      = 3 // 23: VRC2b, 25: VRC2c
+
+	VRC2_MICROWIRE_START = 0x6000
+	VRC2_MICROWIRE_END   = 0x6FFF
+	VRC2_MICROWIRE_BITS  = 0x01 // ラッチはbit0のみを駆動する
+)
+
+// MARK: レジスタのA0 / A1に接続されているCPUのアドレス線
+type vrcPins struct {
+	a0 uint16
+	a1 uint16
+}
+
+// MARK: CPUのアドレスからレジスタの番号 (0-3) を求めるメソッド
+func (p vrcPins) register(address uint16) int {
+	var register int
+	if address&p.a0 != 0 {
+		register |= 0x01
+	}
+	if address&p.a1 != 0 {
+		register |= 0x02
+	}
+	return register
+}
+
+// MARK: マッパー番号とサブマッパーごとのアドレス線の配線
+func vrcPinsFor(mapper uint16, submapper uint8) vrcPins {
+	// サブマッパーが不明な場合は両方の配線の論理和で判定する
+	switch mapper {
+	case 21:
+		switch submapper {
+		case VRC_SUBMAPPER_VRC4_LOW:
+			return vrcPins{0x02, 0x04} // VRC4a
+		case VRC_SUBMAPPER_VRC4_HIGH:
+			return vrcPins{0x40, 0x80} // VRC4c
+		default:
+			return vrcPins{0x42, 0x84}
+		}
+	case 22:
+		return vrcPins{0x02, 0x01} // VRC2a
+	case 23:
+		switch submapper {
+		case VRC_SUBMAPPER_VRC4_LOW, VRC_SUBMAPPER_VRC2:
+			return vrcPins{0x01, 0x02} // VRC4f / VRC2b
+		case VRC_SUBMAPPER_VRC4_HIGH:
+			return vrcPins{0x04, 0x08} // VRC4e
+		default:
+			return vrcPins{0x05, 0x0A}
+		}
+	default: // 25
+		switch submapper {
+		case VRC_SUBMAPPER_VRC4_LOW, VRC_SUBMAPPER_VRC2:
+			return vrcPins{0x02, 0x01} // VRC4b / VRC2c
+		case VRC_SUBMAPPER_VRC4_HIGH:
+			return vrcPins{0x08, 0x04} // VRC4d
+		default:
+			return vrcPins{0x0A, 0x05}
+		}
+	}
+}
+
+// MARK: VRC2 / VRC4 (マッパー21, 22, 23, 25) の定義
+type vrc4 struct {
+	baseMapper
+
+	pins  vrcPins
+	vrc2  bool // PRGの入れ替えとIRQを持たない
+	vrc2a bool // CHRバンクの最下位bitが無視される (マッパー22)
+
+	prg       [2]uint8  // $8000 / $A000
+	prgSwap   bool      // $9002 bit1: $8000と$C000を入れ替える (bit0はPRG-RAMの有効化)
+	chr       [8]uint16 // $B000-$E003 (下位4bitと上位5bitを別々に書き込む)
+	microwire uint8     // VRC2の$6000-$6FFFのラッチ (bit0のみ)
+	hasLatch  bool      // PRG-RAMを持たないVRC2の基板
+
+	irq vrcIRQ
+}
+
+// MARK: VRC2 / VRC4のコンストラクタ
+func newVRC4(c *Cartridge) *vrc4 {
+	/*
+		$8000-$9FFF: 8kB 切り替え可能なバンク (PRG入れ替えモードでは$C000-$DFFF)
+		$A000-$BFFF: 8kB 切り替え可能なバンク
+		$C000-$DFFF: 最後から2番目のバンクに固定 (PRG入れ替えモードでは$8000-$9FFF)
+		$E000-$FFFF: 最後のバンクに固定
+		CHRは1kB単位で8つ
+	*/
+	h := c.Header
+	m := &vrc4{
+		baseMapper: newBaseMapper(c),
+		pins:       vrcPinsFor(h.Mapper, h.Submapper),
+		vrc2:       h.Mapper == 22 || h.Submapper == VRC_SUBMAPPER_VRC2 && h.Mapper != 21,
+		vrc2a:      h.Mapper == 22,
+	}
+
+	// PRG-RAMを持たないVRC2の基板では$6000-$6FFFがシリアルEEPROM用のラッチになる
+	// iNESではPRG-RAMのサイズが表現できないため、バッテリーを持たない基板はPRG-RAMを持たないとみなす
+	if m.vrc2 {
+		if h.Format == FormatNES20 {
+			m.hasLatch = h.PRGRAMSize+h.PRGNVRAMSize == 0
+		} else {
+			m.hasLatch = !h.Battery
+		}
+		m.prgRAMEnabled = !m.hasLatch
+	}
+	m.updateBanks()
+	return m
+}
+
+// MARK: CPUからの読み取り
+func (m *vrc4) ReadPRG(address uint16) (uint8, bool) {
+	return m.PeekPRG(address)
+}
+
+// MARK: CPUからの読み取り (副作用なし)
+func (m *vrc4) PeekPRG(address uint16) (uint8, bool) {
+	if m.hasMicrowire(address) {
+		return m.microwire, true
+	}
+	return m.baseMapper.PeekPRG(address)
+}
+
+// MARK: 読み取りで駆動されるbitのマスク
+func (m *vrc4) DrivenBits(address uint16) uint8 {
+	// ラッチはbit0のみが駆動され、上位bitはオープンバスになる
+	if m.hasMicrowire(address) {
+		return VRC2_MICROWIRE_BITS
+	}
+	return DRIVEN_ALL_BITS
+}
+
+// MARK: ラッチのアドレスかを判定するメソッド
+func (m *vrc4) hasMicrowire(address uint16) bool {
+	return m.hasLatch && VRC2_MICROWIRE_START <= address && address <= VRC2_MICROWIRE_END
+}
+
+// MARK: CPUからの書き込み
+func (m *vrc4) WritePRG(address uint16, value uint8) {
+	if address < CPU_PRG_ROM_START {
+		if m.hasMicrowire(address) {
+			m.microwire = value & 0x01
+			return
+		}
+		m.baseMapper.WritePRG(address, value)
+		return
+	}
+
+	register := m.pins.register(address)
+	switch base := address & 0xF000; base {
+	case 0x8000:
+		m.prg[0] = value & 0x1F
+	case 0x9000:
+		m.writeControl(register, value)
+	case 0xA000:
+		m.prg[1] = value & 0x1F
+	case 0xB000, 0xC000, 0xD000, 0xE000:
+		// 各アドレスの2つのレジスタで1つのバンクの下位4bitと上位bitを書き込む
+		bank := int(base-0xB000)>>12*2 + register>>1
+		if register&0x01 == 0 {
+			m.chr[bank] = m.chr[bank]&^0x000F | uint16(value&0x0F)
+		} else {
+			m.chr[bank] = m.chr[bank]&0x000F | uint16(value&0x1F)<<4
+		}
+	case 0xF000:
+		if m.vrc2 {
+			return
+		}
+		switch register {
+		case 0:
+			m.irq.writeLatchLow(value)
+		case 1:
+			m.irq.writeLatchHigh(value)
+		case 2:
+			m.irq.writeControl(value)
+		case 3:
+			m.irq.acknowledge()
+		}
+		return
+	}
+	m.updateBanks()
+}
+
+// MARK: $9000-$9003への書き込み
+func (m *vrc4) writeControl(register int, value uint8) {
+	if m.vrc2 {
+		// VRC2は$9000-$9003すべてがミラーリング (1bit)
+		if value&0x01 == 0 {
+			m.mirroring = MirroringVertical
+		} else {
+			m.mirroring = MirroringHorizontal
+		}
+		return
+	}
+
+	switch register {
+	case 0:
+		switch value & 0x03 {
+		case 0:
+			m.mirroring = MirroringVertical
+		case 1:
+			m.mirroring = MirroringHorizontal
+		case 2:
+			m.mirroring = MirroringSingleScreenLower
+		case 3:
+			m.mirroring = MirroringSingleScreenUpper
+		}
+	case 2:
+		// 電源投入時の値は不定のため、書き込まれるまではPRG-RAMを有効とみなす
+		m.prgRAMEnabled = value&0x01 != 0
+		m.prgSwap = value&0x02 != 0
+	}
+}
+
+// MARK: バンクの更新
+func (m *vrc4) updateBanks() {
+	if m.prgSwap {
+		m.setPRGBank8k(0, -2)
+		m.setPRGBank8k(2, int(m.prg[0]))
+	} else {
+		m.setPRGBank8k(0, int(m.prg[0]))
+		m.setPRGBank8k(2, -2)
+	}
+	m.setPRGBank8k(1, int(m.prg[1]))
+	m.setPRGBank8k(3, -1)
+
+	for slot, bank := range m.chr {
+		if m.vrc2a {
+			bank >>= 1
+		}
+		m.setCHRBank1k(slot, int(bank))
+	}
+}
+
+// MARK: IRQの取得
+func (m *vrc4) IRQ() bool {
+	return m.irq.pending
+}
+
+// MARK: CPUサイクルの通知
+func (m *vrc4) Tick() {
+	m.irq.tick()
+}
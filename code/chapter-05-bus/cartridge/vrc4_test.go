@@ -0,0 +1,194 @@
+package cartridge
+
+import "testing"
+
+// MARK: マッパー番号とサブマッパーごとのA0 / A1の配線のテスト
+func TestVRC4Wirings(t *testing.T) {
+	tests := []struct {
+		name      string
+		mapper    uint16
+		submapper uint8
+		a0        uint16 // レジスタのA0に接続されたCPUのアドレス
+		a1        uint16 // レジスタのA1に接続されたCPUのアドレス
+	}{
+		{"VRC4a", 21, VRC_SUBMAPPER_VRC4_LOW, 0x02, 0x04},
+		{"VRC4c", 21, VRC_SUBMAPPER_VRC4_HIGH, 0x40, 0x80},
+		{"mapper 21 unknown (VRC4a)", 21, 0, 0x02, 0x04},
+		{"mapper 21 unknown (VRC4c)", 21, 0, 0x40, 0x80},
+		{"VRC2a", 22, 0, 0x02, 0x01},
+		{"VRC4f", 23, VRC_SUBMAPPER_VRC4_LOW, 0x01, 0x02},
+		{"VRC4e", 23, VRC_SUBMAPPER_VRC4_HIGH, 0x04, 0x08},
+		{"VRC2b", 23, VRC_SUBMAPPER_VRC2, 0x01, 0x02},
+		{"mapper 23 unknown (VRC4f)", 23, 0, 0x01, 0x02},
+		{"mapper 23 unknown (VRC4e)", 23, 0, 0x04, 0x08},
+		{"VRC4b", 25, VRC_SUBMAPPER_VRC4_LOW, 0x02, 0x01},
+		{"VRC4d", 25, VRC_SUBMAPPER_VRC4_HIGH, 0x08, 0x04},
+		{"VRC2c", 25, VRC_SUBMAPPER_VRC2, 0x02, 0x01},
+		{"mapper 25 unknown (VRC4b)", 25, 0, 0x02, 0x01},
+		{"mapper 25 unknown (VRC4d)", 25, 0, 0x08, 0x04},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCartridge(t, testBoard{mapper: tt.mapper, submapper: tt.submapper, prgROM: 256 * 1024, chrROM: 256 * 1024})
+
+			// $B000: 0の下位, A0: 0の上位, A1: 1の下位, A0|A1: 1の上位
+			c.WriteByteAt(0xB000, 0x02)
+			c.WriteByteAt(0xB000|tt.a0, 0x01)
+			c.WriteByteAt(0xB000|tt.a1, 0x06)
+			c.WriteByteAt(0xB000|tt.a0|tt.a1, 0x02)
+			want := [2]int{0x12, 0x26}
+			if tt.mapper == 22 {
+				want = [2]int{0x09, 0x13} // VRC2aはCHRバンクの最下位bitを無視する
+			}
+			for slot, want := range want {
+				if got := chrBankAt(c, uint16(slot)*CHR_BANK_SIZE); got != want {
+					t.Errorf("slot %d: bank $%02X, want $%02X", slot, got, want)
+				}
+			}
+		})
+	}
+}
+
+// MARK: VRC4のPRGの入れ替えモードのテスト
+func TestVRC4PRGSwap(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 23, submapper: VRC_SUBMAPPER_VRC4_LOW, prgROM: 256 * 1024, chrROM: 128 * 1024})
+	c.WriteByteAt(0x8000, 0x05)
+	c.WriteByteAt(0xA000, 0x06)
+	for address, want := range map[uint16]int{0x8000: 5, 0xA000: 6, 0xC000: 30, 0xE000: 31} {
+		if got := prgBankAt(t, c, address); got != want {
+			t.Errorf("$%04X: bank %d, want %d", address, got, want)
+		}
+	}
+
+	c.WriteByteAt(0x9002, 0x03) // bit1: $8000と$C000を入れ替える
+	for address, want := range map[uint16]int{0x8000: 30, 0xA000: 6, 0xC000: 5, 0xE000: 31} {
+		if got := prgBankAt(t, c, address); got != want {
+			t.Errorf("swapped $%04X: bank %d, want %d", address, got, want)
+		}
+	}
+}
+
+// MARK: $9002 bit0によるPRG-RAMの有効化のテスト
+func TestVRC4PRGRAMEnable(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 23, submapper: VRC_SUBMAPPER_VRC4_LOW, prgROM: 256 * 1024, chrROM: 128 * 1024, prgRAM: 8 * 1024})
+
+	// 書き込まれるまでは有効
+	c.WriteByteAt(0x6000, 0x42)
+	if got, driven := c.PeekByteFrom(0x6000); got != 0x42 || driven != DRIVEN_ALL_BITS {
+		t.Fatalf("PRG-RAM before $9002 = $%02X (driven $%02X), want $42", got, driven)
+	}
+
+	// bit0が0の場合は読み書きともに無効
+	c.WriteByteAt(0x9002, 0x00)
+	c.WriteByteAt(0x6000, 0x99)
+	if _, driven := c.PeekByteFrom(0x6000); driven != DRIVEN_NO_BITS {
+		t.Fatal("disabled PRG-RAM is still driven")
+	}
+
+	c.WriteByteAt(0x9002, 0x01)
+	if got, _ := c.PeekByteFrom(0x6000); got != 0x42 {
+		t.Fatalf("PRG-RAM after re-enabling = $%02X, want $42", got)
+	}
+}
+
+// MARK: テスト用のIRQを設定したVRC4 (VRC4f: $F000-$F003)
+func testVRC4IRQ(t *testing.T, latch uint8, control uint8) *Cartridge {
+	t.Helper()
+	c := newTestCartridge(t, testBoard{mapper: 23, submapper: VRC_SUBMAPPER_VRC4_LOW, prgROM: 256 * 1024, chrROM: 128 * 1024})
+	c.WriteByteAt(0xF000, latch&0x0F)
+	c.WriteByteAt(0xF001, latch>>4)
+	c.WriteByteAt(0xF002, control)
+	return c
+}
+
+// MARK: サイクルモードとスキャンラインモード (プリスケーラ) のテスト
+func TestVRC4IRQModes(t *testing.T) {
+	// サイクルモード: CPUサイクルごとにカウンタを進め、$FFから桁あふれするとIRQを発生させる
+	c := testVRC4IRQ(t, 0xFD, 0x06)
+	c.Tick(2)
+	if c.IRQ() {
+		t.Fatal("cycle mode: IRQ before the counter overflowed")
+	}
+	c.Tick(1)
+	if !c.IRQ() {
+		t.Fatal("cycle mode: no IRQ after 3 cycles from $FD")
+	}
+
+	// スキャンラインモード: 341/3 CPUサイクルごとに1回進める
+	c = testVRC4IRQ(t, 0xFF, 0x02)
+	c.Tick(113)
+	if c.IRQ() {
+		t.Fatal("scanline mode: IRQ after 113 cycles")
+	}
+	c.Tick(1)
+	if !c.IRQ() {
+		t.Fatal("scanline mode: no IRQ after 114 cycles")
+	}
+
+	// 3スキャンラインでちょうど341サイクルになる
+	c = testVRC4IRQ(t, 0xFD, 0x02)
+	c.Tick(340)
+	if c.IRQ() {
+		t.Fatal("scanline mode: IRQ before 3 scanlines")
+	}
+	c.Tick(1)
+	if !c.IRQ() {
+		t.Fatal("scanline mode: no IRQ after 341 cycles")
+	}
+}
+
+// MARK: 確認応答と確認応答後の有効化のテスト
+func TestVRC4IRQAcknowledge(t *testing.T) {
+	// bit0が1の場合は確認応答の後もカウンタを進める (ラッチから再ロード済み)
+	c := testVRC4IRQ(t, 0xFE, 0x07)
+	c.Tick(2)
+	if !c.IRQ() {
+		t.Fatal("no IRQ")
+	}
+	c.WriteByteAt(0xF003, 0x00)
+	if c.IRQ() {
+		t.Fatal("$F003 did not acknowledge the IRQ")
+	}
+	c.Tick(2)
+	if !c.IRQ() {
+		t.Fatal("enable-after-acknowledge: counter stopped after acknowledge")
+	}
+
+	// bit0が0の場合は確認応答でカウンタを停止する
+	c = testVRC4IRQ(t, 0xFE, 0x06)
+	c.Tick(2)
+	c.WriteByteAt(0xF003, 0x00)
+	c.Tick(0x200)
+	if c.IRQ() {
+		t.Fatal("counter kept running after acknowledge without enable-after-acknowledge")
+	}
+
+	// 制御レジスタへの書き込みも保留中のIRQを解除する
+	c = testVRC4IRQ(t, 0xFF, 0x06)
+	c.Tick(1)
+	c.WriteByteAt(0xF002, 0x00)
+	if c.IRQ() {
+		t.Fatal("writing the control register did not acknowledge the IRQ")
+	}
+}
+
+// MARK: VRC2がIRQとPRGの入れ替えを持たないかのテスト
+func TestVRC2HasNoIRQ(t *testing.T) {
+	c := newTestCartridge(t, testBoard{mapper: 23, submapper: VRC_SUBMAPPER_VRC2, prgROM: 256 * 1024, chrROM: 128 * 1024, prgRAM: 8 * 1024})
+	c.WriteByteAt(0xF000, 0x0F)
+	c.WriteByteAt(0xF001, 0x0F)
+	c.WriteByteAt(0xF002, 0x06)
+	c.Tick(4)
+	if c.IRQ() {
+		t.Fatal("VRC2 raised an IRQ")
+	}
+
+	c.WriteByteAt(0x9002, 0x02)
+	if got := prgBankAt(t, c, 0xC000); got != 30 {
+		t.Fatalf("$C000: bank %d, want 30", got)
+	}
+	if _, driven := c.PeekByteFrom(0x6000); driven != DRIVEN_ALL_BITS {
+		t.Fatal("VRC2 PRG-RAM disabled by $9002")
+	}
+}
@@ -0,0 +1,153 @@
+package cartridge
+
+const (
+	VRC6_CHR_A10_FROM_REGISTER = 0x20 // $B003 bit5: 2kBの領域でもCHRのA10をレジスタのbit0で決める
+	VRC6_NAMETABLE_FROM_CHR    = 0x10 // $B003 bit4: ネームテーブルをCHR-ROMから読み取る (未対応)
+)
+
+// MARK: VRC6 (マッパー24, 26) の定義
+type vrc6 struct {
+	baseMapper
+
+	swapPins bool // マッパー26はA0とA1が入れ替わって配線されている
+
+	prg     [2]uint8 // $8000 (16kB) / $C000 (8kB)
+	chr     [8]uint8 // $D000-$E003
+	ppuMode uint8    // $B003
+
+	irq   vrcIRQ
+	audio vrc6Audio
+}
+
+// MARK: VRC6のコンストラクタ
+func newVRC6(c *Cartridge) *vrc6 {
+	/*
+		$8000-$BFFF: 16kB 切り替え可能なバンク
+		$C000-$DFFF: 8kB 切り替え可能なバンク
+		$E000-$FFFF: 最後のバンクに固定
+		$6000-$7FFF: 8kB PRG-RAM ($B003 bit7で有効化)
+	*/
+	m := &vrc6{
+		baseMapper: newBaseMapper(c),
+		swapPins:   c.Header.Mapper == 26,
+	}
+	m.prgRAMEnabled = false
+	m.updateBanks()
+	return m
+}
+
+// MARK: CPUからの書き込み
+func (m *vrc6) WritePRG(address uint16, value uint8) {
+	if address < CPU_PRG_ROM_START {
+		m.baseMapper.WritePRG(address, value)
+		return
+	}
+
+	register := address & 0x03
+	if m.swapPins {
+		register = register>>1 | register&0x01<<1
+	}
+
+	switch base := address & 0xF000; base {
+	case 0x8000:
+		m.prg[0] = value & 0x0F
+	case 0x9000, 0xA000:
+		m.audio.write(base|register, value)
+		return
+	case 0xB000:
+		if register != 3 {
+			m.audio.write(base|register, value)
+			return
+		}
+		// bit4 (ネームテーブルにCHR-ROMを使う) を使用する市販のソフトはないため、
+		// 常に本体のCIRAMを使い、bit4は無視する
+		m.ppuMode = value &^ VRC6_NAMETABLE_FROM_CHR
+		m.prgRAMEnabled = value&0x80 != 0
+		switch value >> 2 & 0x03 {
+		case 0:
+			m.mirroring = MirroringVertical
+		case 1:
+			m.mirroring = MirroringHorizontal
+		case 2:
+			m.mirroring = MirroringSingleScreenLower
+		case 3:
+			m.mirroring = MirroringSingleScreenUpper
+		}
+	case 0xC000:
+		m.prg[1] = value & 0x1F
+	case 0xD000, 0xE000:
+		m.chr[int(base-0xD000)>>12*4+int(register)] = value
+	case 0xF000:
+		switch register {
+		case 0:
+			m.irq.writeLatch(value)
+		case 1:
+			m.irq.writeControl(value)
+		case 2:
+			m.irq.acknowledge()
+		}
+		return
+	}
+	m.updateBanks()
+}
+
+// MARK: バンクの更新
+func (m *vrc6) updateBanks() {
+	m.setPRGBank16k(0, int(m.prg[0]))
+	m.setPRGBank8k(2, int(m.prg[1]))
+	m.setPRGBank8k(3, -1)
+
+	/*
+		$B003 bit0-1 (CHRモード)
+		0: 1kB x8 (R0-R7)
+		1: 2kB x4 (R0-R3)
+		2, 3: $0000-$0FFFは1kB x4 (R0-R3), $1000-$1FFFは2kB x2 (R4, R5)
+		レジスタの値は常に1kB単位のバンク番号
+	*/
+	r := m.chr
+	switch m.ppuMode & 0x03 {
+	case 0:
+		for slot, bank := range r {
+			m.setCHRBank1k(slot, int(bank))
+		}
+	case 1:
+		for slot := range 4 {
+			m.setCHRBank2kFrom1k(slot, r[slot])
+		}
+	default:
+		for slot := range 4 {
+			m.setCHRBank1k(slot, int(r[slot]))
+		}
+		m.setCHRBank2kFrom1k(2, r[4])
+		m.setCHRBank2kFrom1k(3, r[5])
+	}
+}
+
+// MARK: 1kB単位のバンク番号を2kBの領域に割り当てる
+func (m *vrc6) setCHRBank2kFrom1k(slot int, bank uint8) {
+	// 通常はバンク番号の最下位bitがPPUのA10に置き換えられ、連続した2つの1kBバンクになる
+	// $B003 bit5 が立っている場合はレジスタのbit0がそのままA10になる (同じ1kBバンクが2回並ぶ)
+	if m.ppuMode&VRC6_CHR_A10_FROM_REGISTER != 0 {
+		m.setCHRBank1k(slot*2, int(bank))
+		m.setCHRBank1k(slot*2+1, int(bank))
+		return
+	}
+	m.setCHRBank1k(slot*2, int(bank&0xFE))
+	m.setCHRBank1k(slot*2+1, int(bank|0x01))
+}
+
+// MARK: IRQの取得
+func (m *vrc6) IRQ() bool {
+	return m.irq.pending
+}
+
+// MARK: CPUサイクルの通知
+func (m *vrc6) Tick() {
+	m.irq.tick()
+	m.audio.tick()
+}
+
+// MARK: 拡張音源の出力
+func (m *vrc6) AudioOutput() float32 {
+	return m.audio.output()
+}
@@ -0,0 +1,34 @@
+package cartridge
+
+import "testing"
+
+// MARK: VRC6のCHRモードごとのバンクの割り当てのテスト
+func TestVRC6CHRModes(t *testing.T) {
+	tests := []struct {
+		name string
+		mode uint8 // $B003
+		want [8]int
+	}{
+		{"mode 0", 0x00, [8]int{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88}},
+		{"mode 1", 0x01, [8]int{0x10, 0x11, 0x22, 0x23, 0x32, 0x33, 0x44, 0x45}},
+		{"mode 2", 0x02, [8]int{0x11, 0x22, 0x33, 0x44, 0x54, 0x55, 0x66, 0x67}},
+		{"mode 3", 0x03, [8]int{0x11, 0x22, 0x33, 0x44, 0x54, 0x55, 0x66, 0x67}},
+		{"mode 1 A10 from register", 0x21, [8]int{0x11, 0x11, 0x22, 0x22, 0x33, 0x33, 0x44, 0x44}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestCartridge(t, testBoard{mapper: 24, prgROM: 256 * 1024, chrROM: 256 * 1024})
+			for i, bank := range []uint8{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88} {
+				c.WriteByteAt(0xD000+uint16(i/4)*0x1000+uint16(i%4), bank)
+			}
+			c.WriteByteAt(0xB003, tt.mode)
+
+			for slot, want := range tt.want {
+				if got := chrBankAt(c, uint16(slot)*CHR_BANK_SIZE); got != want {
+					t.Errorf("slot %d: bank $%02X, want $%02X", slot, got, want)
+				}
+			}
+		})
+	}
+}
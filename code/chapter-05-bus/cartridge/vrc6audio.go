@@ -0,0 +1,139 @@
+package cartridge
+
+import "fc-emu/audio"
+
+const (
+	VRC6_PULSE_STEPS = 16 // デューティのシーケンサは16ステップ
+	VRC6_SAW_STEPS   = 14 // 7回の加算の後に累算器がリセットされる
+)
+
+// MARK: VRC6の矩形波の定義
+type vrc6Pulse struct {
+	volume  uint8  // bit0-3
+	duty    uint8  // bit4-6 (0-7 → 1/16 - 8/16)
+	digital bool   // bit7: デューティを無視して常に音量を出力する
+	enabled bool   // $9002 bit7
+	period  uint16 // 12bit
+	timer   uint16
+	step    uint8
+}
+
+// MARK: VRC6ののこぎり波の定義
+type vrc6Saw struct {
+	rate        uint8 // $B000 bit0-5 (累算器への加算値)
+	enabled     bool
+	period      uint16
+	timer       uint16
+	step        uint8
+	accumulator uint8
+}
+
+// MARK: VRC6の拡張音源 (矩形波2ch + のこぎり波) の定義
+type vrc6Audio struct {
+	pulses [2]vrc6Pulse // $9000-$9002, $A000-$A002
+	saw    vrc6Saw      // $B000-$B002
+
+	halt  bool  // $9003 bit0: すべてのチャンネルを停止する
+	shift uint8 // $9003 bit1 / bit2: 周期を16倍 / 256倍速くする (0 / 4 / 8)
+}
+
+// MARK: レジスタへの書き込み ($9000-$B002)
+func (a *vrc6Audio) write(address uint16, value uint8) {
+	if address == 0x9003 {
+		a.halt = value&0x01 != 0
+		switch {
+		case value&0x04 != 0:
+			a.shift = 8
+		case value&0x02 != 0:
+			a.shift = 4
+		default:
+			a.shift = 0
+		}
+		return
+	}
+
+	if address >= 0xB000 {
+		s := &a.saw
+		switch address & 0x03 {
+		case 0:
+			s.rate = value & 0x3F
+		case 1:
+			s.period = s.period&0x0F00 | uint16(value)
+		case 2:
+			s.period = s.period&0x00FF | uint16(value&0x0F)<<8
+			s.enabled = value&0x80 != 0
+			if !s.enabled {
+				s.step = 0
+				s.accumulator = 0
+			}
+		}
+		return
+	}
+
+	p := &a.pulses[(address-0x9000)>>12]
+	switch address & 0x03 {
+	case 0:
+		p.volume = value & 0x0F
+		p.duty = value >> 4 & 0x07
+		p.digital = value&0x80 != 0
+	case 1:
+		p.period = p.period&0x0F00 | uint16(value)
+	case 2:
+		p.period = p.period&0x00FF | uint16(value&0x0F)<<8
+		p.enabled = value&0x80 != 0
+		if !p.enabled {
+			p.step = 0
+		}
+	}
+}
+
+// MARK: CPUサイクルの通知 (各チャンネルのタイマはCPUクロックで進む)
+func (a *vrc6Audio) tick() {
+	if a.halt {
+		return
+	}
+	for i := range a.pulses {
+		p := &a.pulses[i]
+		if p.enabled && a.clockTimer(&p.timer, p.period) {
+			p.step = (p.step + 1) % VRC6_PULSE_STEPS
+		}
+	}
+
+	s := &a.saw
+	if s.enabled && a.clockTimer(&s.timer, s.period) {
+		// 2ステップごとに加算し、14ステップ目で0に戻る
+		s.step++
+		if s.step == VRC6_SAW_STEPS {
+			s.step = 0
+			s.accumulator = 0
+		} else if s.step%2 == 0 {
+			s.accumulator += s.rate
+		}
+	}
+}
+
+// MARK: タイマのクロック (0になったら周期を再ロードしてtrueを返す)
+func (a *vrc6Audio) clockTimer(timer *uint16, period uint16) bool {
+	if *timer > 0 {
+		*timer--
+		return false
+	}
+	*timer = period >> a.shift
+	return true
+}
+
+// MARK: 拡張音源の出力
+func (a *vrc6Audio) output() float32 {
+	var sum uint8
+	for _, p := range a.pulses {
+		// シーケンサの位置がデューティ以下の間だけ音量を出力する
+		if p.enabled && (p.digital || p.step <= p.duty) {
+			sum += p.volume
+		}
+	}
+	if a.saw.enabled {
+		sum += a.saw.accumulator >> 3 // 上位5bitが出力される
+	}
+	// 矩形波の1ステップはAPUの矩形波の1ステップとほぼ同じ音量になる
+	return float32(sum) * audio.PULSE_LINEAR_GAIN
+}
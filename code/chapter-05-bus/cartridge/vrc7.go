@@ -0,0 +1,136 @@
+package cartridge
+
+const (
+	VRC7_SUBMAPPER_VRC7B = 1 // A3で2番目のレジスタを選択する (Tiny Toon Adventures 2)
+	VRC7_SUBMAPPER_VRC7A = 2 // A4で2番目のレジスタを選択する (Lagrange Point)
+)
+
+// MARK: VRC7 (マッパー85) の定義
+type vrc7 struct {
+	baseMapper
+
+	pin uint16 // 2番目のレジスタを選択するアドレス線 (サブマッパーが不明な場合はA3とA4の論理和)
+
+	prg [3]uint8 // $8000, $A000, $C000
+	chr [8]uint8 // $A000-$D010
+
+	irq   vrcIRQ
+	audio opll
+}
+
+// MARK: VRC7のコンストラクタ
+func newVRC7(c *Cartridge) *vrc7 {
+	/*
+		$8000-$DFFF: 8kB 切り替え可能なバンク x3
+		$E000-$FFFF: 最後のバンクに固定
+		$6000-$7FFF: 8kB PRG-RAM ($E000 bit7で有効化)
+		CHRは1kB単位で8つ
+	*/
+	var pin uint16
+	switch c.Header.Submapper {
+	case VRC7_SUBMAPPER_VRC7B:
+		pin = 0x08
+	case VRC7_SUBMAPPER_VRC7A:
+		pin = 0x10
+	default:
+		pin = 0x18
+	}
+
+	m := &vrc7{
+		baseMapper: newBaseMapper(c),
+		pin:        pin,
+		audio:      newOPLL(),
+	}
+	m.prgRAMEnabled = false
+	m.updateBanks()
+	return m
+}
+
+// MARK: CPUからの書き込み
+func (m *vrc7) WritePRG(address uint16, value uint8) {
+	if address < CPU_PRG_ROM_START {
+		m.baseMapper.WritePRG(address, value)
+		return
+	}
+
+	second := address&m.pin != 0
+	switch base := address & 0xF000; base {
+	case 0x8000:
+		if second {
+			m.prg[1] = value & 0x3F
+		} else {
+			m.prg[0] = value & 0x3F
+		}
+	case 0x9000:
+		// 音源のレジスタは$9010 (アドレス) と$9030 (データ) で配線が固定されている
+		switch address & 0x0030 {
+		case 0x0010:
+			m.audio.writeAddress(value)
+		case 0x0030:
+			m.audio.writeData(value)
+		default:
+			m.prg[2] = value & 0x3F
+		}
+	case 0xA000, 0xB000, 0xC000, 0xD000:
+		bank := int(base-0xA000) >> 12 * 2
+		if second {
+			bank++
+		}
+		m.chr[bank] = value
+	case 0xE000:
+		if second {
+			m.irq.writeLatch(value)
+			return
+		}
+		// bit0-1: ミラーリング, bit6: 音源のリセット, bit7: PRG-RAMの有効化
+		switch value & 0x03 {
+		case 0:
+			m.mirroring = MirroringVertical
+		case 1:
+			m.mirroring = MirroringHorizontal
+		case 2:
+			m.mirroring = MirroringSingleScreenLower
+		case 3:
+			m.mirroring = MirroringSingleScreenUpper
+		}
+		m.audio.setReset(value&0x40 != 0)
+		m.prgRAMEnabled = value&0x80 != 0
+		return
+	case 0xF000:
+		if second {
+			m.irq.acknowledge()
+		} else {
+			m.irq.writeControl(value)
+		}
+		return
+	}
+	m.updateBanks()
+}
+
+// MARK: バンクの更新
+func (m *vrc7) updateBanks() {
+	for slot, bank := range m.prg {
+		m.setPRGBank8k(slot, int(bank))
+	}
+	m.setPRGBank8k(3, -1)
+
+	for slot, bank := range m.chr {
+		m.setCHRBank1k(slot, int(bank))
+	}
+}
+
+// MARK: IRQの取得
+func (m *vrc7) IRQ() bool {
+	return m.irq.pending
+}
+
+// MARK: CPUサイクルの通知
+func (m *vrc7) Tick() {
+	m.irq.tick()
+	m.audio.tick()
+}
+
+// MARK: 拡張音源の出力
+func (m *vrc7) AudioOutput() float32 {
+	return m.audio.output()
+}
@@ -0,0 +1,379 @@
+package cartridge
+
+import (
+	"math"
+
+	"fc-emu/audio"
+)
+
+const (
+	OPLL_CHANNELS                = 6  // VRC7はYM2413の9チャンネルのうち6チャンネルのみを持つ
+	OPLL_CPU_CYCLES_PER_SAMPLE   = 36 // 3.58MHz / 72 = CPUクロック / 36 (約49.7kHz)
+	OPLL_SAMPLE_RATE             = float64(NTSC_CPU_CLOCK_HZ) / OPLL_CPU_CYCLES_PER_SAMPLE
+	OPLL_PHASE_BITS              = 19
+	OPLL_MAX_ATTENUATION         = 48.0  // dB (エンベロープの範囲)
+	OPLL_AM_DEPTH                = 4.875 // dB
+	OPLL_AM_RATE                 = 3.7   // Hz
+	OPLL_FM_DEPTH                = 0.004 // 周波数の変化率 (約 ±7セント)
+	OPLL_FM_RATE                 = 6.4   // Hz
+	OPLL_MODULATION_DEPTH        = 4.0   // モジュレータの最大出力で変化するキャリアの位相 (周期)
+	OPLL_DECAY_COEFFICIENT       = 6.15e-6
+	OPLL_ATTACK_COEFFICIENT      = 5.49e-6
+	OPLL_RELEASE_RATE_SUSTAIN    = 5 // チャンネルのサステインが有効な場合のキーオフ後のレート
+	OPLL_RELEASE_RATE_PERCUSSIVE = 7 // 減衰音のキーオフ後のレート
+
+	// 最大音量のキャリア1チャンネルとAPUの矩形波 (音量15) の音量比
+	// VRC7の出力はAPUの矩形波とほぼ同じ音量になる
+	VRC7_MIX_LEVEL = 1.0
+)
+
+// MARK: VRC7の内蔵音色 (1-15, 0はユーザー定義)
+var opllPatches = [16][8]uint8{
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+	{0x03, 0x21, 0x05, 0x06, 0xE8, 0x81, 0x42, 0x27},
+	{0x13, 0x41, 0x14, 0x0D, 0xD8, 0xF6, 0x23, 0x12},
+	{0x11, 0x11, 0x08, 0x08, 0xFA, 0xB2, 0x20, 0x12},
+	{0x31, 0x61, 0x0C, 0x07, 0xA8, 0x64, 0x61, 0x27},
+	{0x32, 0x21, 0x1E, 0x06, 0xE1, 0x76, 0x01, 0x28},
+	{0x02, 0x01, 0x06, 0x00, 0xA3, 0xE2, 0xF4, 0xF4},
+	{0x21, 0x61, 0x1D, 0x07, 0x82, 0x81, 0x11, 0x07},
+	{0x23, 0x21, 0x22, 0x17, 0xA2, 0x72, 0x01, 0x17},
+	{0x35, 0x11, 0x25, 0x00, 0x40, 0x73, 0x72, 0x01},
+	{0xB5, 0x01, 0x0F, 0x0F, 0xA8, 0xA5, 0x51, 0x02},
+	{0x17, 0xC1, 0x24, 0x07, 0xF8, 0xF8, 0x22, 0x12},
+	{0x71, 0x23, 0x11, 0x06, 0x65, 0x74, 0x18, 0x16},
+	{0x01, 0x02, 0xD3, 0x05, 0xC9, 0x95, 0x03, 0x02},
+	{0x61, 0x63, 0x0C, 0x00, 0x94, 0xC0, 0x33, 0xF6},
+	{0x21, 0x72, 0x0D, 0x00, 0xC1, 0xD5, 0x56, 0x06},
+}
+
+// MARK: 周波数の倍率 (x2)
+var opllMultipliers = [16]float64{1, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 20, 24, 24, 30, 30}
+
+// MARK: キースケールレベル (オクターブ7, 6dB/オクターブの場合の減衰量 dB)
+var opllKeyScaleLevels = [16]float64{
+	0, 18, 24, 27.75, 30, 32.25, 33.75, 35.25, 36, 37.5, 38.25, 39, 39.75, 40.5, 41.25, 42,
+}
+
+// MARK: KSLのビットごとの倍率 (0, 1.5, 3, 6 dB/オクターブ)
+var opllKeyScaleFactors = [4]float64{0, 0.25, 0.5, 1}
+
+// MARK: エンベロープの状態
+type opllEnvelopeState uint8
+
+const (
+	opllEnvelopeOff opllEnvelopeState = iota
+	opllEnvelopeAttack
+	opllEnvelopeDecay
+	opllEnvelopeSustain
+	opllEnvelopeRelease
+)
+
+// MARK: 音色の1オペレータ分のパラメータ
+type opllOperatorPatch struct {
+	am, fm     bool // トレモロ / ビブラート
+	sustained  bool // EG-TYP: 持続音 (falseの場合は減衰音)
+	ksr        bool // キースケールレート
+	multiplier uint8
+	ksl        uint8
+	halfWave   bool // 負の半波を0にする
+	ar, dr     uint8
+	sl, rr     uint8
+}
+
+// MARK: 音色の定義 (8バイトのレジスタ値から展開する)
+type opllPatch struct {
+	operators  [2]opllOperatorPatch // 0: モジュレータ, 1: キャリア
+	totalLevel uint8                // モジュレータの出力レベル (0.75dB単位)
+	feedback   uint8
+}
+
+func newOPLLPatch(data [8]uint8) opllPatch {
+	var p opllPatch
+	for i := range p.operators {
+		o := &p.operators[i]
+		o.am = data[i]&0x80 != 0
+		o.fm = data[i]&0x40 != 0
+		o.sustained = data[i]&0x20 != 0
+		o.ksr = data[i]&0x10 != 0
+		o.multiplier = data[i] & 0x0F
+		o.ksl = data[2+i] >> 6
+		o.ar = data[4+i] >> 4
+		o.dr = data[4+i] & 0x0F
+		o.sl = data[6+i] >> 4
+		o.rr = data[6+i] & 0x0F
+	}
+	p.operators[0].halfWave = data[3]&0x08 != 0
+	p.operators[1].halfWave = data[3]&0x10 != 0
+	p.totalLevel = data[2] & 0x3F
+	p.feedback = data[3] & 0x07
+	return p
+}
+
+// MARK: オペレータの定義
+type opllOperator struct {
+	phase    float64 // 周期単位 (0-1)
+	envelope float64 // 減衰量 (dB)
+	state    opllEnvelopeState
+	outputs  [2]float64 // 直近2サンプルの出力 (フィードバック用)
+}
+
+// MARK: チャンネルの定義
+type opllChannel struct {
+	fnum       uint16 // 9bit ($10-$15, $20-$25 bit0)
+	block      uint8  // $20-$25 bit1-3
+	key        bool   // $20-$25 bit4
+	sustain    bool   // $20-$25 bit5
+	instrument uint8  // $30-$35 bit4-7
+	volume     uint8  // $30-$35 bit0-3 (3dB単位)
+
+	operators [2]opllOperator
+}
+
+// MARK: VRC7のFM音源 (YM2413の派生) の定義
+type opll struct {
+	address  uint8
+	custom   [8]uint8 // $00-$07 ユーザー定義音色
+	patches  [16]opllPatch
+	channels [OPLL_CHANNELS]opllChannel
+
+	reset   bool // $E000 bit6
+	cycles  int
+	amPhase float64
+	fmPhase float64
+	sample  float32
+}
+
+// MARK: FM音源のコンストラクタ
+func newOPLL() opll {
+	var o opll
+	for i, data := range opllPatches {
+		o.patches[i] = newOPLLPatch(data)
+	}
+	return o
+}
+
+// MARK: アドレスレジスタへの書き込み ($9010)
+func (o *opll) writeAddress(value uint8) {
+	o.address = value
+}
+
+// MARK: データレジスタへの書き込み ($9030)
+func (o *opll) writeData(value uint8) {
+	if o.reset {
+		return
+	}
+
+	switch {
+	case o.address <= 0x07:
+		o.custom[o.address] = value
+		o.patches[0] = newOPLLPatch(o.custom)
+	case 0x10 <= o.address && o.address < 0x10+OPLL_CHANNELS:
+		c := &o.channels[o.address-0x10]
+		c.fnum = c.fnum&0x100 | uint16(value)
+	case 0x20 <= o.address && o.address < 0x20+OPLL_CHANNELS:
+		c := &o.channels[o.address-0x20]
+		c.fnum = c.fnum&0x0FF | uint16(value&0x01)<<8
+		c.block = value >> 1 & 0x07
+		c.sustain = value&0x20 != 0
+		key := value&0x10 != 0
+		if key && !c.key {
+			o.keyOn(c)
+		} else if !key && c.key {
+			o.keyOff(c)
+		}
+		c.key = key
+	case 0x30 <= o.address && o.address < 0x30+OPLL_CHANNELS:
+		c := &o.channels[o.address-0x30]
+		c.instrument = value >> 4
+		c.volume = value & 0x0F
+	}
+}
+
+// MARK: 音源のリセット ($E000 bit6)
+func (o *opll) setReset(reset bool) {
+	o.reset = reset
+	if reset {
+		o.channels = [OPLL_CHANNELS]opllChannel{}
+		o.custom = [8]uint8{}
+		o.patches[0] = newOPLLPatch(o.custom)
+		o.sample = 0
+	}
+}
+
+// MARK: キーオン (位相をリセットしてアタックを開始する)
+func (o *opll) keyOn(c *opllChannel) {
+	for i := range c.operators {
+		op := &c.operators[i]
+		op.phase = 0
+		op.outputs = [2]float64{}
+		op.state = opllEnvelopeAttack
+	}
+}
+
+// MARK: キーオフ (リリースを開始する)
+func (o *opll) keyOff(c *opllChannel) {
+	for i := range c.operators {
+		if c.operators[i].state != opllEnvelopeOff {
+			c.operators[i].state = opllEnvelopeRelease
+		}
+	}
+}
+
+// MARK: CPUサイクルの通知
+func (o *opll) tick() {
+	o.cycles++
+	if o.cycles < OPLL_CPU_CYCLES_PER_SAMPLE {
+		return
+	}
+	o.cycles = 0
+	if o.reset {
+		return
+	}
+
+	o.amPhase = math.Mod(o.amPhase+OPLL_AM_RATE/OPLL_SAMPLE_RATE, 1)
+	o.fmPhase = math.Mod(o.fmPhase+OPLL_FM_RATE/OPLL_SAMPLE_RATE, 1)
+	am := OPLL_AM_DEPTH * (1 + math.Sin(2*math.Pi*o.amPhase)) / 2
+	fm := 1 + OPLL_FM_DEPTH*math.Sin(2*math.Pi*o.fmPhase)
+
+	var sum float64
+	for i := range o.channels {
+		sum += o.clockChannel(&o.channels[i], am, fm)
+	}
+	o.sample = float32(sum) * VRC7_MIX_LEVEL * audio.PULSE_MAX_LEVEL
+}
+
+// MARK: 1チャンネル分のサンプルの生成
+func (o *opll) clockChannel(c *opllChannel, am float64, fm float64) float64 {
+	patch := &o.patches[c.instrument]
+
+	// キースケールの基準 (ブロックとF-Numberの最上位bit)
+	keyCode := c.block<<1 | uint8(c.fnum>>8)
+	ksl := max(opllKeyScaleLevels[c.fnum>>5]-6*float64(7-c.block), 0)
+
+	var modulation float64
+	var output float64
+	for i := range c.operators {
+		op := &c.operators[i]
+		p := &patch.operators[i]
+
+		o.clockEnvelope(c, op, p, keyCode)
+
+		// 位相の更新 (1周期 = 2^19)
+		increment := float64(uint32(c.fnum)<<c.block) * opllMultipliers[p.multiplier] / 2
+		if p.fm {
+			increment *= fm
+		}
+		op.phase = math.Mod(op.phase+increment/(1<<OPLL_PHASE_BITS), 1)
+
+		attenuation := op.envelope + ksl*opllKeyScaleFactors[p.ksl]
+		if p.am {
+			attenuation += am
+		}
+
+		if i == 0 {
+			// モジュレータはフィードバックを受け、出力をキャリアの位相に加える
+			attenuation += float64(patch.totalLevel) * 0.75
+			var feedback float64
+			if patch.feedback > 0 {
+				feedback = (op.outputs[0] + op.outputs[1]) / 2 * float64(uint(1)<<(patch.feedback-1)) / 32
+			}
+			value := o.operatorOutput(op, feedback, p.halfWave, attenuation)
+			op.outputs = [2]float64{value, op.outputs[0]}
+			modulation = value * OPLL_MODULATION_DEPTH
+		} else {
+			attenuation += float64(c.volume) * 3
+			output = o.operatorOutput(op, modulation, p.halfWave, attenuation)
+		}
+	}
+	return output
+}
+
+// MARK: オペレータの出力 (-1 - 1)
+func (o *opll) operatorOutput(op *opllOperator, modulation float64, halfWave bool, attenuation float64) float64 {
+	if op.state == opllEnvelopeOff || attenuation >= OPLL_MAX_ATTENUATION {
+		return 0
+	}
+	value := math.Sin(2 * math.Pi * (op.phase + modulation))
+	if halfWave && value < 0 {
+		value = 0
+	}
+	return value * math.Pow(10, -attenuation/20)
+}
+
+// MARK: エンベロープの更新
+func (o *opll) clockEnvelope(c *opllChannel, op *opllOperator, p *opllOperatorPatch, keyCode uint8) {
+	switch op.state {
+	case opllEnvelopeOff:
+		op.envelope = OPLL_MAX_ATTENUATION
+	case opllEnvelopeAttack:
+		if rate := opllRate(p.ar, p.ksr, keyCode); rate >= 60 {
+			op.envelope = 0
+		} else if rate > 0 {
+			// アタックは指数的に減衰量が小さくなる
+			op.envelope *= 1 - min(opllRateSteps(rate)*OPLL_ATTACK_COEFFICIENT, 1)
+		}
+		if op.envelope < 0.1 {
+			op.envelope = 0
+			op.state = opllEnvelopeDecay
+		}
+	case opllEnvelopeDecay:
+		sustainLevel := float64(p.sl) * 3
+		op.envelope += opllDecayStep(opllRate(p.dr, p.ksr, keyCode))
+		if op.envelope >= sustainLevel {
+			op.envelope = sustainLevel
+			op.state = opllEnvelopeSustain
+		}
+	case opllEnvelopeSustain:
+		// 持続音はキーオフまでサステインレベルを保ち、減衰音はRRで減衰を続ける
+		if !p.sustained {
+			op.envelope += opllDecayStep(opllRate(p.rr, p.ksr, keyCode))
+		}
+	case opllEnvelopeRelease:
+		rate := p.rr
+		switch {
+		case c.sustain:
+			rate = OPLL_RELEASE_RATE_SUSTAIN
+		case !p.sustained:
+			rate = OPLL_RELEASE_RATE_PERCUSSIVE
+		}
+		op.envelope += opllDecayStep(opllRate(rate, p.ksr, keyCode))
+	}
+
+	if op.envelope >= OPLL_MAX_ATTENUATION {
+		op.envelope = OPLL_MAX_ATTENUATION
+		if op.state != opllEnvelopeAttack {
+			op.state = opllEnvelopeOff
+		}
+	}
+}
+
+// MARK: キースケールを含めた実効レート (0-63)
+func opllRate(rate uint8, ksr bool, keyCode uint8) int {
+	if rate == 0 {
+		return 0
+	}
+	rks := keyCode >> 2
+	if ksr {
+		rks = keyCode
+	}
+	return min(int(rate)*4+int(rks), 63)
+}
+
+// MARK: 実効レートから1サンプルあたりの変化量の比率を求める関数
+func opllRateSteps(rate int) float64 {
+	return float64(4+rate%4) * float64(uint(1)<<(rate/4))
+}
+
+// MARK: ディケイ / リリースの1サンプルあたりの減衰量 (dB)
+func opllDecayStep(rate int) float64 {
+	if rate == 0 {
+		return 0
+	}
+	return opllRateSteps(rate) * OPLL_DECAY_COEFFICIENT
+}
+
+// MARK: 拡張音源の出力
+func (o *opll) output() float32 {
+	return o.sample
+}
@@ -0,0 +1,77 @@
+package cartridge
+
+const (
+	VRC_IRQ_PRESCALER_PERIOD = 341 // スキャンラインモードでは 341/3 CPUサイクルごとにカウンタを進める
+	VRC_IRQ_PRESCALER_STEP   = 3
+)
+
+// MARK: コナミのVRC (VRC4 / VRC6 / VRC7) 共通のIRQカウンタの定義
+type vrcIRQ struct {
+	latch          uint8
+	counter        uint8
+	prescaler      int
+	enabled        bool
+	enableAfterAck bool
+	cycleMode      bool // trueの場合はCPUサイクルごと、falseの場合はスキャンラインごと
+	pending        bool
+}
+
+// MARK: ラッチの下位4bitへの書き込み (VRC4)
+func (i *vrcIRQ) writeLatchLow(value uint8) {
+	i.latch = i.latch&0xF0 | value&0x0F
+}
+
+// MARK: ラッチの上位4bitへの書き込み (VRC4)
+func (i *vrcIRQ) writeLatchHigh(value uint8) {
+	i.latch = i.latch&0x0F | value<<4
+}
+
+// MARK: ラッチへの書き込み (VRC6 / VRC7)
+func (i *vrcIRQ) writeLatch(value uint8) {
+	i.latch = value
+}
+
+// MARK: 制御レジスタへの書き込み
+func (i *vrcIRQ) writeControl(value uint8) {
+	// bit0: 確認応答後の有効化, bit1: 有効化, bit2: サイクルモード
+	i.enableAfterAck = value&0x01 != 0
+	i.enabled = value&0x02 != 0
+	i.cycleMode = value&0x04 != 0
+	i.pending = false
+	if i.enabled {
+		i.counter = i.latch
+		i.prescaler = VRC_IRQ_PRESCALER_PERIOD
+	}
+}
+
+// MARK: 確認応答 (保留中のIRQを解除する)
+func (i *vrcIRQ) acknowledge() {
+	i.pending = false
+	i.enabled = i.enableAfterAck
+}
+
+// MARK: CPUサイクルの通知
+func (i *vrcIRQ) tick() {
+	if !i.enabled {
+		return
+	}
+	if i.cycleMode {
+		i.clock()
+		return
+	}
+	i.prescaler -= VRC_IRQ_PRESCALER_STEP
+	if i.prescaler <= 0 {
+		i.prescaler += VRC_IRQ_PRESCALER_PERIOD
+		i.clock()
+	}
+}
+
+// MARK: カウンタのクロック ($FFから桁あふれするとラッチを再ロードしてIRQを発生させる)
+func (i *vrcIRQ) clock() {
+	if i.counter == 0xFF {
+		i.counter = i.latch
+		i.pending = true
+	} else {
+		i.counter++
+	}
+}
@@ -6,6 +6,13 @@ import (
 	"fc-emu/bus"
 )
 
+const (
+	RESET_VECTOR = 0xFFFC
+	IRQ_VECTOR   = 0xFFFE
+
+	INTERRUPT_CYCLES = 7
+)
+
 // MARK: CPUの定義
 type CPU struct {
 	registers registers
@@ -16,6 +23,11 @@ type CPU struct {
 
 // MARK: CPUのコンストラクタ
 func NewCPU() *CPU {
+	return NewCPUWithBus(bus.NewBus())
+}
+
+// MARK: 接続するBusを指定するCPUのコンストラクタ
+func NewCPUWithBus(b bus.Bus) *CPU {
 	cpu := &CPU{
 		registers: registers{
 			A:  0x00,
@@ -25,13 +37,18 @@ func NewCPU() *CPU {
 			PC: 0x0000,
 			P:  NewStatusRegister(),
 		},
-		bus: bus.NewBus(),
+		bus: b,
 	}
 	cpu.instructionSet = generateInstructionSet(cpu)
 
 	return cpu
 }
 
+// MARK: 接続されているBusを返すメソッド (デバッガ等のツール向け)
+func (c *CPU) Bus() *bus.Bus {
+	return &c.bus
+}
+
 // MARK: N/Zフラグの更新メソッド
 func (c *CPU) updateNZFlags(result uint8) {
 	// Nフラグの更新
@@ -142,6 +159,15 @@ func (c *CPU) pullWord() uint16 {
 	return uint16(upper)<<8 | uint16(lower)
 }
 
+// MARK: リードモディファイライト命令の読み取り
+func (c *CPU) readModifyWrite(address uint16) uint8 {
+	// 実機は変更後の値を書き込む前のサイクルで、読み取った値をそのまま書き戻す (ダミーライト)
+	// MMC1等のマッパーはこの連続した書き込みの2回目を無視する
+	value := c.bus.ReadByteFrom(address)
+	c.bus.WriteByteAt(address, value)
+	return value
+}
+
 // MARK: 算術演算系 公式命令
 // ADC命令の実装
 func (c *CPU) adc(mode AddressingMode) {
@@ -163,7 +189,7 @@ func (c *CPU) adc(mode AddressingMode) {
 // DEC命令の実装
 func (c *CPU) dec(mode AddressingMode) {
 	address := c.calcOperandAddress(mode)
-	value := c.bus.ReadByteFrom(address) - 1
+	value := c.readModifyWrite(address) - 1
 	c.bus.WriteByteAt(address, value)
 	c.updateNZFlags(value)
 }
@@ -183,7 +209,7 @@ func (c *CPU) dey(_ AddressingMode) {
 // INC命令の実装
 func (c *CPU) inc(mode AddressingMode) {
 	address := c.calcOperandAddress(mode)
-	value := c.bus.ReadByteFrom(address) + 1
+	value := c.readModifyWrite(address) + 1
 	c.bus.WriteByteAt(address, value)
 	c.updateNZFlags(value)
 }
@@ -264,7 +290,7 @@ func (c *CPU) asl(mode AddressingMode) {
 		c.updateNZFlags(c.registers.A)
 	} else {
 		address := c.calcOperandAddress(mode)
-		value := c.bus.ReadByteFrom(address)
+		value := c.readModifyWrite(address)
 		c.registers.P.Carry = (value >> 7) != 0
 		value <<= 1
 		c.bus.WriteByteAt(address, value)
@@ -280,7 +306,7 @@ func (c *CPU) lsr(mode AddressingMode) {
 		c.updateNZFlags(c.registers.A)
 	} else {
 		address := c.calcOperandAddress(mode)
-		value := c.bus.ReadByteFrom(address)
+		value := c.readModifyWrite(address)
 		c.registers.P.Carry = (value & 0x01) != 0
 		value >>= 1
 		c.bus.WriteByteAt(address, value)
@@ -300,7 +326,7 @@ func (c *CPU) rol(mode AddressingMode) {
 		c.updateNZFlags(c.registers.A)
 	} else {
 		address := c.calcOperandAddress(mode)
-		value := c.bus.ReadByteFrom(address)
+		value := c.readModifyWrite(address)
 		carry := (value >> 7) != 0
 		value <<= 1
 		if c.registers.P.Carry {
@@ -325,7 +351,7 @@ func (c *CPU) ror(mode AddressingMode) {
 		c.updateNZFlags(c.registers.A)
 	} else {
 		address := c.calcOperandAddress(mode)
-		value := c.bus.ReadByteFrom(address)
+		value := c.readModifyWrite(address)
 		carry := (value & 0x01) != 0
 		value >>= 1
 		if c.registers.P.Carry {
@@ -759,16 +785,71 @@ func (c *CPU) xaa(mode AddressingMode) {
 	c.registers.A = (c.registers.A | 0xEE) & c.registers.X & value
 }
 
+// MARK: リセット
+func (c *CPU) Reset() {
+	c.registers.SP = 0xFD
+	c.registers.P.IrqDisabled = true
+	c.registers.PC = c.bus.ReadWordFrom(RESET_VECTOR)
+}
+
+// MARK: 割り込みの処理
+func (c *CPU) interrupt(vector uint16) {
+	c.pushWord(c.registers.PC)
+
+	// BRKと異なり、スタックに積むステータスのBフラグは0
+	status := c.registers.P
+	status.Break = false
+	c.pushByte(status.ToByte())
+
+	c.registers.P.IrqDisabled = true
+	c.registers.PC = c.bus.ReadWordFrom(vector)
+	c.bus.Tick(INTERRUPT_CYCLES)
+}
+
+// MARK: 1命令の実行
+func (c *CPU) Step() error {
+	// IRQはレベルトリガーのため、信号がアサートされている間は割り込みを受け付ける
+	if c.bus.IRQ() && !c.registers.P.IrqDisabled {
+		c.interrupt(IRQ_VECTOR)
+	}
+
+	// 命令のフェッチ
+	address := c.registers.PC
+	opcode := c.bus.FetchOpcodeFrom(address)
+	c.registers.PC++
+
+	// 命令のデコード
+	instruction, ok := c.instructionSet[opcode]
+	if !ok {
+		return fmt.Errorf("undefined opcode 0x%02X at $%04X", opcode, address)
+	}
+
+	c.execute(instruction)
+	return nil
+}
+
+// MARK: デコード済みの命令の実行
+func (c *CPU) execute(instruction instruction) {
+	// 命令の実行
+	instruction.Handler(instruction.AddressingMode)
+
+	// 命令長の分プログラムカウンタを進める (オペコードの分-1)
+	c.registers.PC += uint16(instruction.Bytes - 1)
+
+	// 命令のサイクル数の分だけ時間を進める
+	c.bus.Tick(uint64(instruction.Cycles))
+}
+
 // MARK: uint8の配列から実行
 func (c *CPU) RunWithByteArray(program []uint8) {
-	// Busに仮のプログラムをセット
+	// Busに仮のプログラムをセット (CPUによるアクセスではないため副作用なしで書き込む)
 	for i := range len(program) {
-		c.bus.WriteByteAt(uint16(i), program[i])
+		c.bus.PokeByteAt(uint16(i), program[i])
 	}
 
 	for {
 		// 命令のフェッチ
-		opcode := c.bus.ReadByteFrom(c.registers.PC)
+		opcode := c.bus.FetchOpcodeFrom(c.registers.PC)
 		c.registers.PC++
 
 		if opcode == 0x00 {
@@ -791,5 +872,8 @@ func (c *CPU) RunWithByteArray(program []uint8) {
 
 		// 命令長の分プログラムカウンタを進める (オペコードの分-1)
 		c.registers.PC += uint16(instruction.Bytes - 1)
+
+		// 命令のサイクル数の分だけ時間を進める
+		c.bus.Tick(uint64(instruction.Cycles))
 	}
 }
@@ -0,0 +1,72 @@
+package cpu
+
+import (
+	"testing"
+
+	"fc-emu/cartridge"
+)
+
+// MARK: テスト用のMMC1のiNESイメージ (128kB PRG-ROM, 各16kBバンクの値はバンク番号)
+func testMMC1Image() []uint8 {
+	const banks = 8
+	data := []uint8{'N', 'E', 'S', 0x1A, banks, 0, 0x10, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}
+	for bank := range banks {
+		for range cartridge.PRG_ROM_BANK_SIZE {
+			data = append(data, uint8(bank))
+		}
+	}
+	prg := data[cartridge.INES_HEADER_SIZE:]
+	prg[0] = 0xFF // INC $8000 の変更前の値 (bit7が立っている) と変更後の値 ($00)
+
+	// リセットベクタはWRAMの$0000
+	prg[len(prg)-4] = 0x00
+	prg[len(prg)-3] = 0x00
+	return data
+}
+
+// MARK: リードモディファイライト命令のダミーライトでMMC1のシフトレジスタがリセットされるかのテスト
+func TestRMWDummyWriteResetsMMC1(t *testing.T) {
+	cart, err := cartridge.Parse(testMMC1Image())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewCPU()
+	c.Bus().ConnectCartridge(cart)
+	c.Reset()
+
+	program := []uint8{
+		// $E000に4bitだけ書き込んでシフトレジスタを途中の状態にする
+		0xA9, 0x01, // LDA #$01
+		0x8D, 0x00, 0xE0, // STA $E000
+		0x8D, 0x00, 0xE0, // STA $E000
+		0xA9, 0x00, // LDA #$00
+		0x8D, 0x00, 0xE0, // STA $E000
+		0x8D, 0x00, 0xE0, // STA $E000
+
+		// ダミーライト ($FF) でリセットされ、直後の書き込み ($00) は無視される
+		0xEE, 0x00, 0x80, // INC $8000
+
+		// PRGバンク3を選択する (LSBから 1, 1, 0, 0, 0)
+		0xA9, 0x01, // LDA #$01
+		0x8D, 0x00, 0xE0, // STA $E000
+		0x8D, 0x00, 0xE0, // STA $E000
+		0xA9, 0x00, // LDA #$00
+		0x8D, 0x00, 0xE0, // STA $E000
+		0x8D, 0x00, 0xE0, // STA $E000
+		0x8D, 0x00, 0xE0, // STA $E000
+
+		0xAD, 0x00, 0x80, // LDA $8000
+	}
+	for i, b := range program {
+		c.Bus().PokeByteAt(uint16(i), b)
+	}
+
+	for range 16 {
+		if err := c.Step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if c.registers.A != 3 {
+		t.Fatalf("LDA $8000 = $%02X, want $03 (PRG bank 3)", c.registers.A)
+	}
+}
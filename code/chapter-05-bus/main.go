@@ -1,15 +1,214 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"fc-emu/audio"
+	"fc-emu/bus"
+	"fc-emu/cartridge"
 	"fc-emu/cpu"
+	"fc-emu/ram"
 )
 
+// コマンドライン引数の定義
+type options struct {
+	romPath    string
+	romEntry   string
+	patchPath  string
+	noPatch    bool
+	romDB      string
+	noROMDB    bool
+	savePath   string
+	noSave     bool
+	smoothN163 bool
+	steps      int
+	ramInit    string
+	ramSeed    int64
+	tracePath  string
+	traceRange string
+	heatmapPNG string
+	heatmapCSV string
+	audioWAV   string
+}
+
 func main() {
-	c := cpu.NewCPU()
+	var opts options
+	flag.StringVar(&opts.romPath, "rom", "", "iNES / NES 2.0 (.nes) or UNIF (.unf) ROM to load, optionally in a .zip or .gz; runs the built-in demo program when empty")
+	flag.StringVar(&opts.romEntry, "rom-entry", "", "name of the ROM inside a .zip holding several")
+	flag.StringVar(&opts.patchPath, "patch", "", "IPS, UPS or BPS patch to apply in memory (default: same-named patch next to the ROM)")
+	flag.BoolVar(&opts.noPatch, "no-patch", false, "do not auto-apply a same-named patch next to the ROM")
+	flag.StringVar(&opts.romDB, "romdb", "", "NES 2.0 database (.xml or .json) whose entries override the built-in ones (the built-in database ships empty)")
+	flag.BoolVar(&opts.noROMDB, "no-romdb", false, "trust the ROM header instead of correcting it from the database")
+	flag.StringVar(&opts.savePath, "save", "", "battery save file (default: the ROM path with a .sav extension)")
+	flag.BoolVar(&opts.noSave, "no-save", false, "do not load or write battery save files")
+	flag.BoolVar(&opts.smoothN163, "smooth-n163", false, "average Namco 163 wavetable channels instead of time-multiplexing them (avoids high-pitched whine)")
+	flag.IntVar(&opts.steps, "steps", 0, "number of instructions to execute from the ROM's reset vector")
+	flag.StringVar(&opts.ramInit, "ram-init", "zero", "power-on RAM contents: zero, ff, alternating or random")
+	flag.Int64Var(&opts.ramSeed, "ram-seed", 0, "seed for -ram-init=random")
+	flag.StringVar(&opts.tracePath, "trace", "", "write every bus access to this file")
+	flag.StringVar(&opts.traceRange, "trace-range", "", "only record these addresses, e.g. 0000-07FF,4016")
+	flag.StringVar(&opts.heatmapPNG, "heatmap-png", "", "write a 256x256 access heatmap PNG to this file")
+	flag.StringVar(&opts.heatmapCSV, "heatmap-csv", "", "write per-address access counts as CSV to this file")
+	flag.StringVar(&opts.audioWAV, "audio-wav", "", "write the mixed audio output (44.1 kHz, 16-bit mono) to this WAV file")
+	flag.Parse()
+
+	if err := run(opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(opts options) error {
+	pattern, err := ram.ParseInitPattern(opts.ramInit)
+	if err != nil {
+		return err
+	}
+
+	c := cpu.NewCPUWithBus(bus.NewBusWithPowerOnState(ram.PowerOnState{
+		Pattern: pattern,
+		Seed:    opts.ramSeed,
+	}))
+
+	// メモリアクセスの記録 (いずれかの出力先が指定された場合のみ)
+	var recorder *bus.Recorder
+	if opts.tracePath != "" || opts.heatmapPNG != "" || opts.heatmapCSV != "" {
+		ranges, err := bus.ParseAddressRanges(opts.traceRange)
+		if err != nil {
+			return err
+		}
+
+		var log io.Writer
+		if opts.tracePath != "" {
+			traceFile, err := os.Create(opts.tracePath)
+			if err != nil {
+				return err
+			}
+			defer traceFile.Close()
+			log = traceFile
+		}
+
+		recorder = bus.NewRecorder(log, ranges...)
+		c.Bus().AttachRecorder(recorder)
+	}
+
+	if opts.romPath != "" {
+		if err := runROM(c, opts); err != nil {
+			return err
+		}
+	} else {
+		// 配列から以下のプログラムを実行
+		// LDA #$24    ; A = $24
+		// AND #$0F    ; A = A & $0F
+		// BRK         ; break0
+		c.RunWithByteArray([]uint8{0xA9, 0x24, 0x29, 0x0F, 0x00})
+	}
+
+	if recorder == nil {
+		return nil
+	}
+	if err := recorder.Flush(); err != nil {
+		return err
+	}
+	if opts.heatmapPNG != "" {
+		if err := writeFile(opts.heatmapPNG, recorder.WriteHeatmapPNG); err != nil {
+			return err
+		}
+	}
+	if opts.heatmapCSV != "" {
+		if err := writeFile(opts.heatmapCSV, recorder.WriteHeatmapCSV); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ROMを読み込んでリセットベクタから実行する
+func runROM(c *cpu.CPU, opts options) error {
+	loadOpts := cartridge.LoadOptions{
+		ArchiveEntry:     opts.romEntry,
+		PatchPath:        opts.patchPath,
+		DisableAutoPatch: opts.noPatch,
+		DisableDatabase:  opts.noROMDB,
+		SavePath:         opts.savePath,
+		DisableSave:      opts.noSave,
+		SmoothAudio:      opts.smoothN163,
+	}
+	if opts.romDB != "" {
+		loadOpts.Database = cartridge.BuiltinDatabase()
+		if err := loadOpts.Database.LoadFile(opts.romDB); err != nil {
+			return err
+		}
+	}
+
+	cart, report, err := cartridge.Load(opts.romPath, loadOpts)
+	if err != nil {
+		return err
+	}
+	if report.PatchPath != "" {
+		fmt.Printf("applied patch: %s\n", report.PatchPath)
+	}
+	for _, correction := range report.Corrections {
+		fmt.Printf("header corrected by ROM database: %v\n", correction)
+	}
+	fmt.Printf("%s: %v\n", opts.romPath, cart.Header)
+	fmt.Printf("region: %s (CPU %d Hz)\n", cart.Region(), cart.Region().CPUClockHz())
+	if report.SavePath != "" {
+		fmt.Printf("battery save: %s\n", report.SavePath)
+	}
+
+	c.Bus().ConnectCartridge(cart)
+	c.Reset()
+
+	var mixer *audio.Mixer
+	if opts.audioWAV != "" {
+		mixer = audio.NewMixer(cart.Region().CPUClockHz(), audio.MIXER_DEFAULT_SAMPLE_RATE)
+		c.Bus().AttachMixer(mixer)
+	}
+
+	// Ctrl+C で中断された場合もセーブデータを書き出してから終了する
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+	defer signal.Stop(interrupted)
+
+	err = execute(c, opts.steps, interrupted)
+	if mixer != nil {
+		err = errors.Join(err, writeFile(opts.audioWAV, func(w io.Writer) error {
+			return audio.WriteWAV(w, mixer.SampleRate(), mixer.Samples())
+		}))
+	}
+	return errors.Join(err, cart.Close())
+}
+
+// 指定した命令数を実行する (中断された場合はその時点で終了する)
+func execute(c *cpu.CPU, steps int, interrupted <-chan os.Signal) error {
+	for range steps {
+		select {
+		case <-interrupted:
+			return nil
+		default:
+		}
+
+		if err := c.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// 配列から以下のプログラムを実行
-	// LDA #$24    ; A = $24
-	// AND #$0F    ; A = A & $0F
-	// BRK         ; break0
-	c.RunWithByteArray([]uint8{0xA9, 0x24, 0x29, 0x0F, 0x00})
+// ファイルを作成して内容を書き込む
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
@@ -0,0 +1,122 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	BPS_SOURCE_READ = iota // 元データの同じ位置からコピー
+	BPS_TARGET_READ        // パッチ内のデータをコピー
+	BPS_SOURCE_COPY        // 元データの任意の位置からコピー
+	BPS_TARGET_COPY        // 出力済みのデータからコピー
+)
+
+var BPS_MAGIC = []uint8{'B', 'P', 'S', '1'}
+
+// MARK: BPSパッチを適用する関数
+func ApplyBPS(source []uint8, patch []uint8) ([]uint8, error) {
+	/*
+		BPS 形式
+
+		"BPS1"
+		元データのサイズ / 適用後のサイズ / メタデータのサイズ (可変長整数) + メタデータ
+		コマンドの列: (長さ-1) << 2 | コマンド番号 (可変長整数)
+			SourceCopy / TargetCopy の場合は相対オフセット (可変長整数, bit0が符号) が続く
+		フッタ (元データ / 適用後 / パッチ自身のCRC32, リトルエンディアン)
+	*/
+	if !bytes.HasPrefix(patch, BPS_MAGIC) {
+		return nil, ErrUnknownFormat
+	}
+	targetCRC, err := verifyFooter(source, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reader{data: patch, offset: len(BPS_MAGIC), end: len(patch) - UPS_FOOTER_SIZE}
+	sourceSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	metadataSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.bytes(metadataSize); err != nil {
+		return nil, err
+	}
+	if err := checkTargetSize(targetSize); err != nil {
+		return nil, err
+	}
+	if sourceSize != len(source) {
+		return nil, fmt.Errorf("%w: got %d bytes, patch expects %d", ErrSourceSize, len(source), sourceSize)
+	}
+
+	target := make([]uint8, targetSize)
+	outputOffset, sourceRelative, targetRelative := 0, 0, 0
+
+	for r.offset < r.end {
+		data, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		command := data & 0x03
+		length := data>>2 + 1
+
+		if outputOffset+length > targetSize {
+			return nil, fmt.Errorf("%w: write of %d bytes at %d exceeds target size %d", ErrOutOfRange, length, outputOffset, targetSize)
+		}
+
+		switch command {
+		case BPS_SOURCE_READ:
+			if outputOffset+length > len(source) {
+				return nil, fmt.Errorf("%w: SourceRead at %d", ErrOutOfRange, outputOffset)
+			}
+			copy(target[outputOffset:], source[outputOffset:outputOffset+length])
+		case BPS_TARGET_READ:
+			data, err := r.bytes(length)
+			if err != nil {
+				return nil, err
+			}
+			copy(target[outputOffset:], data)
+		case BPS_SOURCE_COPY, BPS_TARGET_COPY:
+			offset, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			delta := offset >> 1
+			if offset&1 != 0 {
+				delta = -delta
+			}
+
+			if command == BPS_SOURCE_COPY {
+				sourceRelative += delta
+				if sourceRelative < 0 || sourceRelative+length > len(source) {
+					return nil, fmt.Errorf("%w: SourceCopy from %d", ErrOutOfRange, sourceRelative)
+				}
+				copy(target[outputOffset:], source[sourceRelative:sourceRelative+length])
+				sourceRelative += length
+			} else {
+				targetRelative += delta
+				if targetRelative < 0 || targetRelative >= outputOffset {
+					return nil, fmt.Errorf("%w: TargetCopy from %d", ErrOutOfRange, targetRelative)
+				}
+				// 出力中の範囲と重なる場合があるため1バイトずつコピーする
+				for i := range length {
+					target[outputOffset+i] = target[targetRelative+i]
+				}
+				targetRelative += length
+			}
+		}
+		outputOffset += length
+	}
+
+	if err := verifyTarget(target, targetCRC); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
@@ -0,0 +1,81 @@
+package patch
+
+import (
+	"bytes"
+	"fmt"
+)
+
+const (
+	IPS_EOF_OFFSET      = 0x454F46 // "EOF"
+	IPS_TRUNCATION_SIZE = 3
+)
+
+var (
+	IPS_MAGIC = []uint8{'P', 'A', 'T', 'C', 'H'}
+	IPS_EOF   = []uint8{'E', 'O', 'F'}
+)
+
+// MARK: IPSパッチを適用する関数
+func ApplyIPS(source []uint8, patch []uint8) ([]uint8, error) {
+	/*
+		IPS 形式
+
+		"PATCH"
+		レコードの列:
+			オフセット (3バイト, ビッグエンディアン) + サイズ (2バイト) + データ
+			サイズが0の場合はRLE: 繰り返し回数 (2バイト) + 値 (1バイト)
+		"EOF"
+		(拡張) 切り詰め後のサイズ (3バイト)
+	*/
+	if !bytes.HasPrefix(patch, IPS_MAGIC) {
+		return nil, ErrUnknownFormat
+	}
+
+	target := bytes.Clone(source)
+	r := &reader{data: patch, offset: len(IPS_MAGIC), end: len(patch)}
+
+	for {
+		header, err := r.bytes(3)
+		if err != nil {
+			return nil, fmt.Errorf("missing EOF marker: %w", err)
+		}
+		offset := int(header[0])<<16 | int(header[1])<<8 | int(header[2])
+		if offset == IPS_EOF_OFFSET {
+			break
+		}
+
+		sizeBytes, err := r.bytes(2)
+		if err != nil {
+			return nil, fmt.Errorf("record at $%06X: %w", offset, err)
+		}
+		size := int(sizeBytes[0])<<8 | int(sizeBytes[1])
+
+		var data []uint8
+		if size == 0 {
+			// RLEレコード
+			rle, err := r.bytes(3)
+			if err != nil {
+				return nil, fmt.Errorf("RLE record at $%06X: %w", offset, err)
+			}
+			data = bytes.Repeat([]uint8{rle[2]}, int(rle[0])<<8|int(rle[1]))
+		} else if data, err = r.bytes(size); err != nil {
+			return nil, fmt.Errorf("record at $%06X: %w", offset, err)
+		}
+
+		// ファイル末尾を超える書き込みはファイルを拡張する
+		if end := offset + len(data); end > len(target) {
+			target = append(target, make([]uint8, end-len(target))...)
+		}
+		copy(target[offset:], data)
+	}
+
+	// EOFの後に3バイトあれば切り詰め後のサイズとして扱う
+	if rest := patch[r.offset:]; len(rest) == IPS_TRUNCATION_SIZE {
+		size := int(rest[0])<<16 | int(rest[1])<<8 | int(rest[2])
+		if size < len(target) {
+			target = target[:size]
+		}
+	}
+
+	return target, nil
+}
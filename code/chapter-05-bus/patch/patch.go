@@ -0,0 +1,136 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown patch format (want IPS, UPS or BPS)")
+	ErrTruncated     = errors.New("patch ends in the middle of a record")
+	ErrChecksum      = errors.New("checksum mismatch")
+	ErrSourceSize    = errors.New("source size does not match the patch")
+	ErrOutOfRange    = errors.New("patch refers to data outside the file")
+	ErrTargetSize    = errors.New("patch target size is too large")
+)
+
+const (
+	MAX_TARGET_SIZE = 64 * 1024 * 1024 // 適用後のサイズの上限 (壊れたパッチで巨大な領域を確保しないため)
+)
+
+// MARK: パッチの形式の定義
+type Format uint8
+
+const (
+	FormatIPS Format = iota
+	FormatUPS
+	FormatBPS
+)
+
+// MARK: パッチの形式の文字列表現
+func (f Format) String() string {
+	switch f {
+	case FormatIPS:
+		return "IPS"
+	case FormatUPS:
+		return "UPS"
+	case FormatBPS:
+		return "BPS"
+	default:
+		return fmt.Sprintf("Format(%d)", uint8(f))
+	}
+}
+
+// MARK: パッチの形式を判別する関数
+func Detect(patch []uint8) (Format, error) {
+	switch {
+	case bytes.HasPrefix(patch, IPS_MAGIC):
+		return FormatIPS, nil
+	case bytes.HasPrefix(patch, UPS_MAGIC):
+		return FormatUPS, nil
+	case bytes.HasPrefix(patch, BPS_MAGIC):
+		return FormatBPS, nil
+	default:
+		return 0, ErrUnknownFormat
+	}
+}
+
+// MARK: 形式を判別してパッチを適用する関数
+func Apply(source []uint8, patch []uint8) ([]uint8, error) {
+	// 元のデータは変更せず、新しいバイト列を返す
+	format, err := Detect(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	var target []uint8
+	switch format {
+	case FormatIPS:
+		target, err = ApplyIPS(source, patch)
+	case FormatUPS:
+		target, err = ApplyUPS(source, patch)
+	case FormatBPS:
+		target, err = ApplyBPS(source, patch)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", format, err)
+	}
+	return target, nil
+}
+
+// MARK: 適用後のサイズを検証する関数
+func checkTargetSize(size int) error {
+	if size > MAX_TARGET_SIZE {
+		return fmt.Errorf("%w: %d bytes (limit %d)", ErrTargetSize, size, MAX_TARGET_SIZE)
+	}
+	return nil
+}
+
+// MARK: パッチを先頭から読み進めるリーダーの定義
+type reader struct {
+	data   []uint8
+	offset int
+	end    int // 読み取り可能な終端 (フッタを除く)
+}
+
+// MARK: 1バイト読み取るメソッド
+func (r *reader) byte() (uint8, error) {
+	if r.offset >= r.end {
+		return 0, ErrTruncated
+	}
+	value := r.data[r.offset]
+	r.offset++
+	return value, nil
+}
+
+// MARK: 指定したバイト数を読み取るメソッド
+func (r *reader) bytes(length int) ([]uint8, error) {
+	if length < 0 || r.end-r.offset < length {
+		return nil, ErrTruncated
+	}
+	value := r.data[r.offset : r.offset+length]
+	r.offset += length
+	return value, nil
+}
+
+// MARK: UPS/BPSの可変長整数を読み取るメソッド
+func (r *reader) varint() (int, error) {
+	// 各バイトの下位7bitを格納し、最上位bitが立っていれば終端
+	value, shift := 0, 1
+	for {
+		x, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		value += int(x&0x7F) * shift
+		if x&0x80 != 0 {
+			return value, nil
+		}
+		shift <<= 7
+		value += shift
+		if shift > 1<<48 {
+			return 0, fmt.Errorf("%w: variable-length integer is too long", ErrTruncated)
+		}
+	}
+}
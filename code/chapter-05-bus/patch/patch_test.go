@@ -0,0 +1,185 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+// MARK: UPS/BPSの可変長整数を書き込む関数
+func appendVarint(data []uint8, value int) []uint8 {
+	for {
+		x := uint8(value & 0x7F)
+		value >>= 7
+		if value == 0 {
+			return append(data, 0x80|x)
+		}
+		data = append(data, x)
+		value--
+	}
+}
+
+// MARK: UPS/BPSのフッタを付加する関数
+func appendFooter(patch []uint8, source []uint8, target []uint8) []uint8 {
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(source))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+}
+
+// MARK: テスト用のパッチを組み立てる関数
+func join(parts ...[]uint8) []uint8 {
+	return bytes.Join(parts, nil)
+}
+
+// MARK: BPSのコマンドを書き込む関数
+func appendBPSCommand(data []uint8, command int, length int) []uint8 {
+	return appendVarint(data, (length-1)<<2|command)
+}
+
+// MARK: BPSの相対オフセットを書き込む関数
+func appendBPSOffset(data []uint8, delta int) []uint8 {
+	if delta < 0 {
+		return appendVarint(data, -delta<<1|1)
+	}
+	return appendVarint(data, delta<<1)
+}
+
+// MARK: パッチ適用のテスト
+func TestApply(t *testing.T) {
+	source := []uint8("0123456789ABCDEF")
+
+	// UPS: 2バイト目から2バイトをXORし、末尾に1バイト追加する
+	upsTarget := []uint8("01\x00\x00456789ABCDEFG")
+	upsBody := join(UPS_MAGIC, appendVarint(nil, len(source)), appendVarint(appendVarint(nil, len(upsTarget)), 2),
+		[]uint8{'2', '3', 0x00}, appendVarint(nil, len(source)-5), []uint8{'G', 0x00})
+
+	// BPS: SourceRead / TargetRead / SourceCopy / TargetCopy を1回ずつ使う
+	bpsTarget := []uint8("0123xyz89AB123x")
+	bpsBody := join(BPS_MAGIC, appendVarint(nil, len(source)), appendVarint(nil, len(bpsTarget)), appendVarint(nil, 0))
+	bpsBody = appendBPSCommand(bpsBody, BPS_SOURCE_READ, 4)
+	bpsBody = append(appendBPSCommand(bpsBody, BPS_TARGET_READ, 3), "xyz"...)
+	bpsBody = appendBPSOffset(appendBPSCommand(bpsBody, BPS_SOURCE_COPY, 4), 8)
+	bpsBody = appendBPSOffset(appendBPSCommand(bpsBody, BPS_TARGET_COPY, 4), 1)
+
+	tests := []struct {
+		name   string
+		patch  []uint8
+		target []uint8
+		err    error
+	}{
+		{
+			name:   "IPS record",
+			patch:  join(IPS_MAGIC, []uint8{0x00, 0x00, 0x02, 0x00, 0x02, 'a', 'b'}, IPS_EOF),
+			target: []uint8("01ab456789ABCDEF"),
+		},
+		{
+			name:   "IPS RLE record",
+			patch:  join(IPS_MAGIC, []uint8{0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x03, '*'}, IPS_EOF),
+			target: []uint8("0123***789ABCDEF"),
+		},
+		{
+			name:   "IPS record past the end extends the file",
+			patch:  join(IPS_MAGIC, []uint8{0x00, 0x00, 0x12, 0x00, 0x01, 'z'}, IPS_EOF),
+			target: []uint8("0123456789ABCDEF\x00\x00z"),
+		},
+		{
+			name:   "IPS truncation extension",
+			patch:  join(IPS_MAGIC, IPS_EOF, []uint8{0x00, 0x00, 0x04}),
+			target: []uint8("0123"),
+		},
+		{
+			name:  "IPS truncated record",
+			patch: join(IPS_MAGIC, []uint8{0x00, 0x00, 0x02, 0x00, 0x04, 'a'}),
+			err:   ErrTruncated,
+		},
+		{
+			name:  "IPS missing EOF",
+			patch: join(IPS_MAGIC, []uint8{0x00, 0x00, 0x02, 0x00, 0x01, 'a'}),
+			err:   ErrTruncated,
+		},
+		{
+			name:   "UPS",
+			patch:  appendFooter(upsBody, source, upsTarget),
+			target: upsTarget,
+		},
+		{
+			name:  "UPS wrong source",
+			patch: appendFooter(upsBody, []uint8("other ROM"), upsTarget),
+			err:   ErrChecksum,
+		},
+		{
+			name:  "UPS wrong result",
+			patch: appendFooter(upsBody, source, source),
+			err:   ErrChecksum,
+		},
+		{
+			name:  "UPS source size",
+			patch: appendFooter(join(UPS_MAGIC, appendVarint(nil, 4), appendVarint(nil, 4)), source, source[:4]),
+			err:   ErrSourceSize,
+		},
+		{
+			name:  "UPS target size too large",
+			patch: appendFooter(join(UPS_MAGIC, appendVarint(nil, len(source)), appendVarint(nil, MAX_TARGET_SIZE+1)), source, nil),
+			err:   ErrTargetSize,
+		},
+		{
+			name:   "BPS",
+			patch:  appendFooter(bpsBody, source, bpsTarget),
+			target: bpsTarget,
+		},
+		{
+			name:  "BPS corrupt patch",
+			patch: func() []uint8 { p := appendFooter(bpsBody, source, bpsTarget); p[len(BPS_MAGIC)+3] ^= 0x01; return p }(),
+			err:   ErrChecksum,
+		},
+		{
+			name:  "BPS wrong result",
+			patch: appendFooter(bpsBody, source, source),
+			err:   ErrChecksum,
+		},
+		{
+			name:  "BPS truncated command",
+			patch: appendFooter(append(bpsBody[:len(bpsBody)-1:len(bpsBody)-1], 0x00), source, bpsTarget),
+			err:   ErrTruncated,
+		},
+		{
+			name:  "BPS without footer",
+			patch: join(BPS_MAGIC, []uint8{0x90}),
+			err:   ErrTruncated,
+		},
+		{
+			name:  "BPS target size too large",
+			patch: appendFooter(join(BPS_MAGIC, appendVarint(nil, len(source)), appendVarint(nil, MAX_TARGET_SIZE+1), appendVarint(nil, 0)), source, nil),
+			err:   ErrTargetSize,
+		},
+		{
+			name:  "unknown format",
+			patch: []uint8("NOT A PATCH"),
+			err:   ErrUnknownFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := bytes.Clone(source)
+			target, err := Apply(source, tt.patch)
+			if !bytes.Equal(source, original) {
+				t.Fatal("Apply modified the source")
+			}
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(target, tt.target) {
+				t.Fatalf("target = %q, want %q", target, tt.target)
+			}
+		})
+	}
+}
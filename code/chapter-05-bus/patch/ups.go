@@ -0,0 +1,114 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	UPS_FOOTER_SIZE = 12 // 元データ / 適用後 / パッチ自身のCRC32
+)
+
+var UPS_MAGIC = []uint8{'U', 'P', 'S', '1'}
+
+// MARK: UPS/BPSのフッタのCRC32を検証する関数
+func verifyFooter(source []uint8, patch []uint8) (uint32, error) {
+	// 戻り値は適用後のデータのCRC32 (適用後に検証する)
+	if len(patch) < UPS_FOOTER_SIZE {
+		return 0, ErrTruncated
+	}
+	footer := patch[len(patch)-UPS_FOOTER_SIZE:]
+	sourceCRC := binary.LittleEndian.Uint32(footer[0:4])
+	targetCRC := binary.LittleEndian.Uint32(footer[4:8])
+	patchCRC := binary.LittleEndian.Uint32(footer[8:12])
+
+	if actual := crc32.ChecksumIEEE(patch[:len(patch)-4]); actual != patchCRC {
+		return 0, fmt.Errorf("%w: patch CRC32 is %08X, footer says %08X (corrupt patch)", ErrChecksum, actual, patchCRC)
+	}
+	if actual := crc32.ChecksumIEEE(source); actual != sourceCRC {
+		return 0, fmt.Errorf("%w: source CRC32 is %08X, patch expects %08X (wrong ROM)", ErrChecksum, actual, sourceCRC)
+	}
+	return targetCRC, nil
+}
+
+// MARK: 適用後のデータのCRC32を検証する関数
+func verifyTarget(target []uint8, expected uint32) error {
+	if actual := crc32.ChecksumIEEE(target); actual != expected {
+		return fmt.Errorf("%w: result CRC32 is %08X, patch expects %08X", ErrChecksum, actual, expected)
+	}
+	return nil
+}
+
+// MARK: UPSパッチを適用する関数
+func ApplyUPS(source []uint8, patch []uint8) ([]uint8, error) {
+	/*
+		UPS 形式
+
+		"UPS1"
+		元データのサイズ (可変長整数) / 適用後のサイズ (可変長整数)
+		ハンクの列:
+			前回の位置からの相対オフセット (可変長整数)
+			元データとXORするバイト列 ($00で終端, $00の分も1バイト進む)
+		フッタ (元データ / 適用後 / パッチ自身のCRC32, リトルエンディアン)
+	*/
+	if !bytes.HasPrefix(patch, UPS_MAGIC) {
+		return nil, ErrUnknownFormat
+	}
+	targetCRC, err := verifyFooter(source, patch)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &reader{data: patch, offset: len(UPS_MAGIC), end: len(patch) - UPS_FOOTER_SIZE}
+	sourceSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTargetSize(targetSize); err != nil {
+		return nil, err
+	}
+	if sourceSize != len(source) {
+		return nil, fmt.Errorf("%w: got %d bytes, patch expects %d", ErrSourceSize, len(source), sourceSize)
+	}
+
+	target := make([]uint8, targetSize)
+	copy(target, source)
+
+	offset := 0
+	for r.offset < r.end {
+		relative, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		offset += relative
+
+		for {
+			x, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			if offset < targetSize {
+				var original uint8
+				if offset < len(source) {
+					original = source[offset]
+				}
+				target[offset] = original ^ x
+			}
+			offset++
+			if x == 0 {
+				break
+			}
+		}
+	}
+
+	if err := verifyTarget(target, targetCRC); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
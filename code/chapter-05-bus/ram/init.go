@@ -0,0 +1,96 @@
+package ram
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// MARK: 電源投入時のRAM初期化パターンの定義
+type InitPattern uint8
+
+const (
+	InitZero        InitPattern = iota // すべて$00
+	InitFF                             // すべて$FF
+	InitAlternating                    // $00 x4, $FF x4 の繰り返し (実機でよく見られるパターン)
+	InitRandom                         // シード付きの乱数
+)
+
+const (
+	ALTERNATING_RUN_LENGTH = 4 // $00/$FFが切り替わるバイト数
+)
+
+// MARK: 初期化パターンの文字列表現
+func (p InitPattern) String() string {
+	switch p {
+	case InitZero:
+		return "zero"
+	case InitFF:
+		return "ff"
+	case InitAlternating:
+		return "alternating"
+	case InitRandom:
+		return "random"
+	default:
+		return fmt.Sprintf("InitPattern(%d)", uint8(p))
+	}
+}
+
+// MARK: 文字列から初期化パターンへ変換する関数
+func ParseInitPattern(name string) (InitPattern, error) {
+	switch strings.ToLower(name) {
+	case "zero", "00":
+		return InitZero, nil
+	case "ff":
+		return InitFF, nil
+	case "alternating", "pattern":
+		return InitAlternating, nil
+	case "random":
+		return InitRandom, nil
+	default:
+		return InitZero, fmt.Errorf("unknown RAM init pattern %q (want zero, ff, alternating or random)", name)
+	}
+}
+
+// MARK: 電源投入時のRAMの状態の定義
+type PowerOnState struct {
+	Pattern InitPattern
+	Seed    int64 // InitRandomの場合のみ使用
+}
+
+// MARK: RAM初期化器の定義
+type Initializer struct {
+	pattern InitPattern
+	rng     *rand.Rand
+}
+
+// MARK: RAM初期化器のコンストラクタ
+func NewInitializer(state PowerOnState) *Initializer {
+	return &Initializer{
+		pattern: state.Pattern,
+		rng:     rand.New(rand.NewSource(state.Seed)),
+	}
+}
+
+// MARK: メモリを初期化パターンで埋めるメソッド
+func (i *Initializer) Fill(memory []uint8) {
+	// 乱数は呼び出し順に消費されるため、同じシード・同じ順序であれば常に同じ内容になる
+	for n := range memory {
+		switch i.pattern {
+		case InitFF:
+			memory[n] = 0xFF
+		case InitAlternating:
+			if (n/ALTERNATING_RUN_LENGTH)%2 == 0 {
+				memory[n] = 0x00
+			} else {
+				memory[n] = 0xFF
+			}
+		case InitRandom:
+			memory[n] = uint8(i.rng.Intn(0x100))
+		case InitZero:
+			fallthrough
+		default:
+			memory[n] = 0x00
+		}
+	}
+}